@@ -0,0 +1,10 @@
+// Package configs
+// Date: 2024/5/10 10:00
+// Author: Amu
+// Description: 示例配置，amprobe config init 以此为模板生成默认配置
+package configs
+
+import _ "embed"
+
+//go:embed config.toml
+var Template []byte
@@ -6,7 +6,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/amuluze/amprobe/service"
 
@@ -20,29 +22,221 @@ func main() {
 	app.Usage = "resource monitor"
 	app.Commands = []*cli.Command{
 		monitorCmd(ctx),
+		auditCmd(ctx),
+		migrateCmd(ctx),
+		configCmd(ctx),
 	}
 	if err := app.Run(os.Args); err != nil {
 		panic(err)
 	}
 }
 
+// configFlags 配置文件及覆盖各配置项的参数，优先级: 命令行参数 > AMPROBE_ 环境变量 > 配置文件 > 默认值
+func configFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     "conf",
+			Aliases:  []string{"c"},
+			Usage:    "App Configuration file(.toml), can be omitted when configured by environment variables",
+			EnvVars:  []string{service.EnvPrefix + "_CONFIG"},
+			Required: false,
+		},
+	}
+	for _, key := range service.ConfigKeys() {
+		flags = append(flags, &cli.StringFlag{
+			Name:     key.Name,
+			Usage:    fmt.Sprintf("override %s (env %s)", key.Path, key.Env),
+			Category: "config overrides",
+		})
+	}
+	return flags
+}
+
+// configOptions 读取配置文件路径和命令行中设置的配置项
+func configOptions(c *cli.Context) []service.Option {
+	overrides := make(map[string]string)
+	for _, key := range service.ConfigKeys() {
+		if c.IsSet(key.Name) {
+			overrides[key.Name] = c.String(key.Name)
+		}
+	}
+	return []service.Option{
+		service.SetConfigFile(c.String("conf")),
+		service.SetOverrides(overrides),
+	}
+}
+
 func monitorCmd(ctx context.Context) *cli.Command {
 	return &cli.Command{
 		Name:  "run",
 		Usage: "run amprobe service",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:     "conf",
-				Aliases:  []string{"c"},
-				Usage:    "App Configuration file(.toml)",
-				Required: false,
+		Flags: configFlags(),
+		Action: func(c *cli.Context) error {
+			return service.Run(ctx, configOptions(c)...)
+		},
+	}
+}
+
+func auditCmd(ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:  "audit",
+		Usage: "audit log tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "verify",
+				Usage: "verify audit log hash chain",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "conf",
+						Aliases:  []string{"c"},
+						Usage:    "App Configuration file(.toml)",
+						Required: false,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					res, err := service.AuditVerify(
+						ctx,
+						service.SetConfigFile(c.String("conf")),
+					)
+					if err != nil {
+						return err
+					}
+					if !res.OK {
+						return cli.Exit(fmt.Sprintf("audit chain broken at id %d: %s (%d records checked)", res.BrokenID, res.Reason, res.Checked), 1)
+					}
+					fmt.Printf("audit chain ok, %d records checked\n", res.Checked)
+					return nil
+				},
 			},
 		},
-		Action: func(c *cli.Context) error {
-			return service.Run(
-				ctx,
-				service.SetConfigFile(c.String("conf")),
-			)
+	}
+}
+
+func migrateCmd(ctx context.Context) *cli.Command {
+	confFlag := &cli.StringFlag{
+		Name:     "conf",
+		Aliases:  []string{"c"},
+		Usage:    "App Configuration file(.toml)",
+		Required: false,
+	}
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "database schema migrations",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "apply pending migrations",
+				Flags: []cli.Flag{confFlag},
+				Action: func(c *cli.Context) error {
+					versions, err := service.MigrateUp(ctx, service.SetConfigFile(c.String("conf")))
+					if err != nil {
+						return err
+					}
+					if len(versions) == 0 {
+						fmt.Println("database is up to date")
+						return nil
+					}
+					fmt.Printf("applied migrations: %v\n", versions)
+					return nil
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "show migration status",
+				Flags: []cli.Flag{confFlag},
+				Action: func(c *cli.Context) error {
+					list, err := service.MigrateStatus(ctx, service.SetConfigFile(c.String("conf")))
+					if err != nil {
+						return err
+					}
+					for _, m := range list {
+						state := "pending"
+						if m.Applied {
+							state = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+						}
+						fmt.Printf("%4d  %-30s %s\n", m.Version, m.Name, state)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func configCmd(ctx context.Context) *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "configuration tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "validate",
+				Usage: "check types, values, devices, container runtime and signing key of the effective configuration",
+				Flags: configFlags(),
+				Action: func(c *cli.Context) error {
+					report, err := service.ValidateConfig(ctx, configOptions(c)...)
+					if err != nil {
+						return err
+					}
+					for _, issue := range report.Issues {
+						fmt.Println(issue)
+					}
+					errs, warns := report.Count()
+					if errs > 0 {
+						return cli.Exit(fmt.Sprintf("config is invalid: %d error(s), %d warning(s)", errs, warns), 1)
+					}
+					fmt.Printf("config is valid: %d warning(s)\n", warns)
+					return nil
+				},
+			},
+			{
+				Name:  "init",
+				Usage: "write a commented default configuration for this host",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "path of the configuration file to write",
+						Value:   "config.toml",
+					},
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "overwrite the file if it exists",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					path := c.String("output")
+					if _, err := os.Stat(path); err == nil && !c.Bool("force") {
+						return cli.Exit(fmt.Sprintf("%s already exists, use --force to overwrite", path), 1)
+					}
+					data, err := service.DefaultConfig(path)
+					if err != nil {
+						return err
+					}
+					if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+						return err
+					}
+					// 包含签名密钥，仅当前用户可读
+					if err := os.WriteFile(path, data, 0o600); err != nil {
+						return err
+					}
+					fmt.Printf("config written to %s\n", path)
+					return nil
+				},
+			},
+			{
+				Name:  "print",
+				Usage: "print the effective configuration with secrets redacted",
+				Flags: configFlags(),
+				Action: func(c *cli.Context) error {
+					data, err := service.PrintConfig(ctx, configOptions(c)...)
+					if err != nil {
+						return err
+					}
+					fmt.Print(string(data))
+					return nil
+				},
+			},
 		},
 	}
 }
@@ -0,0 +1,223 @@
+// Package auditlog
+// Date: 2024/4/23 09:30
+// Author: Amu
+// Description: 审计日志归档，过期记录导出为 gzip 压缩的 JSONL 文件后删除
+package auditlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"github.com/amuluze/amutool/timex"
+	"gorm.io/gorm"
+)
+
+const (
+	archiveBatchSize = 5000
+	archiveSuffix    = ".jsonl.gz"
+)
+
+var ErrInvalidArchive = errors.New("invalid archive name")
+
+// ArchiveFile 归档文件信息
+type ArchiveFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+type Archiver struct {
+	DB *database.DB
+	// Chain 删除归档记录时同时移动链尾中的锚点
+	Chain *Chain
+	Dir   string
+	// Retention 审计记录保留时长，<= 0 表示不清理
+	Retention time.Duration
+	ticker    timex.Ticker
+	stopCh    chan struct{}
+}
+
+func NewArchiver(chain *Chain, dir string, retention time.Duration) *Archiver {
+	return &Archiver{
+		DB:        chain.DB,
+		Chain:     chain,
+		Dir:       dir,
+		Retention: retention,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Run 按固定间隔执行归档，直到调用 Stop
+func (a *Archiver) Run(interval time.Duration) {
+	if a.Retention <= 0 {
+		return
+	}
+	a.ticker = timex.NewTicker(interval)
+	a.archive()
+	for {
+		select {
+		case <-a.ticker.Chan():
+			a.archive()
+		case <-a.stopCh:
+			a.ticker.Stop()
+			return
+		}
+	}
+}
+
+func (a *Archiver) Stop() {
+	close(a.stopCh)
+}
+
+func (a *Archiver) archive() {
+	file, err := a.Archive(time.Now().Add(-a.Retention))
+	if err != nil {
+		slog.Error("archive audit failed", "error", err)
+		return
+	}
+	if file != nil {
+		slog.Info("archive audit", "file", file.Name, "size", file.Size)
+	}
+}
+
+// Archive 将 before 之前的审计记录写入归档文件并删除，没有需要归档的记录时返回 nil
+// 最新的一条记录始终保留，保证哈希链在数据库中可以继续衔接；
+// 只归档按 ID 连续的最早一段记录，遇到 before 之后的记录即停止，保证锚点之前没有剩余记录
+func (a *Archiver) Archive(before time.Time) (*ArchiveFile, error) {
+	var latest model.Audit
+	if err := a.DB.Unscoped().Select("id").Order("id DESC").Limit(1).Find(&latest).Error; err != nil {
+		return nil, err
+	}
+	query := a.DB.Unscoped().Model(&model.Audit{}).Where("created_at < ? AND id < ?", before, latest.ID)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(a.Dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(a.Dir, "audit_*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	buf := bufio.NewWriter(tmp)
+	gz := gzip.NewWriter(buf)
+	enc := json.NewEncoder(gz)
+	var firstID, lastID uint
+	var lastHash string
+	done := false
+	for !done {
+		var audits model.Audits
+		err := a.DB.Unscoped().Model(&model.Audit{}).
+			Where("id < ? AND id > ?", latest.ID, lastID).
+			Order("id ASC").Limit(archiveBatchSize).Find(&audits).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range audits {
+			if !audits[i].CreatedAt.Before(before) {
+				done = true
+				break
+			}
+			if firstID == 0 {
+				firstID = audits[i].ID
+			}
+			lastID, lastHash = audits[i].ID, audits[i].Hash
+			if err := enc.Encode(NewEvent(&audits[i])); err != nil {
+				return nil, err
+			}
+		}
+		if len(audits) < archiveBatchSize {
+			break
+		}
+	}
+	if firstID == 0 {
+		return nil, nil
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("audit_%s_%d-%d%s", time.Now().Format("20060102150405"), firstID, lastID, archiveSuffix)
+	path := filepath.Join(a.Dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	// 归档文件落盘之后再删除记录，锚点与删除在同一个事务中更新
+	err = a.Chain.transact(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("id >= ? AND id <= ?", firstID, lastID).Delete(&model.Audit{}).Error; err != nil {
+			return err
+		}
+		return a.Chain.moveAnchor(tx, lastID, lastHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveFile{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List 列出归档文件，按时间倒序
+func (a *Archiver) List() ([]ArchiveFile, error) {
+	entries, err := os.ReadDir(a.Dir)
+	if os.IsNotExist(err) {
+		return []ArchiveFile{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	files := make([]ArchiveFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), archiveSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, ArchiveFile{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+// Path 返回归档文件的完整路径，拒绝目录穿越
+func (a *Archiver) Path(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || !strings.HasSuffix(name, archiveSuffix) {
+		return "", ErrInvalidArchive
+	}
+	path := filepath.Join(a.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
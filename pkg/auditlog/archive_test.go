@@ -0,0 +1,110 @@
+// Package auditlog
+// Date: 2024/4/23 10:12
+// Author: Amu
+// Description:
+package auditlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/service/model"
+)
+
+func TestArchive(t *testing.T) {
+	chain := openTestChain(t)
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	// 前三条记录已过期
+	for i := 0; i < 5; i++ {
+		created := old
+		if i >= 3 {
+			created = time.Now()
+		}
+		if err := chain.Record(&model.Audit{CreatedAt: created, Username: "admin", Operate: "登录"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	archiver := NewArchiver(chain, dir, 24*time.Hour)
+	file, err := archiver.Archive(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file == nil {
+		t.Fatal("expected archive file")
+	}
+
+	var count int64
+	chain.DB.Model(&model.Audit{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 remaining rows, got %d", count)
+	}
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK {
+		t.Fatalf("chain broken after archive: %+v", res)
+	}
+
+	path, err := archiver.Path(file.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 3 {
+		t.Fatalf("expected 3 archived rows, got %d", lines)
+	}
+
+	files, err := archiver.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != file.Name {
+		t.Fatalf("unexpected archive list: %+v", files)
+	}
+
+	// 锚点之后最早的记录被删除
+	chain.DB.Exec("DELETE FROM s_audit WHERE id = ?", 4)
+	res, err = chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 5 || res.Reason != ReasonPrevMismatch {
+		t.Fatalf("unexpected result after deleting first row: %+v", res)
+	}
+
+	// 没有过期记录时不生成文件
+	file, err = archiver.Archive(time.Now().Add(-24 * time.Hour))
+	if err != nil || file != nil {
+		t.Fatalf("expected no archive, got %+v %v", file, err)
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	archiver := NewArchiver(&Chain{}, t.TempDir(), 0)
+	for _, name := range []string{"", "../audit.jsonl.gz", "audit.db", filepath.Join("a", "b.jsonl.gz")} {
+		if _, err := archiver.Path(name); err != ErrInvalidArchive {
+			t.Fatalf("expected invalid archive for %q, got %v", name, err)
+		}
+	}
+}
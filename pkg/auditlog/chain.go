@@ -0,0 +1,393 @@
+// Package auditlog
+// Date: 2024/4/22 09:40
+// Author: Amu
+// Description: 审计日志哈希链，每条记录携带前一条记录的 HMAC，用于发现篡改或删除
+package auditlog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"gorm.io/gorm"
+)
+
+const verifyBatchSize = 1000
+
+// headID 链尾只有一行
+const headID = 1
+
+// recordRetries 多个实例同时写入时链尾被其他实例更新，重新读取链尾后重试的次数
+const recordRetries = 5
+
+var errHeadChanged = errors.New("audit chain head changed")
+
+// 校验失败原因
+const (
+	ReasonHashMismatch = "hash mismatch"
+	ReasonPrevMismatch = "previous hash mismatch"
+	ReasonUnchained    = "missing hash"
+	ReasonDeleted      = "marked as deleted"
+	ReasonTruncated    = "latest records missing"
+	ReasonHeadMismatch = "chain head mismatch"
+	ReasonHeadMissing  = "chain head missing"
+)
+
+// Event 审计事件，写入外部 sink 时使用
+type Event struct {
+	ID         uint   `json:"id"`
+	Time       string `json:"time"`
+	Username   string `json:"username"`
+	Operate    string `json:"operate"`
+	ResourceID string `json:"resource_id,omitempty"`
+	Params     string `json:"params,omitempty"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Status     int    `json:"status"`
+	Duration   int64  `json:"duration"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// hashInput 参与 HMAC 计算的字段，字段顺序固定
+type hashInput struct {
+	Prev       string `json:"prev"`
+	Time       int64  `json:"time"`
+	Username   string `json:"username"`
+	Operate    string `json:"operate"`
+	ResourceID string `json:"resource_id"`
+	Params     string `json:"params"`
+	ClientIP   string `json:"client_ip"`
+	UserAgent  string `json:"user_agent"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Status     int    `json:"status"`
+	Duration   int64  `json:"duration"`
+}
+
+// Sum 计算审计记录的 HMAC-SHA256
+func Sum(key []byte, prev string, a *model.Audit) string {
+	data, _ := json.Marshal(hashInput{
+		Prev:       prev,
+		Time:       a.CreatedAt.Unix(),
+		Username:   a.Username,
+		Operate:    a.Operate,
+		ResourceID: a.ResourceID,
+		Params:     a.Params,
+		ClientIP:   a.ClientIP,
+		UserAgent:  a.UserAgent,
+		Method:     a.Method,
+		Path:       a.Path,
+		Status:     a.Status,
+		Duration:   a.Duration,
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// headSum 计算链尾的 HMAC，防止直接改写链尾掩盖删除的记录
+func headSum(key []byte, h *model.AuditHead) string {
+	// 锚点为空时不参与计算，升级前创建的链尾 HMAC 保持不变
+	data, _ := json.Marshal(struct {
+		Seq        uint64 `json:"seq"`
+		LastID     uint   `json:"last_id"`
+		LastHash   string `json:"last_hash"`
+		AnchorID   uint   `json:"anchor_id,omitempty"`
+		AnchorHash string `json:"anchor_hash,omitempty"`
+	}{h.Seq, h.LastID, h.LastHash, h.AnchorID, h.AnchorHash})
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("head"))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewEvent 将审计记录转换为事件
+func NewEvent(a *model.Audit) *Event {
+	return &Event{
+		ID:         a.ID,
+		Time:       a.CreatedAt.Format(time.RFC3339),
+		Username:   a.Username,
+		Operate:    a.Operate,
+		ResourceID: a.ResourceID,
+		Params:     a.Params,
+		ClientIP:   a.ClientIP,
+		UserAgent:  a.UserAgent,
+		Method:     a.Method,
+		Path:       a.Path,
+		Status:     a.Status,
+		Duration:   a.Duration,
+		PrevHash:   a.PrevHash,
+		Hash:       a.Hash,
+	}
+}
+
+// VerifyResult 哈希链校验结果
+type VerifyResult struct {
+	OK      bool `json:"ok"`
+	Checked int  `json:"checked"`
+	// BrokenID 第一条校验失败的记录
+	BrokenID uint   `json:"broken_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type Chain struct {
+	DB    *database.DB
+	Key   []byte
+	Sinks []Sink
+	mu    sync.Mutex
+}
+
+func NewChain(db *database.DB, key []byte, sinks ...Sink) *Chain {
+	return &Chain{DB: db, Key: key, Sinks: sinks}
+}
+
+// Init 链尾不存在时以最后一条记录初始化，兼容升级前已有的记录
+func (c *Chain) Init() error {
+	return c.DB.RunInTransaction(func(tx *gorm.DB) error {
+		_, err := c.head(tx)
+		return err
+	})
+}
+
+// head 读取链尾，不存在时以最后一条记录创建
+func (c *Chain) head(tx *gorm.DB) (*model.AuditHead, error) {
+	var heads []model.AuditHead
+	if err := tx.Where("id = ?", headID).Limit(1).Find(&heads).Error; err != nil {
+		return nil, err
+	}
+	if len(heads) > 0 {
+		return &heads[0], nil
+	}
+	var last, first model.Audit
+	err := tx.Unscoped().Model(&model.Audit{}).Select("id", "hash").Where("hash <> ?", "").Order("id DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return nil, err
+	}
+	// 升级前已归档过的记录无法再校验，以剩余的第一条记录链接的哈希作为锚点
+	err = tx.Unscoped().Model(&model.Audit{}).Select("id", "prev_hash").Where("hash <> ?", "").Order("id ASC").Limit(1).Find(&first).Error
+	if err != nil {
+		return nil, err
+	}
+	head := &model.AuditHead{ID: headID, LastID: last.ID, LastHash: last.Hash, AnchorHash: first.PrevHash}
+	head.Hash = headSum(c.Key, head)
+	// 其他实例同时创建时主键冲突，重试时读取对方创建的链尾
+	if err := tx.Create(head).Error; err != nil {
+		return nil, fmt.Errorf("%w: %v", errHeadChanged, err)
+	}
+	return head, nil
+}
+
+// appendRecord 链接到链尾并更新链尾，链尾的 Seq 已被其他实例更新时返回 errHeadChanged
+func (c *Chain) appendRecord(tx *gorm.DB, a *model.Audit) error {
+	head, err := c.head(tx)
+	if err != nil {
+		return err
+	}
+	a.PrevHash = head.LastHash
+	a.Hash = Sum(c.Key, a.PrevHash, a)
+	if err := tx.Create(a).Error; err != nil {
+		return err
+	}
+	next := *head
+	next.LastID, next.LastHash = a.ID, a.Hash
+	return c.saveHead(tx, head, &next)
+}
+
+// moveAnchor 将锚点移到最后一条已归档的记录，与删除归档记录在同一个事务中执行
+func (c *Chain) moveAnchor(tx *gorm.DB, id uint, hash string) error {
+	head, err := c.head(tx)
+	if err != nil {
+		return err
+	}
+	next := *head
+	next.AnchorID, next.AnchorHash = id, hash
+	return c.saveHead(tx, head, &next)
+}
+
+// saveHead 以 Seq 作为乐观锁更新链尾，链尾已被其他实例更新时返回 errHeadChanged
+func (c *Chain) saveHead(tx *gorm.DB, head, next *model.AuditHead) error {
+	next.Seq = head.Seq + 1
+	res := tx.Model(&model.AuditHead{}).Where("id = ? AND seq = ?", headID, head.Seq).Updates(map[string]interface{}{
+		"seq":         next.Seq,
+		"last_id":     next.LastID,
+		"last_hash":   next.LastHash,
+		"anchor_id":   next.AnchorID,
+		"anchor_hash": next.AnchorHash,
+		"hash":        headSum(c.Key, next),
+		"updated_at":  time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errHeadChanged
+	}
+	return nil
+}
+
+// transact 在事务中执行 fn，链尾已被其他实例更新时重试
+func (c *Chain) transact(fn func(tx *gorm.DB) error) error {
+	var err error
+	for i := 0; i < recordRetries; i++ {
+		err = c.DB.RunInTransaction(fn)
+		if !errors.Is(err, errHeadChanged) {
+			break
+		}
+	}
+	return err
+}
+
+// Record 写入一条审计记录，并链接到上一条记录；
+// 通过链尾的 Seq 在数据库层面串行化写入，多个实例共用一个库时不会产生分叉
+func (c *Chain) Record(a *model.Audit) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 数据库 datetime 精度不一致，统一截断到秒，保证读回后哈希不变
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	a.CreatedAt = a.CreatedAt.Truncate(time.Second)
+	a.UpdatedAt = a.CreatedAt
+	err := c.transact(func(tx *gorm.DB) error {
+		a.ID = 0
+		return c.appendRecord(tx, a)
+	})
+	if err != nil {
+		return err
+	}
+
+	event := NewEvent(a)
+	for _, sink := range c.Sinks {
+		if err := sink.Write(event); err != nil {
+			slog.Error("write audit sink failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// Verify 按 ID 顺序校验整条哈希链，返回第一处断裂
+// 链起点之前的旧记录(未计算哈希)会被跳过；第一条记录的 PrevHash 视为可信锚点，
+// 归档会删除最早的记录，最新的记录通过链尾校验
+func (c *Chain) Verify(ctx context.Context) (*VerifyResult, error) {
+	res := &VerifyResult{OK: true}
+	// 先读取链尾，校验期间新写入的记录排在链尾之后
+	var heads []model.AuditHead
+	if err := c.DB.WithContext(ctx).Where("id = ?", headID).Limit(1).Find(&heads).Error; err != nil {
+		return nil, err
+	}
+	var head *model.AuditHead
+	if len(heads) > 0 {
+		head = &heads[0]
+		if !hmac.Equal([]byte(head.Hash), []byte(headSum(c.Key, head))) {
+			return res.broken(head.LastID, ReasonHeadMismatch), nil
+		}
+	}
+	var lastID, lastChained uint
+	var prev string
+	started := false
+	// 有链尾时剩余的第一条记录必须链接到最后一条已归档的记录，否则只能信任第一条记录
+	first := head == nil
+	if head != nil {
+		lastID, prev = head.AnchorID, head.AnchorHash
+	}
+	headChecked := head == nil
+	// checkHead 链尾之前的记录必须以链尾记录的 ID 和哈希结束
+	checkHead := func() *VerifyResult {
+		headChecked = true
+		if lastChained < head.LastID {
+			return res.broken(head.LastID, ReasonTruncated)
+		}
+		if lastChained != head.LastID || prev != head.LastHash {
+			return res.broken(lastChained, ReasonHeadMismatch)
+		}
+		return nil
+	}
+	for {
+		var audits model.Audits
+		err := c.DB.WithContext(ctx).Unscoped().Model(&model.Audit{}).
+			Where("id > ?", lastID).Order("id ASC").Limit(verifyBatchSize).
+			Find(&audits).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range audits {
+			a := &audits[i]
+			lastID = a.ID
+			if first {
+				prev = a.PrevHash
+				first = false
+			}
+			if !headChecked && a.ID > head.LastID {
+				if broken := checkHead(); broken != nil {
+					return broken, nil
+				}
+			}
+			if a.Hash == "" {
+				if started {
+					return res.broken(a.ID, ReasonUnchained), nil
+				}
+				continue
+			}
+			started = true
+			lastChained = a.ID
+			res.Checked++
+			if a.PrevHash != prev {
+				return res.broken(a.ID, ReasonPrevMismatch), nil
+			}
+			if !hmac.Equal([]byte(a.Hash), []byte(Sum(c.Key, a.PrevHash, a))) {
+				return res.broken(a.ID, ReasonHashMismatch), nil
+			}
+			if a.DeletedAt.Valid {
+				return res.broken(a.ID, ReasonDeleted), nil
+			}
+			prev = a.Hash
+		}
+		if len(audits) < verifyBatchSize {
+			break
+		}
+	}
+	if head == nil {
+		// 升级后尚未初始化链尾时 Init 会创建链尾，已有链上记录但没有链尾说明链尾被删除
+		if res.Checked > 0 {
+			return res.broken(lastChained, ReasonHeadMissing), nil
+		}
+		return res, nil
+	}
+	if !headChecked {
+		if broken := checkHead(); broken != nil {
+			return broken, nil
+		}
+	}
+	return res, nil
+}
+
+func (r *VerifyResult) broken(id uint, reason string) *VerifyResult {
+	r.OK = false
+	r.BrokenID = id
+	r.Reason = reason
+	return r
+}
+
+// Close 关闭所有 sink
+func (c *Chain) Close() error {
+	var err error
+	for _, sink := range c.Sinks {
+		if e := sink.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+// Package auditlog
+// Date: 2024/4/22 11:02
+// Author: Amu
+// Description:
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestChain(t *testing.T, sinks ...Sink) *Chain {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "audit.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Audit{}, &model.AuditHead{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewChain(&database.DB{DB: db}, []byte("test-key"), sinks...)
+}
+
+func newTestChain(t *testing.T, sinks ...Sink) *Chain {
+	t.Helper()
+	chain := openTestChain(t, sinks...)
+	for _, op := range []string{"登录", "启动容器", "停止容器"} {
+		if err := chain.Record(&model.Audit{Username: "admin", Operate: op, Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chain
+}
+
+func TestVerifyIntact(t *testing.T) {
+	chain := newTestChain(t)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK || res.Checked != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifyEdited(t *testing.T) {
+	chain := newTestChain(t)
+	chain.DB.Exec("UPDATE s_audit SET operate = ? WHERE id = ?", "删除容器", 2)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 2 || res.Reason != ReasonHashMismatch {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifyDeleted(t *testing.T) {
+	chain := newTestChain(t)
+	chain.DB.Exec("DELETE FROM s_audit WHERE id = ?", 2)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 3 || res.Reason != ReasonPrevMismatch {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifyFirstDeleted(t *testing.T) {
+	chain := newTestChain(t)
+	// 删除最早的记录，剩余记录本身的链接完整
+	chain.DB.Exec("DELETE FROM s_audit WHERE id = ?", 1)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 2 || res.Reason != ReasonPrevMismatch {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifySoftDeleted(t *testing.T) {
+	chain := newTestChain(t)
+	chain.DB.Delete(&model.Audit{}, 3)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 3 || res.Reason != ReasonDeleted {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifyTruncated(t *testing.T) {
+	chain := newTestChain(t)
+	// 删除最新的记录，剩余记录本身的链接完整
+	chain.DB.Exec("DELETE FROM s_audit WHERE id >= ?", 2)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 3 || res.Reason != ReasonTruncated {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifyHeadForged(t *testing.T) {
+	chain := newTestChain(t)
+	var last model.Audit
+	chain.DB.First(&last, 2)
+	chain.DB.Exec("DELETE FROM s_audit WHERE id = ?", 3)
+	chain.DB.Exec("UPDATE s_audit_head SET last_id = ?, last_hash = ?", last.ID, last.Hash)
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.Reason != ReasonHeadMismatch {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestVerifyHeadMissing(t *testing.T) {
+	chain := newTestChain(t)
+	chain.DB.Exec("DELETE FROM s_audit WHERE id = ?", 3)
+	chain.DB.Exec("DELETE FROM s_audit_head")
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenID != 2 || res.Reason != ReasonHeadMissing {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRecordHeadChanged(t *testing.T) {
+	chain := newTestChain(t)
+	// 模拟另一个实例在读取链尾之后、更新链尾之前写入了记录
+	fired := false
+	err := chain.DB.Callback().Update().Before("gorm:update").Register("test:advance_head", func(tx *gorm.DB) {
+		if tx.Statement.Table == "s_audit_head" && !fired {
+			fired = true
+			tx.Exec("UPDATE s_audit_head SET seq = seq + 1")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Record(&model.Audit{Username: "admin", Operate: "删除镜像", Status: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if !fired {
+		t.Fatal("expected head update hook to run")
+	}
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第一次写入被回滚，重试后链仍然完整
+	if !res.OK || res.Checked != 4 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newTestChain(t, sink)
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 3 {
+		t.Fatalf("expected 3 lines, got %d", lines)
+	}
+}
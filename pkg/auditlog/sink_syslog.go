@@ -0,0 +1,39 @@
+//go:build !windows && !plan9
+
+// Package auditlog
+// Date: 2024/4/22 10:40
+// Author: Amu
+// Description:
+package auditlog
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+var _ Sink = (*SyslogSink)(nil)
+
+// SyslogSink 将审计事件发送到 syslog，network 为空时使用本地 syslog
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink(network, addr, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_NOTICE|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+func (s *SyslogSink) Write(event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.writer.Notice(string(data))
+}
+
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
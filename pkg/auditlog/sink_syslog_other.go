@@ -0,0 +1,23 @@
+//go:build windows || plan9
+
+// Package auditlog
+// Date: 2024/4/22 10:40
+// Author: Amu
+// Description:
+package auditlog
+
+import "errors"
+
+type SyslogSink struct{}
+
+func NewSyslogSink(network, addr, tag string) (*SyslogSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *SyslogSink) Write(event *Event) error {
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -6,10 +6,45 @@ package auth
 
 import (
 	"errors"
+	"time"
 )
 
 var ErrInvalidToken = errors.New("invalid token")
 
+// APITokenPrefix 长期访问令牌的前缀，用于和 JWT 区分
+const APITokenPrefix = "amp_"
+
+// APITokenInfo 长期访问令牌对应的用户信息
+type APITokenInfo struct {
+	UserID   string
+	Username string
+	IsAdmin  string
+	ReadOnly bool
+}
+
+// Session 令牌存储中的一条记录，存储中只保存令牌的哈希
+type Session struct {
+	TokenHash string
+	UserID    string
+	TokenType string
+	ExpiresAt time.Time
+}
+
+// AuditEntry 一条操作审计记录
+type AuditEntry struct {
+	Username   string
+	Operate    string
+	ResourceID string
+	// Params 已脱敏的请求参数(JSON)
+	Params    string
+	ClientIP  string
+	UserAgent string
+	Method    string
+	Path      string
+	Status    int
+	Duration  time.Duration
+}
+
 type TokenInfo interface {
 	GetAccessToken() string
 	GetRefreshToken() string
@@ -18,7 +53,10 @@ type TokenInfo interface {
 type Auther interface {
 	GenerateToken(userID string, username string, isAdmin string) (TokenInfo, error)
 	DestroyToken(token string) error
+	DestroyUserTokens(userID string) error
+	ListSessions(userID string) ([]Session, error)
 	ParseToken(token string, tokenType string) (string, string, string, error)
+	ParseAPIToken(token string) (*APITokenInfo, error)
 	Release() error
-	RecordAudit(username string, operate string)
+	RecordAudit(entry *AuditEntry)
 }
@@ -0,0 +1,52 @@
+// Package jwtauth
+// Date: 2024/4/19 10:15
+// Author: Amu
+// Description:
+package jwtauth
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/service/model"
+)
+
+// lastUsedInterval 最近使用时间的更新间隔，避免每个请求都写库
+const lastUsedInterval = time.Minute
+
+// ParseAPIToken 校验长期访问令牌，返回令牌所属用户
+func (a *JWTAuth) ParseAPIToken(tokenString string) (*auth.APITokenInfo, error) {
+	if tokenString == "" || a.db == nil {
+		return nil, auth.ErrInvalidToken
+	}
+	var token model.APIToken
+	if err := a.db.Where("token_hash = ?", hash.SHA256String(tokenString)).First(&token).Error; err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(now) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	var user model.User
+	if err := a.db.Where("id = ?", token.UserID).First(&user).Error; err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	if user.Status != 1 {
+		return nil, auth.ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedInterval {
+		if err := a.db.Model(&model.APIToken{}).Where("id = ?", token.ID).Update("last_used_at", now).Error; err != nil {
+			slog.Error("update api token last used failed", "error", err)
+		}
+	}
+	return &auth.APITokenInfo{
+		UserID:   token.UserID,
+		Username: user.Username,
+		IsAdmin:  user.IsAdmin,
+		ReadOnly: token.ReadOnly,
+	}, nil
+}
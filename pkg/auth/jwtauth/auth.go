@@ -6,10 +6,11 @@ package jwtauth
 
 import (
 	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/utils/uuid"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amutool/database"
 	"github.com/golang-jwt/jwt"
-	"strings"
+	"log/slog"
 	"time"
 )
 
@@ -27,48 +28,41 @@ func New(store Storer, db *database.DB, opts ...Option) *JWTAuth {
 	return &JWTAuth{opts: &o, store: store, db: db}
 }
 
-func (a *JWTAuth) generateAccessToken(userID string, username string, isAdmin string) (string, error) {
-	now := time.Now()
-	expiresAt := now.Add(time.Duration(a.opts.expired) * time.Second).Unix()
-
-	token := jwt.NewWithClaims(a.opts.signingMethod, &jwt.StandardClaims{
-		IssuedAt:  now.Unix(),
-		ExpiresAt: expiresAt,
-		NotBefore: now.Unix(),
-		Subject:   userID + "." + username + "." + isAdmin,
-	})
-
-	tokenString, err := token.SignedString(a.opts.signingKey)
-	if err != nil {
-		return "", err
-	}
-
-	err = a.callStore(func(storer Storer) error {
-		return storer.Set(tokenString, time.Duration(a.opts.expired)*time.Second)
-	})
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+// claims 令牌携带的用户信息，各字段单独存放，用户名中可以包含 "."
+type claims struct {
+	jwt.StandardClaims
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	IsAdmin   string `json:"is_admin"`
+	TokenType string `json:"token_type"`
 }
 
-func (a *JWTAuth) generateRefreshToken(userID string, username string, isAdmin string) (string, error) {
+func (a *JWTAuth) generateToken(userID string, username string, isAdmin string, tokenType string, expired int) (string, error) {
 	now := time.Now()
-	expiresAt := now.Add(time.Duration(a.opts.refreshExpired) * time.Second).Unix()
-
-	token := jwt.NewWithClaims(a.opts.signingMethod, &jwt.StandardClaims{
-		IssuedAt:  now.Unix(),
-		ExpiresAt: expiresAt,
-		NotBefore: now.Unix(),
-		Subject:   username + "." + userID + "." + isAdmin,
+	expiresAt := now.Add(time.Duration(expired) * time.Second).Unix()
+
+	token := jwt.NewWithClaims(a.opts.signingMethod, &claims{
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt,
+			NotBefore: now.Unix(),
+			Subject:   userID,
+			// 同一秒内多次登录也生成不同的令牌
+			Id: uuid.MustString(),
+		},
+		UserID:    userID,
+		Username:  username,
+		IsAdmin:   isAdmin,
+		TokenType: tokenType,
 	})
 
 	tokenString, err := token.SignedString(a.opts.signingKey)
 	if err != nil {
 		return "", err
 	}
+
 	err = a.callStore(func(storer Storer) error {
-		return storer.Set(tokenString, time.Duration(a.opts.refreshExpired)*time.Second)
+		return storer.Set(tokenString, userID, tokenType, time.Duration(expired)*time.Second)
 	})
 	if err != nil {
 		return "", err
@@ -78,11 +72,11 @@ func (a *JWTAuth) generateRefreshToken(userID string, username string, isAdmin s
 
 // GenerateToken 生成令牌
 func (a *JWTAuth) GenerateToken(userID string, username string, isAdmin string) (auth.TokenInfo, error) {
-	accessToken, err := a.generateAccessToken(userID, username, isAdmin)
+	accessToken, err := a.generateToken(userID, username, isAdmin, "access_token", a.opts.expired)
 	if err != nil {
 		return nil, err
 	}
-	refreshToken, err := a.generateRefreshToken(userID, username, isAdmin)
+	refreshToken, err := a.generateToken(userID, username, isAdmin, "refresh_token", a.opts.refreshExpired)
 	if err != nil {
 		return nil, err
 	}
@@ -94,13 +88,13 @@ func (a *JWTAuth) GenerateToken(userID string, username string, isAdmin string)
 }
 
 // parseToke 解析令牌
-func (a *JWTAuth) parseToken(tokenString string) (*jwt.StandardClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, a.opts.keyfunc)
+func (a *JWTAuth) parseToken(tokenString string) (*claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &claims{}, a.opts.keyfunc)
 	if err != nil || !token.Valid {
 		return nil, auth.ErrInvalidToken
 	}
 
-	return token.Claims.(*jwt.StandardClaims), nil
+	return token.Claims.(*claims), nil
 }
 
 func (a *JWTAuth) callStore(fn func(Storer) error) error {
@@ -113,11 +107,29 @@ func (a *JWTAuth) callStore(fn func(Storer) error) error {
 // DestroyToken 销毁令牌
 func (a *JWTAuth) DestroyToken(tokenString string) error {
 	return a.callStore(func(storer Storer) error {
-		return storer.Set(tokenString, 1*time.Second)
+		return storer.Delete(tokenString)
+	})
+}
+
+// DestroyUserTokens 销毁用户的全部令牌
+func (a *JWTAuth) DestroyUserTokens(userID string) error {
+	return a.callStore(func(storer Storer) error {
+		return storer.DeleteUser(userID)
 	})
 }
 
-// ParseToken 解析用户 ID, username
+// ListSessions 列出用户未过期的令牌
+func (a *JWTAuth) ListSessions(userID string) ([]auth.Session, error) {
+	var sessions []auth.Session
+	err := a.callStore(func(storer Storer) error {
+		var err error
+		sessions, err = storer.List(userID)
+		return err
+	})
+	return sessions, err
+}
+
+// ParseToken 解析用户 ID, username, isAdmin
 func (a *JWTAuth) ParseToken(tokenString string, tokenType string) (string, string, string, error) {
 	if tokenString == "" {
 		return "", "", "", auth.ErrInvalidToken
@@ -136,19 +148,15 @@ func (a *JWTAuth) ParseToken(tokenString string, tokenType string) (string, stri
 		return "", "", "", err
 	}
 
-	claims, err := a.parseToken(tokenString)
+	c, err := a.parseToken(tokenString)
 	if err != nil {
 		return "", "", "", err
 	}
-
-	switch tokenType {
-	case "access_token":
-		return strings.Split(claims.Subject, ".")[0], strings.Split(claims.Subject, ".")[1], strings.Split(claims.Subject, ".")[2], nil
-	case "refresh_token":
-		return strings.Split(claims.Subject, ".")[1], strings.Split(claims.Subject, ".")[0], strings.Split(claims.Subject, ".")[2], nil
-	default:
+	// 访问令牌和刷新令牌不能混用
+	if c.TokenType != tokenType || c.UserID == "" {
 		return "", "", "", auth.ErrInvalidToken
 	}
+	return c.UserID, c.Username, c.IsAdmin, nil
 }
 
 // Release 释放资源
@@ -158,6 +166,26 @@ func (a *JWTAuth) Release() error {
 	})
 }
 
-func (a *JWTAuth) RecordAudit(username string, operate string) {
-	a.db.Model(&model.Audit{}).Create(&model.Audit{Username: username, Operate: operate})
+func (a *JWTAuth) RecordAudit(entry *auth.AuditEntry) {
+	audit := &model.Audit{
+		Username:   entry.Username,
+		Operate:    entry.Operate,
+		ResourceID: entry.ResourceID,
+		Params:     entry.Params,
+		ClientIP:   entry.ClientIP,
+		UserAgent:  entry.UserAgent,
+		Method:     entry.Method,
+		Path:       entry.Path,
+		Status:     entry.Status,
+		Duration:   entry.Duration.Milliseconds(),
+	}
+	var err error
+	if a.opts.auditChain != nil {
+		err = a.opts.auditChain.Record(audit)
+	} else {
+		err = a.db.Model(&model.Audit{}).Create(audit).Error
+	}
+	if err != nil {
+		slog.Error("record audit failed", "error", err)
+	}
 }
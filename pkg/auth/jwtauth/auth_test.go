@@ -0,0 +1,64 @@
+// Package jwtauth
+// Date: 2024/5/10 16:30
+// Author: Amu
+// Description:
+package jwtauth
+
+import (
+	"testing"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/golang-jwt/jwt"
+	"github.com/patrickmn/go-cache"
+)
+
+func newTestAuth() *JWTAuth {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	return New(&Store{Storage: cache.New(cache.NoExpiration, 0), Prefix: "test_"}, nil,
+		SetSigningMethod(jwt.SigningMethodHS256),
+		SetSigningKey(key),
+		SetKeyfunc(func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, auth.ErrInvalidToken
+			}
+			return key, nil
+		}),
+		SetExpired(60),
+		SetRefreshExpired(120),
+	)
+}
+
+func TestParseTokenDottedUsername(t *testing.T) {
+	a := newTestAuth()
+	// 用户名中的 "." 不能影响用户 ID 和管理员标记
+	info, err := a.GenerateToken("uid-1", "x.1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ token, tokenType string }{
+		{info.GetAccessToken(), "access_token"},
+		{info.GetRefreshToken(), "refresh_token"},
+	} {
+		userID, username, isAdmin, err := a.ParseToken(tc.token, tc.tokenType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID != "uid-1" || username != "x.1" || isAdmin != "0" {
+			t.Fatalf("unexpected %s claims: %q %q %q", tc.tokenType, userID, username, isAdmin)
+		}
+	}
+}
+
+func TestParseTokenType(t *testing.T) {
+	a := newTestAuth()
+	info, err := a.GenerateToken("uid-1", "admin", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := a.ParseToken(info.GetAccessToken(), "refresh_token"); err != auth.ErrInvalidToken {
+		t.Fatalf("access token accepted as refresh token: %v", err)
+	}
+	if _, _, _, err := a.ParseToken(info.GetRefreshToken(), "access_token"); err != auth.ErrInvalidToken {
+		t.Fatalf("refresh token accepted as access token: %v", err)
+	}
+}
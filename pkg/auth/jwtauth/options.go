@@ -5,6 +5,7 @@
 package jwtauth
 
 import (
+	"github.com/amuluze/amprobe/pkg/auditlog"
 	"github.com/amuluze/amprobe/pkg/auth"
 	"github.com/golang-jwt/jwt"
 )
@@ -25,6 +26,7 @@ var defaultOptions = options{
 }
 
 type options struct {
+	auditChain     *auditlog.Chain
 	signingMethod  jwt.SigningMethod
 	signingKey     interface{}
 	keyfunc        jwt.Keyfunc
@@ -64,3 +66,10 @@ func SetRefreshExpired(expired int) Option {
 		o.refreshExpired = expired
 	}
 }
+
+// SetAuditChain 审计记录通过哈希链写入
+func SetAuditChain(chain *auditlog.Chain) Option {
+	return func(o *options) {
+		o.auditChain = chain
+	}
+}
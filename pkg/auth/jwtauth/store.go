@@ -6,20 +6,34 @@ package jwtauth
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
 	"github.com/amuluze/amutool/errors"
 	"github.com/patrickmn/go-cache"
-	"time"
 )
 
+// Storer 令牌存储，只保存令牌的 SHA256，不保存令牌原文
 type Storer interface {
 	// Set 存储令牌数据，并指定过期时间
-	Set(tokenString string, expiration time.Duration) error
+	Set(tokenString string, userID string, tokenType string, expiration time.Duration) error
 	// Check 检查令牌是否存在
 	Check(tokenString string) (bool, error)
+	// Delete 删除令牌
+	Delete(tokenString string) error
+	// DeleteUser 删除用户的全部令牌
+	DeleteUser(userID string) error
+	// List 列出用户未过期的令牌
+	List(userID string) ([]auth.Session, error)
 	// Close 关闭存储
 	Close() error
 }
 
+var _ Storer = (*Store)(nil)
+
+// Store 基于内存的令牌存储，重启后失效
 type Store struct {
 	Storage *cache.Cache
 	Prefix  string
@@ -33,15 +47,47 @@ func (s *Store) Close() error {
 	return nil
 }
 
-func (s *Store) Set(tokenString string, expiration time.Duration) error {
-	s.Storage.Set(s.wrapperKey(tokenString), "1", expiration)
+func (s *Store) Set(tokenString string, userID string, tokenType string, expiration time.Duration) error {
+	tokenHash := hash.SHA256String(tokenString)
+	s.Storage.Set(s.wrapperKey(tokenHash), auth.Session{
+		TokenHash: tokenHash,
+		UserID:    userID,
+		TokenType: tokenType,
+		ExpiresAt: time.Now().Add(expiration),
+	}, expiration)
 	return nil
 }
 
 func (s *Store) Check(key string) (bool, error) {
-	_, found := s.Storage.Get(s.wrapperKey(key))
+	_, found := s.Storage.Get(s.wrapperKey(hash.SHA256String(key)))
 	if found {
 		return found, nil
 	}
 	return false, errors.New("key not found")
 }
+
+func (s *Store) Delete(tokenString string) error {
+	s.Storage.Delete(s.wrapperKey(hash.SHA256String(tokenString)))
+	return nil
+}
+
+func (s *Store) DeleteUser(userID string) error {
+	sessions, _ := s.List(userID)
+	for _, session := range sessions {
+		s.Storage.Delete(s.wrapperKey(session.TokenHash))
+	}
+	return nil
+}
+
+func (s *Store) List(userID string) ([]auth.Session, error) {
+	var sessions []auth.Session
+	for key, item := range s.Storage.Items() {
+		if !strings.HasPrefix(key, s.Prefix) {
+			continue
+		}
+		if session, ok := item.Object.(auth.Session); ok && session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
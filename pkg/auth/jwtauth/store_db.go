@@ -0,0 +1,80 @@
+// Package jwtauth
+// Date: 2024/4/20 14:10
+// Author: Amu
+// Description:
+package jwtauth
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+)
+
+var _ Storer = (*DBStore)(nil)
+
+// DBStore 基于数据库的令牌存储，重启后仍然有效，可供多个实例共享
+type DBStore struct {
+	DB *database.DB
+}
+
+func (s *DBStore) Close() error {
+	return nil
+}
+
+func (s *DBStore) Set(tokenString string, userID string, tokenType string, expiration time.Duration) error {
+	now := time.Now()
+	// 顺带清理已过期的令牌
+	if err := s.DB.Where("expires_at < ?", now).Delete(&model.AuthToken{}).Error; err != nil {
+		slog.Error("clear expired auth token failed", "error", err)
+	}
+	return s.DB.Create(&model.AuthToken{
+		TokenHash: hash.SHA256String(tokenString),
+		UserID:    userID,
+		TokenType: tokenType,
+		ExpiresAt: now.Add(expiration),
+	}).Error
+}
+
+func (s *DBStore) Check(tokenString string) (bool, error) {
+	var count int64
+	err := s.DB.Model(&model.AuthToken{}).
+		Where("token_hash = ? AND expires_at > ?", hash.SHA256String(tokenString), time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *DBStore) Delete(tokenString string) error {
+	return s.DB.Where("token_hash = ?", hash.SHA256String(tokenString)).Delete(&model.AuthToken{}).Error
+}
+
+func (s *DBStore) DeleteUser(userID string) error {
+	return s.DB.Where("user_id = ?", userID).Delete(&model.AuthToken{}).Error
+}
+
+func (s *DBStore) List(userID string) ([]auth.Session, error) {
+	var tokens []model.AuthToken
+	err := s.DB.Model(&model.AuthToken{}).
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]auth.Session, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, auth.Session{
+			TokenHash: t.TokenHash,
+			UserID:    t.UserID,
+			TokenType: t.TokenType,
+			ExpiresAt: t.ExpiresAt,
+		})
+	}
+	return sessions, nil
+}
@@ -0,0 +1,169 @@
+// Package jwtauth
+// Date: 2024/4/20 14:36
+// Author: Amu
+// Description:
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Storer = (*RedisStore)(nil)
+
+// redisTxRetries 写入令牌时索引被并发修改的最大重试次数
+const redisTxRetries = 5
+
+// RedisStore 基于 Redis 的令牌存储
+// 令牌哈希保存在 <prefix><hash>，同时在 <prefix>user_<userID> 有序集合中按过期时间索引
+type RedisStore struct {
+	Client  *redis.Client
+	Prefix  string
+	Timeout time.Duration
+}
+
+func (s *RedisStore) context() (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func (s *RedisStore) tokenKey(tokenHash string) string {
+	return fmt.Sprintf("%s%s", s.Prefix, tokenHash)
+}
+
+func (s *RedisStore) userKey(userID string) string {
+	return fmt.Sprintf("%suser_%s", s.Prefix, userID)
+}
+
+func (s *RedisStore) Close() error {
+	return s.Client.Close()
+}
+
+func (s *RedisStore) Set(tokenString string, userID string, tokenType string, expiration time.Duration) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	tokenHash := hash.SHA256String(tokenString)
+	expiresAt := time.Now().Add(expiration)
+	value, err := json.Marshal(auth.Session{TokenHash: tokenHash, UserID: userID, TokenType: tokenType, ExpiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+	userKey := s.userKey(userID)
+	set := func(tx *redis.Tx) error {
+		// 索引随最晚过期的令牌一起过期，用户不再登录时不会一直保留
+		indexExpiresAt := expiresAt.Unix()
+		latest, err := tx.ZRevRangeWithScores(ctx, userKey, 0, 0).Result()
+		if err != nil {
+			return err
+		}
+		if len(latest) > 0 && int64(latest[0].Score) > indexExpiresAt {
+			indexExpiresAt = int64(latest[0].Score)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, s.tokenKey(tokenHash), value, expiration)
+			pipe.ZAdd(ctx, userKey, redis.Z{Score: float64(expiresAt.Unix()), Member: tokenHash})
+			// 每次写入时清理索引中已过期的成员
+			pipe.ZRemRangeByScore(ctx, userKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+			pipe.ExpireAt(ctx, userKey, time.Unix(indexExpiresAt, 0))
+			return nil
+		})
+		return err
+	}
+	// 同一用户并发登录时索引被其他请求修改，重新读取最晚的过期时间
+	for i := 0; i < redisTxRetries; i++ {
+		err = s.Client.Watch(ctx, set, userKey)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	return err
+}
+
+func (s *RedisStore) Check(tokenString string) (bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	n, err := s.Client.Exists(ctx, s.tokenKey(hash.SHA256String(tokenString))).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *RedisStore) Delete(tokenString string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	tokenHash := hash.SHA256String(tokenString)
+	key := s.tokenKey(tokenHash)
+	value, err := s.Client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var session auth.Session
+	if err := json.Unmarshal(value, &session); err != nil {
+		return err
+	}
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZRem(ctx, s.userKey(session.UserID), tokenHash)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) DeleteUser(userID string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	userKey := s.userKey(userID)
+	members, err := s.Client.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(members)+1)
+	for _, tokenHash := range members {
+		keys = append(keys, s.tokenKey(tokenHash))
+	}
+	keys = append(keys, userKey)
+	return s.Client.Del(ctx, keys...).Err()
+}
+
+func (s *RedisStore) List(userID string) ([]auth.Session, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	members, err := s.Client.ZRangeByScoreWithScores(ctx, s.userKey(userID), &redis.ZRangeBy{Min: "(" + now, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]auth.Session, 0, len(members))
+	for _, m := range members {
+		tokenHash, ok := m.Member.(string)
+		if !ok {
+			continue
+		}
+		value, err := s.Client.Get(ctx, s.tokenKey(tokenHash)).Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var session auth.Session
+		if err := json.Unmarshal(value, &session); err != nil {
+			return nil, err
+		}
+		session.TokenHash = tokenHash
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
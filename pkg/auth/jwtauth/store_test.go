@@ -0,0 +1,128 @@
+// Package jwtauth
+// Date: 2024/5/10 17:00
+// Author: Amu
+// Description:
+package jwtauth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"github.com/patrickmn/go-cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newDBStore(t *testing.T) *DBStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "auth.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.AuthToken{}); err != nil {
+		t.Fatal(err)
+	}
+	return &DBStore{DB: &database.DB{DB: db}}
+}
+
+func newRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+	store := &RedisStore{Client: redis.NewClient(&redis.Options{Addr: mr.Addr()}), Prefix: "amprobe_"}
+	t.Cleanup(func() { _ = store.Close() })
+	return store, mr
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Storer{
+		"memory": func(t *testing.T) Storer {
+			return &Store{Storage: cache.New(cache.NoExpiration, 0), Prefix: "amprobe_"}
+		},
+		"db": func(t *testing.T) Storer { return newDBStore(t) },
+		"redis": func(t *testing.T) Storer {
+			store, _ := newRedisStore(t)
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, newStore(t))
+		})
+	}
+}
+
+func testStore(t *testing.T, store Storer) {
+	for _, token := range []string{"a1", "a2", "b1"} {
+		userID := "alice"
+		if token == "b1" {
+			userID = "bob"
+		}
+		if err := store.Set(token, userID, "access_token", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, _ := store.Check("a1"); !ok {
+		t.Fatal("expected a1 to exist")
+	}
+	if ok, _ := store.Check("unknown"); ok {
+		t.Fatal("expected unknown token to be rejected")
+	}
+
+	sessions, err := store.List("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	// 存储中只有令牌哈希
+	for _, s := range sessions {
+		if s.TokenHash != hash.SHA256String("a1") && s.TokenHash != hash.SHA256String("a2") {
+			t.Fatalf("unexpected session %+v", s)
+		}
+	}
+
+	if err := store.Delete("a1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Check("a1"); ok {
+		t.Fatal("expected a1 to be deleted")
+	}
+	if err := store.DeleteUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Check("a2"); ok {
+		t.Fatal("expected a2 to be deleted")
+	}
+	if sessions, _ := store.List("alice"); len(sessions) != 0 {
+		t.Fatalf("expected no sessions, got %+v", sessions)
+	}
+	if ok, _ := store.Check("b1"); !ok {
+		t.Fatal("expected other users' tokens to be kept")
+	}
+}
+
+func TestRedisStoreIndexExpire(t *testing.T) {
+	store, mr := newRedisStore(t)
+	if err := store.Set("refresh", "alice", "refresh_token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("access", "alice", "access_token", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// 索引的过期时间与最晚过期的令牌一致，不会被较早过期的令牌缩短
+	ttl := mr.TTL(store.userKey("alice"))
+	if ttl < 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("expected user index to expire with the latest token, got %v", ttl)
+	}
+}
@@ -0,0 +1,343 @@
+// Package cgroup
+// Date: 2024/4/30 10:00
+// Author: Amu
+// Description: 直接读取 cgroup v1/v2 文件获取容器 CPU、内存、IO 和进程数，避免逐个调用 Docker stats
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("cgroup not found")
+
+// 查找容器 cgroup 目录时最多遍历的层级，kubepods 的层级最深
+const maxDepth = 5
+
+// v1 中 memory.limit_in_bytes 大于该值视为不限制
+const unlimited = 1 << 62
+
+// Stats 容器 cgroup 累计计数，CPUUsage 单位 ns，MemoryLimit / PIDsLimit 为 0 表示不限制
+type Stats struct {
+	CPUUsage     uint64
+	MemoryUsage  uint64
+	MemoryLimit  uint64
+	IOReadBytes  uint64
+	IOWriteBytes uint64
+	PIDs         uint64
+	PIDsLimit    uint64
+}
+
+// CPUPercent 根据两次采样计算 CPU 使用率，100% 表示占满一个核，与 docker stats 一致
+func CPUPercent(prev, cur Stats, elapsed time.Duration) float64 {
+	if elapsed <= 0 || cur.CPUUsage < prev.CPUUsage {
+		return 0
+	}
+	return float64(cur.CPUUsage-prev.CPUUsage) / float64(elapsed.Nanoseconds()) * 100
+}
+
+// Collector 从 cgroup 挂载点读取容器指标
+type Collector struct {
+	root    string
+	version int
+	mu      sync.Mutex
+	// paths 容器 ID 到 cgroup 相对路径的缓存
+	paths map[string]string
+	// misses 查找失败的容器，容器集合变化前不再遍历目录树
+	misses map[string]struct{}
+	// ids 上一次 SetContainers 的容器集合
+	ids map[string]struct{}
+}
+
+// DefaultRoot 与 gopsutil 一致，通过 HOST_SYS 指定宿主机 /sys 的挂载位置
+func DefaultRoot() string {
+	if p := os.Getenv("HOST_SYS"); p != "" {
+		return filepath.Join(p, "fs/cgroup")
+	}
+	return "/sys/fs/cgroup"
+}
+
+// New root 为 cgroup 挂载点，如 /sys/fs/cgroup，为空时使用 DefaultRoot
+func New(root string) (*Collector, error) {
+	if root == "" {
+		root = DefaultRoot()
+	}
+	c := &Collector{root: root, paths: make(map[string]string), misses: make(map[string]struct{})}
+	switch {
+	case exists(filepath.Join(root, "cgroup.controllers")):
+		c.version = 2
+	case exists(filepath.Join(root, "memory")) && exists(c.controller("cpuacct")):
+		c.version = 1
+	default:
+		return nil, fmt.Errorf("no cgroup hierarchy found under %s", root)
+	}
+	return c, nil
+}
+
+// Version 返回 1 或 2
+func (c *Collector) Version() int {
+	return c.version
+}
+
+// Stats 读取容器的 cgroup 计数，id 为完整的容器 ID
+func (c *Collector) Stats(id string) (Stats, error) {
+	var stats Stats
+	path, err := c.find(id)
+	if err != nil {
+		return stats, err
+	}
+	if c.version == 2 {
+		err = c.readV2(filepath.Join(c.root, path), &stats)
+	} else {
+		err = c.readV1(path, &stats)
+	}
+	if err != nil {
+		// 容器已退出，下次重新查找
+		c.mu.Lock()
+		delete(c.paths, id)
+		c.mu.Unlock()
+	}
+	return stats, err
+}
+
+// SetContainers 设置当前运行的容器，集合变化时清除查找失败的缓存，并清理已退出容器的路径
+func (c *Collector) SetContainers(ids []string) {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := len(set) != len(c.ids)
+	for id := range set {
+		if _, ok := c.ids[id]; !ok {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	c.ids = set
+	c.misses = make(map[string]struct{})
+	for id := range c.paths {
+		if _, ok := set[id]; !ok {
+			delete(c.paths, id)
+		}
+	}
+}
+
+// controller v1 各子系统的挂载目录，cpuacct 可能与 cpu 合并挂载
+func (c *Collector) controller(name string) string {
+	dir := filepath.Join(c.root, name)
+	if name == "cpuacct" && !exists(dir) {
+		return filepath.Join(c.root, "cpu,cpuacct")
+	}
+	return dir
+}
+
+// find 查找容器的 cgroup 相对路径，依次尝试 cgroupfs、systemd 驱动的常见路径，最后遍历目录树
+func (c *Collector) find(id string) (string, error) {
+	c.mu.Lock()
+	path, ok := c.paths[id]
+	_, missed := c.misses[id]
+	c.mu.Unlock()
+	if ok {
+		return path, nil
+	}
+	if missed {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	base := c.root
+	if c.version == 1 {
+		base = c.controller("memory")
+	}
+	candidates := []string{
+		filepath.Join("docker", id),
+		filepath.Join("system.slice", "docker-"+id+".scope"),
+		filepath.Join("machine.slice", "libpod-"+id+".scope"),
+		filepath.Join("libpod_parent", "libpod-"+id),
+	}
+	path = ""
+	for _, candidate := range candidates {
+		if exists(filepath.Join(base, candidate)) {
+			path = candidate
+			break
+		}
+	}
+	if path == "" {
+		path = walk(base, id)
+	}
+	if path == "" {
+		c.mu.Lock()
+		c.misses[id] = struct{}{}
+		c.mu.Unlock()
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	c.mu.Lock()
+	c.paths[id] = path
+	c.mu.Unlock()
+	return path, nil
+}
+
+// walk 在目录树中查找名称包含容器 ID 的目录，如 kubepods 下的 cri-containerd-<id>.scope
+func walk(base, id string) string {
+	found := ""
+	_ = filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(base, path)
+		if rel != "." && strings.Count(rel, string(filepath.Separator)) >= maxDepth {
+			return filepath.SkipDir
+		}
+		if strings.Contains(d.Name(), id) {
+			found = rel
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+func (c *Collector) readV2(dir string, stats *Stats) error {
+	cpu, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	stats.CPUUsage = cpu["usage_usec"] * 1000
+	if stats.MemoryUsage, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		return err
+	}
+	stats.MemoryLimit, _ = readUint(filepath.Join(dir, "memory.max"))
+	// 与 docker stats 一致，扣除可回收的 page cache
+	if mem, err := readKeyValues(filepath.Join(dir, "memory.stat")); err == nil {
+		stats.MemoryUsage = subtract(stats.MemoryUsage, mem["inactive_file"])
+	}
+	if lines, err := readLines(filepath.Join(dir, "io.stat")); err == nil {
+		// 格式: 8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0
+		for _, line := range lines {
+			for _, field := range strings.Fields(line)[1:] {
+				k, v, ok := strings.Cut(field, "=")
+				if !ok {
+					continue
+				}
+				n, _ := strconv.ParseUint(v, 10, 64)
+				switch k {
+				case "rbytes":
+					stats.IOReadBytes += n
+				case "wbytes":
+					stats.IOWriteBytes += n
+				}
+			}
+		}
+	}
+	stats.PIDs, _ = readUint(filepath.Join(dir, "pids.current"))
+	stats.PIDsLimit, _ = readUint(filepath.Join(dir, "pids.max"))
+	return nil
+}
+
+func (c *Collector) readV1(path string, stats *Stats) error {
+	var err error
+	if stats.CPUUsage, err = readUint(filepath.Join(c.controller("cpuacct"), path, "cpuacct.usage")); err != nil {
+		return err
+	}
+	memDir := filepath.Join(c.controller("memory"), path)
+	if stats.MemoryUsage, err = readUint(filepath.Join(memDir, "memory.usage_in_bytes")); err != nil {
+		return err
+	}
+	if stats.MemoryLimit, _ = readUint(filepath.Join(memDir, "memory.limit_in_bytes")); stats.MemoryLimit >= unlimited {
+		stats.MemoryLimit = 0
+	}
+	if mem, err := readKeyValues(filepath.Join(memDir, "memory.stat")); err == nil {
+		stats.MemoryUsage = subtract(stats.MemoryUsage, mem["total_inactive_file"])
+	}
+	if lines, err := readLines(filepath.Join(c.controller("blkio"), path, "blkio.throttle.io_service_bytes")); err == nil {
+		// 格式: 8:0 Read 4096，最后一行为 Total
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			n, _ := strconv.ParseUint(fields[2], 10, 64)
+			switch fields[1] {
+			case "Read":
+				stats.IOReadBytes += n
+			case "Write":
+				stats.IOWriteBytes += n
+			}
+		}
+	}
+	pidsDir := filepath.Join(c.controller("pids"), path)
+	stats.PIDs, _ = readUint(filepath.Join(pidsDir, "pids.current"))
+	stats.PIDsLimit, _ = readUint(filepath.Join(pidsDir, "pids.max"))
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func subtract(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// readUint 读取单值文件，"max" 表示不限制，返回 0
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// readKeyValues 读取 "key value" 格式的文件，如 cpu.stat、memory.stat
+func readKeyValues(path string) (map[string]uint64, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]uint64, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			res[fields[0]] = v
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,175 @@
+// Package cgroup
+// Date: 2024/4/30 10:00
+// Author: Amu
+// Description:
+package cgroup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const containerID = "3f4e5d6c7b8a99887766554433221100ffeeddccbbaa00112233445566778899"
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectorV2(t *testing.T) {
+	root := t.TempDir()
+	dir := "system.slice/docker-" + containerID + ".scope/"
+	writeTree(t, root, map[string]string{
+		"cgroup.controllers":   "cpu io memory pids\n",
+		dir + "cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n",
+		dir + "memory.current": "104857600\n",
+		dir + "memory.max":     "max\n",
+		dir + "memory.stat":    "anon 52428800\nfile 52428800\ninactive_file 4857600\n",
+		dir + "io.stat":        "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+		dir + "pids.current":   "12\n",
+		dir + "pids.max":       "4096\n",
+	})
+	c, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() != 2 {
+		t.Fatalf("expected cgroup v2, got %d", c.Version())
+	}
+	stats, err := c.Stats(containerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{
+		CPUUsage:     2500000000,
+		MemoryUsage:  100000000,
+		IOReadBytes:  5120,
+		IOWriteBytes: 8192,
+		PIDs:         12,
+		PIDsLimit:    4096,
+	}
+	if stats != expected {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if _, err := c.Stats("0000"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestCollectorV1(t *testing.T) {
+	root := t.TempDir()
+	dir := "/docker/" + containerID + "/"
+	writeTree(t, root, map[string]string{
+		"cpu,cpuacct" + dir + "cpuacct.usage":             "1500000000\n",
+		"memory" + dir + "memory.usage_in_bytes":          "209715200\n",
+		"memory" + dir + "memory.limit_in_bytes":          "9223372036854771712\n",
+		"memory" + dir + "memory.stat":                    "cache 10485760\ntotal_inactive_file 9715200\n",
+		"blkio" + dir + "blkio.throttle.io_service_bytes": "8:0 Read 2048\n8:0 Write 1024\n8:0 Sync 3072\n8:0 Total 3072\nTotal 3072\n",
+		"pids" + dir + "pids.current":                     "3\n",
+		"pids" + dir + "pids.max":                         "max\n",
+	})
+	c, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() != 1 {
+		t.Fatalf("expected cgroup v1, got %d", c.Version())
+	}
+	stats, err := c.Stats(containerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{
+		CPUUsage:     1500000000,
+		MemoryUsage:  200000000,
+		IOReadBytes:  2048,
+		IOWriteBytes: 1024,
+		PIDs:         3,
+	}
+	if stats != expected {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCollectorKubepods(t *testing.T) {
+	root := t.TempDir()
+	dir := "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + containerID + ".scope/"
+	writeTree(t, root, map[string]string{
+		"cgroup.controllers":   "cpu memory\n",
+		dir + "cpu.stat":       "usage_usec 1000\n",
+		dir + "memory.current": "4096\n",
+		dir + "memory.max":     "8192\n",
+	})
+	c, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := c.Stats(containerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CPUUsage != 1000000 || stats.MemoryUsage != 4096 || stats.MemoryLimit != 8192 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if path := c.paths[containerID]; !strings.HasPrefix(path, "kubepods.slice") {
+		t.Fatalf("expected cached path, got %q", path)
+	}
+}
+
+func TestCollectorMiss(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"cgroup.controllers": "cpu memory\n"})
+	c, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetContainers([]string{containerID})
+	if _, err := c.Stats(containerID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	// 容器集合不变时不再查找
+	dir := "docker/" + containerID + "/"
+	writeTree(t, root, map[string]string{
+		dir + "cpu.stat":       "usage_usec 1000\n",
+		dir + "memory.current": "4096\n",
+		dir + "memory.max":     "max\n",
+	})
+	c.SetContainers([]string{containerID})
+	if _, err := c.Stats(containerID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected cached miss, got %v", err)
+	}
+	c.SetContainers([]string{containerID, "other"})
+	if _, err := c.Stats(containerID); err != nil {
+		t.Fatalf("expected lookup after container set changed, got %v", err)
+	}
+}
+
+func TestNewWithoutCgroup(t *testing.T) {
+	if _, err := New(t.TempDir()); err == nil {
+		t.Fatal("expected error for empty root")
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	prev := Stats{CPUUsage: 1e9}
+	cur := Stats{CPUUsage: 4e9}
+	if v := CPUPercent(prev, cur, 2*time.Second); v != 150 {
+		t.Fatalf("expected 150%%, got %v", v)
+	}
+	if v := CPUPercent(cur, prev, time.Second); v != 0 {
+		t.Fatalf("expected 0 after restart, got %v", v)
+	}
+}
@@ -0,0 +1,145 @@
+// Package configx
+// Date: 2024/5/9 10:00
+// Author: Amu
+// Description: 配置项枚举、环境变量绑定和敏感字段脱敏，配合 viper 使用
+package configx
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// Redacted 脱敏后的占位值
+const Redacted = "******"
+
+// Key 配置项，Path 为结构体字段路径，如 Auth.SigningKey
+type Key struct {
+	Path string
+	// Name viper 中的键，即小写的 Path，同时作为命令行参数名
+	Name string
+	// Env 环境变量名，如 AMPROBE_AUTH_SIGNINGKEY
+	Env string
+	// Secret 字段带有 secret:"true" 标签
+	Secret bool
+}
+
+// Keys 枚举 cfg 结构体的所有配置项，嵌套结构体展开，切片和 map 作为单个配置项
+func Keys(prefix string, cfg interface{}) []Key {
+	var keys []Key
+	walk(reflect.TypeOf(cfg), nil, func(path []string, field reflect.StructField) {
+		p := strings.Join(path, ".")
+		keys = append(keys, Key{
+			Path:   p,
+			Name:   strings.ToLower(p),
+			Env:    strings.ToUpper(prefix + "_" + strings.Join(path, "_")),
+			Secret: field.Tag.Get("secret") == "true",
+		})
+	})
+	return keys
+}
+
+func walk(t reflect.Type, path []string, fn func(path []string, field reflect.StructField)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		p := append(append([]string(nil), path...), field.Name)
+		if field.Type.Kind() == reflect.Struct {
+			walk(field.Type, p, fn)
+			continue
+		}
+		fn(p, field)
+	}
+}
+
+// BindEnv 为每个配置项绑定环境变量，未出现在配置文件中的配置项同样可以通过环境变量设置
+func BindEnv(v *viper.Viper, prefix string, cfg interface{}) error {
+	for _, key := range Keys(prefix, cfg) {
+		if err := v.BindEnv(key.Name, key.Env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeHook 在 viper 默认转换的基础上，支持以 JSON 设置切片和 map，
+// 如 AMPROBE_EXPORTERS='[{"Type":"prometheus","URL":"http://127.0.0.1:9090/api/v1/write"}]'
+func DecodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		jsonHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+}
+
+// jsonHook 目标为切片或 map 且字符串以 [ 或 { 开头时按 JSON 解析
+func jsonHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || (to.Kind() != reflect.Slice && to.Kind() != reflect.Map) {
+		return data, nil
+	}
+	s := strings.TrimSpace(data.(string))
+	if !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "{") {
+		return data, nil
+	}
+	var res interface{}
+	if err := json.Unmarshal([]byte(s), &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Redact 返回 cfg 的深拷贝，带有 secret:"true" 标签的非空字符串和 map 的值替换为 Redacted
+func Redact[T any](cfg T) T {
+	src := reflect.ValueOf(&cfg).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	redact(dst, src, false)
+	return dst.Interface().(T)
+}
+
+func redact(dst, src reflect.Value, secret bool) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			field := src.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			redact(dst.Field(i), src.Field(i), field.Tag.Get("secret") == "true")
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			redact(dst.Index(i), src.Index(i), secret)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			redact(value, iter.Value(), secret)
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.String:
+		if secret && src.Len() > 0 {
+			dst.SetString(Redacted)
+			return
+		}
+		dst.Set(src)
+	default:
+		dst.Set(src)
+	}
+}
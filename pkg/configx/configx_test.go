@@ -0,0 +1,182 @@
+// Package configx
+// Date: 2024/5/9 10:00
+// Author: Amu
+// Description:
+package configx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+type testTarget struct {
+	URL     string
+	Token   string            `secret:"true"`
+	Headers map[string]string `secret:"true"`
+	Labels  map[string]string
+}
+
+type testConfig struct {
+	Auth struct {
+		Enable     bool
+		SigningKey string `secret:"true"`
+	}
+	Task struct {
+		Interval int
+	}
+	Disk struct {
+		Devices []string
+	}
+	Targets []testTarget
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys("AMPROBE", testConfig{})
+	expected := []Key{
+		{Path: "Auth.Enable", Name: "auth.enable", Env: "AMPROBE_AUTH_ENABLE"},
+		{Path: "Auth.SigningKey", Name: "auth.signingkey", Env: "AMPROBE_AUTH_SIGNINGKEY", Secret: true},
+		{Path: "Task.Interval", Name: "task.interval", Env: "AMPROBE_TASK_INTERVAL"},
+		{Path: "Disk.Devices", Name: "disk.devices", Env: "AMPROBE_DISK_DEVICES"},
+		{Path: "Targets", Name: "targets", Env: "AMPROBE_TARGETS"},
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+}
+
+func TestBindEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	data := "[Auth]\nSigningKey = \"from-file\"\n\n[Task]\nInterval = 60\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AMPROBE_TASK_INTERVAL", "30")
+	t.Setenv("AMPROBE_AUTH_ENABLE", "true")
+	t.Setenv("AMPROBE_DISK_DEVICES", "/dev/sda,/dev/sdb")
+	t.Setenv("AMPROBE_TARGETS", `[{"URL":"http://127.0.0.1:9090","Labels":{"env":"prod"}}]`)
+
+	v := viper.New()
+	if err := BindEnv(v, "AMPROBE", testConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// 命令行参数优先级最高
+	v.Set("auth.signingkey", "from-flag")
+
+	var cfg testConfig
+	if err := v.Unmarshal(&cfg, DecodeHook()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Task.Interval != 30 || !cfg.Auth.Enable || cfg.Auth.SigningKey != "from-flag" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Disk.Devices, []string{"/dev/sda", "/dev/sdb"}) {
+		t.Fatalf("unexpected devices: %v", cfg.Disk.Devices)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].URL != "http://127.0.0.1:9090" || cfg.Targets[0].Labels["env"] != "prod" {
+		t.Fatalf("unexpected targets: %+v", cfg.Targets)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var cfg testConfig
+	cfg.Auth.SigningKey = "secret"
+	cfg.Targets = []testTarget{
+		{URL: "http://a", Token: "t", Headers: map[string]string{"Authorization": "Bearer x"}, Labels: map[string]string{"env": "prod"}},
+		{URL: "http://b"},
+	}
+	redacted := Redact(cfg)
+	if redacted.Auth.SigningKey != Redacted || redacted.Targets[0].Token != Redacted || redacted.Targets[0].Headers["Authorization"] != Redacted {
+		t.Fatalf("secrets not redacted: %+v", redacted)
+	}
+	if redacted.Targets[0].URL != "http://a" || redacted.Targets[0].Labels["env"] != "prod" || redacted.Targets[1].Token != "" {
+		t.Fatalf("unexpected redacted config: %+v", redacted)
+	}
+	// 原配置不受影响
+	if cfg.Auth.SigningKey != "secret" || cfg.Targets[0].Token != "t" || cfg.Targets[0].Headers["Authorization"] != "Bearer x" {
+		t.Fatalf("original config modified: %+v", cfg)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(strings.NewReader("[Task]\nIntervall = 30\n")); err != nil {
+		t.Fatal(err)
+	}
+	var cfg testConfig
+	if err := v.Unmarshal(&cfg, DecodeHook()); err != nil {
+		t.Fatalf("unexpected error without strict: %v", err)
+	}
+	if err := v.Unmarshal(&cfg, DecodeHook(), Strict()); err == nil || !strings.Contains(err.Error(), "intervall") {
+		t.Fatalf("expected unused key error, got %v", err)
+	}
+
+	v.Set("task.interval", "abc")
+	if err := v.Unmarshal(&cfg, DecodeHook()); err == nil {
+		t.Fatal("expected type error")
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, secret := range []string{"", "amprobe", "short-key", strings.Repeat("ab", 20)} {
+		if err := CheckSecret(secret, 32); err == nil {
+			t.Fatalf("expected %q to be rejected", secret)
+		}
+	}
+	if err := CheckSecret("k3J9xQ2mV7pL0sR8tY4wZ6aB1cD5eF0g", 32); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetValues(t *testing.T) {
+	data := `[Disk]
+Devices = ["/dev/disk0"]
+
+[Task]
+Interval = 60 # 单位 s
+
+[Auth]
+# 签名key
+SigningKey = "a#b" # 注释
+
+[[Targets]]
+URL = "http://a"
+`
+	res, err := SetValues([]byte(data), map[string]interface{}{
+		"Disk.Devices":    []string{"/dev/sda", "/dev/sdb"},
+		"Task.Interval":   30,
+		"Auth.SigningKey": "x\"y",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[Disk]
+Devices = ["/dev/sda", "/dev/sdb"]
+
+[Task]
+Interval = 30 # 单位 s
+
+[Auth]
+# 签名key
+SigningKey = "x\"y" # 注释
+
+[[Targets]]
+URL = "http://a"
+`
+	if string(res) != expected {
+		t.Fatalf("unexpected result:\n%s", res)
+	}
+
+	if _, err := SetValues([]byte(data), map[string]interface{}{"Targets.URL": "http://b"}); err == nil {
+		t.Fatal("expected error for key in array of tables")
+	}
+}
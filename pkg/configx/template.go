@@ -0,0 +1,110 @@
+// Package configx
+// Date: 2024/5/10 10:00
+// Author: Amu
+// Description: 修改带注释的 TOML 配置模板
+package configx
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+var (
+	sectionLine = regexp.MustCompile(`^\s*(\[\[?)\s*([A-Za-z0-9_.]+)\s*\]\]?\s*(#.*)?$`)
+	keyLine     = regexp.MustCompile(`^(\s*)([A-Za-z0-9_]+)(\s*=\s*)(.*)$`)
+)
+
+// SetValues 修改 TOML 文本中已有配置项的值，保留注释和其余内容；
+// values 的键为 Section.Key，如 Disk.Devices，值支持 string / []string / bool / int，
+// 只支持单行的值，[[数组]] 中的配置项不支持修改，未找到的配置项返回错误
+func SetValues(data []byte, values map[string]interface{}) ([]byte, error) {
+	found := make(map[string]bool, len(values))
+	lines := strings.Split(string(data), "\n")
+	section := ""
+	for i, line := range lines {
+		if m := sectionLine.FindStringSubmatch(line); m != nil {
+			section = m[2]
+			if m[1] == "[[" {
+				section = ""
+			}
+			continue
+		}
+		m := keyLine.FindStringSubmatch(line)
+		if m == nil || section == "" {
+			continue
+		}
+		key := section + "." + m[2]
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		formatted, err := formatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		_, comment, err := splitComment(m[4])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if comment != "" {
+			comment = " " + comment
+		}
+		lines[i] = m[1] + m[2] + m[3] + formatted + comment
+		found[key] = true
+	}
+	for key := range values {
+		if !found[key] {
+			return nil, fmt.Errorf("%s: not found in template", key)
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// splitComment 拆分值和行尾注释，值中可能包含 #，以能够单独解析的最短前缀作为值
+func splitComment(rest string) (string, string, error) {
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != '#' {
+			continue
+		}
+		value := strings.TrimSpace(rest[:i])
+		if validValue(value) {
+			return value, rest[i:], nil
+		}
+	}
+	value := strings.TrimSpace(rest)
+	if !validValue(value) {
+		return "", "", fmt.Errorf("unsupported value %q", value)
+	}
+	return value, "", nil
+}
+
+func validValue(value string) bool {
+	var v map[string]interface{}
+	return value != "" && toml.Unmarshal([]byte("v = "+value), &v) == nil
+}
+
+// formatValue 字符串使用 JSON 编码，与 TOML 基本字符串兼容
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		data, err := json.Marshal(v)
+		return string(data), err
+	case []string:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, string(data))
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case bool, int:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", value)
+}
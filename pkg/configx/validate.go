@@ -0,0 +1,87 @@
+// Package configx
+// Date: 2024/5/10 10:00
+// Author: Amu
+// Description: 配置校验结果和密钥强度检查
+package configx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+const (
+	LevelError = "error"
+	LevelWarn  = "warn"
+)
+
+// Issue 校验发现的问题，Key 为配置项路径，如 Auth.SigningKey
+type Issue struct {
+	Level   string
+	Key     string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", i.Level, i.Key, i.Message)
+}
+
+// Report 校验结果，存在 error 级别的问题时配置不可用
+type Report struct {
+	Issues []Issue
+}
+
+func (r *Report) Errorf(key, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Level: LevelError, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) Warnf(key, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Level: LevelWarn, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// Count 返回 error 和 warn 级别问题的数量
+func (r *Report) Count() (errs int, warns int) {
+	for _, issue := range r.Issues {
+		if issue.Level == LevelError {
+			errs++
+		} else {
+			warns++
+		}
+	}
+	return errs, warns
+}
+
+// Strict 解析配置时未知的配置项(通常是拼写错误)返回错误
+func Strict() viper.DecoderConfigOption {
+	return func(c *mapstructure.DecoderConfig) {
+		c.ErrorUnused = true
+	}
+}
+
+// 示例配置中常见的弱密钥
+var weakSecrets = map[string]struct{}{
+	"amprobe": {}, "secret": {}, "changeme": {}, "password": {}, "123456": {}, "admin": {},
+}
+
+// CheckSecret 检查密钥强度，HMAC 密钥至少 minLen 字节，且不能是常见弱密钥或由少量字符重复组成
+func CheckSecret(secret string, minLen int) error {
+	if secret == "" {
+		return fmt.Errorf("is empty")
+	}
+	if _, ok := weakSecrets[strings.ToLower(secret)]; ok {
+		return fmt.Errorf("is a well-known default value")
+	}
+	if len(secret) < minLen {
+		return fmt.Errorf("is %d bytes, at least %d bytes are required", len(secret), minLen)
+	}
+	distinct := make(map[rune]struct{})
+	for _, r := range secret {
+		distinct[r] = struct{}{}
+	}
+	if len(distinct) < 8 {
+		return fmt.Errorf("contains only %d distinct characters", len(distinct))
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+// Package containerx
+// Date: 2024/4/30 16:10
+// Author: Amu
+// Description:
+package containerx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+var _ Runtime = (*Containerd)(nil)
+
+// nerdctl 保存容器名称的标签
+const nerdctlNameLabel = "nerdctl/name"
+
+// 停止容器时等待 SIGTERM 生效的时间，超时后发送 SIGKILL
+const stopTimeout = 10 * time.Second
+
+// Containerd 通过 containerd API 管理容器，适用于 nerdctl 创建的容器；
+// containerd 不提供统计和日志接口，统计由调用方读取 cgroup
+type Containerd struct {
+	client    *containerd.Client
+	namespace string
+}
+
+// NewContainerd address 为空时使用 /run/containerd/containerd.sock，namespace 为空时使用 default
+func NewContainerd(address, namespace string) (*Containerd, error) {
+	address = strings.TrimPrefix(address, "unix://")
+	if address == "" {
+		address = "/run/containerd/containerd.sock"
+	}
+	if namespace == "" {
+		namespace = namespaces.Default
+	}
+	client, err := containerd.New(address, containerd.WithDefaultNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+	return &Containerd{client: client, namespace: namespace}, nil
+}
+
+func (c *Containerd) Name() string {
+	return RuntimeContainerd
+}
+
+func (c *Containerd) context(ctx context.Context) context.Context {
+	return namespaces.WithNamespace(ctx, c.namespace)
+}
+
+func (c *Containerd) Version(ctx context.Context) (*Version, error) {
+	v, err := c.client.Version(c.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &Version{
+		DockerVersion: v.Version,
+		GitCommit:     v.Revision,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+	}, nil
+}
+
+// containerState 与 Docker 的状态名称保持一致
+func containerState(status containerd.ProcessStatus) string {
+	switch status {
+	case "":
+		return "created"
+	case containerd.Stopped:
+		return "exited"
+	case containerd.Unknown:
+		return "dead"
+	}
+	return string(status)
+}
+
+// containerName nerdctl 创建的容器使用其名称，否则使用 ID 前 12 位
+func containerName(id string, labels map[string]string) string {
+	if name := labels[nerdctlNameLabel]; name != "" {
+		return name
+	}
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func (c *Containerd) ListContainers(ctx context.Context) ([]Container, error) {
+	ctx = c.context(ctx)
+	list, err := c.client.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Container
+	for _, item := range list {
+		info, err := item.Info(ctx)
+		if err != nil {
+			continue
+		}
+		summary := Container{
+			ID:      info.ID,
+			Name:    containerName(info.ID, info.Labels),
+			Image:   info.Image,
+			Created: info.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			State:   containerState(""),
+		}
+		if task, err := item.Task(ctx, nil); err == nil {
+			if status, err := task.Status(ctx); err == nil {
+				summary.State = containerState(status.Status)
+			}
+			if summary.State == "running" {
+				// 以 init 进程的启动时间作为容器启动时间
+				if p, err := process.NewProcess(int32(task.Pid())); err == nil {
+					if created, err := p.CreateTime(); err == nil {
+						summary.Uptime = time.UnixMilli(created).Format("2006-01-02 15:04:05")
+					}
+				}
+			}
+		}
+		res = append(res, summary)
+	}
+	return res, nil
+}
+
+func (c *Containerd) ContainerStats(ctx context.Context, id string) (Stats, error) {
+	return Stats{}, ErrNotSupported
+}
+
+// load 加载容器，页面和数据库中保存的是 ID 前缀，containerd 只接受完整 ID，精确匹配失败时按前缀查找
+func (c *Containerd) load(ctx context.Context, id string) (containerd.Container, error) {
+	container, err := c.client.LoadContainer(ctx, id)
+	if !errdefs.IsNotFound(err) {
+		return container, err
+	}
+	list, err := c.client.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(list))
+	for _, item := range list {
+		ids = append(ids, item.ID())
+	}
+	full, err := matchID(ids, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.LoadContainer(ctx, full)
+}
+
+// matchID 返回以 prefix 开头的唯一 ID，未找到或匹配多个时返回错误
+func matchID(ids []string, prefix string) (string, error) {
+	var match string
+	for _, id := range ids {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if match != "" {
+			return "", fmt.Errorf("container id prefix %q is ambiguous", prefix)
+		}
+		match = id
+	}
+	if prefix == "" || match == "" {
+		return "", fmt.Errorf("container %q: %w", prefix, errdefs.ErrNotFound)
+	}
+	return match, nil
+}
+
+func (c *Containerd) StartContainer(ctx context.Context, id string) error {
+	ctx = c.context(ctx)
+	container, err := c.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	// 清理已退出的 task
+	if task, err := container.Task(ctx, nil); err == nil {
+		if _, err := task.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	task, err := container.NewTask(ctx, cio.NullIO)
+	if err != nil {
+		return err
+	}
+	return task.Start(ctx)
+}
+
+func (c *Containerd) StopContainer(ctx context.Context, id string) error {
+	ctx = c.context(ctx)
+	container, err := c.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	task, err := container.Task(ctx, nil)
+	if errdefs.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	status, err := task.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Status == containerd.Running || status.Status == containerd.Paused {
+		exitCh, err := task.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		if err := task.Kill(ctx, syscall.SIGTERM); err != nil {
+			return err
+		}
+		select {
+		case <-exitCh:
+		case <-time.After(stopTimeout):
+			if err := task.Kill(ctx, syscall.SIGKILL); err != nil {
+				return err
+			}
+			<-exitCh
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	_, err = task.Delete(ctx)
+	return err
+}
+
+func (c *Containerd) RestartContainer(ctx context.Context, id string) error {
+	if err := c.StopContainer(ctx, id); err != nil {
+		return err
+	}
+	return c.StartContainer(ctx, id)
+}
+
+func (c *Containerd) RemoveContainer(ctx context.Context, id string) error {
+	if err := c.StopContainer(ctx, id); err != nil {
+		return err
+	}
+	ctx = c.context(ctx)
+	container, err := c.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	return container.Delete(ctx, containerd.WithSnapshotCleanup)
+}
+
+func (c *Containerd) ContainerLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	return nil, ErrNotSupported
+}
+
+// splitImageName 拆分镜像名称和标签，如 docker.io/library/nginx:1.25 -> docker.io/library/nginx, 1.25
+func splitImageName(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ref, "latest"
+	}
+	return ref[:i], ref[i+1:]
+}
+
+func (c *Containerd) ListImages(ctx context.Context) ([]Image, error) {
+	ctx = c.context(ctx)
+	list, err := c.client.ImageService().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Image
+	for _, item := range list {
+		// 跳过以 digest 命名的重复记录
+		if strings.Contains(item.Name, "@sha256:") || strings.HasPrefix(item.Name, "sha256:") {
+			continue
+		}
+		name, tag := splitImageName(item.Name)
+		image := Image{
+			ID:      item.Target.Digest.String(),
+			Name:    name,
+			Tag:     tag,
+			Created: item.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		}
+		if size, err := containerd.NewImage(c.client, item).Size(ctx); err == nil {
+			image.Size = strconv.FormatFloat(float64(size)/(1000*1000), 'f', 2, 64) + "MB"
+		}
+		res = append(res, image)
+	}
+	return res, nil
+}
+
+// RemoveImage id 可以是镜像名称或 digest(前缀)，digest 匹配时删除所有引用
+func (c *Containerd) RemoveImage(ctx context.Context, id string) error {
+	ctx = c.context(ctx)
+	store := c.client.ImageService()
+	list, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	var removed bool
+	for _, item := range list {
+		digest := item.Target.Digest.String()
+		if item.Name == id || digest == id || strings.HasPrefix(strings.TrimPrefix(digest, "sha256:"), strings.TrimPrefix(id, "sha256:")) {
+			if err := store.Delete(ctx, item.Name); err != nil {
+				return err
+			}
+			removed = true
+		}
+	}
+	if !removed {
+		return errdefs.ErrNotFound
+	}
+	return nil
+}
+
+// PruneImages 删除没有被任何容器使用的镜像
+func (c *Containerd) PruneImages(ctx context.Context) error {
+	ctx = c.context(ctx)
+	containers, err := c.client.Containers(ctx)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]struct{})
+	for _, item := range containers {
+		if info, err := item.Info(ctx); err == nil {
+			used[info.Image] = struct{}{}
+		}
+	}
+	store := c.client.ImageService()
+	list, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, item := range list {
+		if _, ok := used[item.Name]; ok {
+			continue
+		}
+		if err := store.Delete(ctx, item.Name); err != nil && !errdefs.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Containerd) Close() error {
+	return c.client.Close()
+}
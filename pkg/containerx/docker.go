@@ -0,0 +1,93 @@
+// Package containerx
+// Date: 2024/4/30 15:00
+// Author: Amu
+// Description:
+package containerx
+
+import (
+	"context"
+	"io"
+
+	"github.com/amuluze/amutool/docker"
+	"github.com/docker/docker/client"
+)
+
+var _ Runtime = (*Docker)(nil)
+
+// Docker 通过 Docker Engine API 管理容器，Podman 提供兼容接口，使用同一实现
+type Docker struct {
+	name    string
+	manager *docker.Manager
+}
+
+// NewDocker endpoint 如 unix:///run/podman/podman.sock，为空时读取 DOCKER_HOST 等环境变量
+func NewDocker(name, endpoint string) (*Docker, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if endpoint != "" {
+		opts = append(opts, client.WithHost(endpoint))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Docker{name: name, manager: &docker.Manager{Client: cli}}, nil
+}
+
+func (d *Docker) Name() string {
+	return d.name
+}
+
+func (d *Docker) Version(ctx context.Context) (*Version, error) {
+	return d.manager.Version(ctx)
+}
+
+func (d *Docker) ListContainers(ctx context.Context) ([]Container, error) {
+	return d.manager.ListContainer(ctx)
+}
+
+func (d *Docker) ContainerStats(ctx context.Context, id string) (Stats, error) {
+	var stats Stats
+	cpuPercent, err := d.manager.GetContainerCPU(ctx, id)
+	if err != nil {
+		return stats, err
+	}
+	stats.CPUPercent = cpuPercent
+	stats.MemPercent, stats.MemUsage, stats.MemLimit, err = d.manager.GetContainerMem(ctx, id)
+	return stats, err
+}
+
+func (d *Docker) StartContainer(ctx context.Context, id string) error {
+	return d.manager.StartContainer(ctx, id)
+}
+
+func (d *Docker) StopContainer(ctx context.Context, id string) error {
+	return d.manager.StopContainer(ctx, id)
+}
+
+func (d *Docker) RestartContainer(ctx context.Context, id string) error {
+	return d.manager.RestartContainer(ctx, id)
+}
+
+func (d *Docker) RemoveContainer(ctx context.Context, id string) error {
+	return d.manager.DeleteContainer(ctx, id)
+}
+
+func (d *Docker) ContainerLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	return d.manager.ContainerLogs(ctx, id)
+}
+
+func (d *Docker) ListImages(ctx context.Context) ([]Image, error) {
+	return d.manager.ListImage(ctx)
+}
+
+func (d *Docker) RemoveImage(ctx context.Context, id string) error {
+	return d.manager.RemoveImage(ctx, id)
+}
+
+func (d *Docker) PruneImages(ctx context.Context) error {
+	return d.manager.PruneImages(ctx)
+}
+
+func (d *Docker) Close() error {
+	return d.manager.Client.Close()
+}
@@ -0,0 +1,140 @@
+// Package containerx
+// Date: 2024/4/30 15:00
+// Author: Amu
+// Description: 容器运行时抽象，支持 Docker、Podman (Docker 兼容接口) 和 containerd
+package containerx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/amuluze/amutool/docker"
+)
+
+const (
+	RuntimeDocker     = "docker"
+	RuntimePodman     = "podman"
+	RuntimeContainerd = "containerd"
+)
+
+var ErrNotSupported = errors.New("operation not supported by container runtime")
+
+// 与 Docker 实现共用的数据结构
+type (
+	Container = docker.ContainerSummary
+	Image     = docker.Image
+	Version   = docker.Version
+)
+
+// ShortIDLen 页面和数据库中保存的容器 ID 长度
+const ShortIDLen = 6
+
+// ShortID 返回容器 ID 前 ShortIDLen 位，containerd 容器 ID 可以是任意名称，不足时返回完整 ID
+func ShortID(id string) string {
+	if len(id) > ShortIDLen {
+		return id[:ShortIDLen]
+	}
+	return id
+}
+
+// Stats 容器 CPU 和内存使用，CPUPercent 100% 表示占满一个核
+type Stats struct {
+	CPUPercent float64
+	MemPercent float64
+	MemUsage   float64
+	MemLimit   float64
+}
+
+// Runtime 容器运行时，容器和镜像 ID 均为运行时返回的原始 ID 或其前缀
+type Runtime interface {
+	// Name 返回 docker / podman / containerd
+	Name() string
+	Version(ctx context.Context) (*Version, error)
+	ListContainers(ctx context.Context) ([]Container, error)
+	// ContainerStats 不支持时返回 ErrNotSupported，由调用方读取 cgroup
+	ContainerStats(ctx context.Context, id string) (Stats, error)
+	StartContainer(ctx context.Context, id string) error
+	StopContainer(ctx context.Context, id string) error
+	RestartContainer(ctx context.Context, id string) error
+	RemoveContainer(ctx context.Context, id string) error
+	// ContainerLogs 返回 Docker 多路复用格式的日志流，每帧带 8 字节头
+	ContainerLogs(ctx context.Context, id string) (io.ReadCloser, error)
+	ListImages(ctx context.Context) ([]Image, error)
+	RemoveImage(ctx context.Context, id string) error
+	PruneImages(ctx context.Context) error
+	Close() error
+}
+
+// Options Runtime 为空或 auto 时自动探测，Endpoint 为空时使用各运行时的默认 socket
+type Options struct {
+	Runtime  string
+	Endpoint string
+	// Namespace containerd 命名空间，nerdctl 默认为 default，kubernetes 为 k8s.io
+	Namespace string
+}
+
+// socket 自动探测时依次检查的 socket
+type socket struct {
+	runtime string
+	path    string
+}
+
+func defaultSockets() []socket {
+	sockets := []socket{
+		{RuntimeDocker, "/var/run/docker.sock"},
+		{RuntimePodman, "/run/podman/podman.sock"},
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sockets = append(sockets, socket{RuntimePodman, filepath.Join(dir, "podman", "podman.sock")})
+	}
+	return append(sockets, socket{RuntimeContainerd, "/run/containerd/containerd.sock"})
+}
+
+// Detect 返回探测到的运行时及其 socket 地址，设置了 DOCKER_HOST 或均未找到时使用 Docker 默认配置
+func Detect() (string, string) {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return RuntimeDocker, ""
+	}
+	return detect(defaultSockets())
+}
+
+func detect(sockets []socket) (string, string) {
+	for _, s := range sockets {
+		if _, err := os.Stat(s.path); err == nil {
+			if s.runtime == RuntimeContainerd {
+				return s.runtime, s.path
+			}
+			return s.runtime, "unix://" + s.path
+		}
+	}
+	return RuntimeDocker, ""
+}
+
+func New(opts Options) (Runtime, error) {
+	name := strings.ToLower(opts.Runtime)
+	endpoint := opts.Endpoint
+	if name == "" || name == "auto" {
+		var detected string
+		name, detected = Detect()
+		if endpoint == "" {
+			endpoint = detected
+		}
+	}
+	switch name {
+	case RuntimeDocker:
+		return NewDocker(RuntimeDocker, endpoint)
+	case RuntimePodman:
+		if endpoint == "" {
+			endpoint = "unix:///run/podman/podman.sock"
+		}
+		return NewDocker(RuntimePodman, endpoint)
+	case RuntimeContainerd:
+		return NewContainerd(endpoint, opts.Namespace)
+	}
+	return nil, fmt.Errorf("unknown container runtime %q", opts.Runtime)
+}
@@ -0,0 +1,125 @@
+// Package containerx
+// Date: 2024/4/30 17:00
+// Author: Amu
+// Description:
+package containerx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+)
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+	sockets := []socket{
+		{RuntimeDocker, filepath.Join(root, "docker.sock")},
+		{RuntimePodman, filepath.Join(root, "podman", "podman.sock")},
+		{RuntimeContainerd, filepath.Join(root, "containerd", "containerd.sock")},
+	}
+
+	if name, endpoint := detect(sockets); name != RuntimeDocker || endpoint != "" {
+		t.Fatalf("expected docker defaults, got %s %s", name, endpoint)
+	}
+
+	touch(t, sockets[2].path)
+	if name, endpoint := detect(sockets); name != RuntimeContainerd || endpoint != sockets[2].path {
+		t.Fatalf("expected containerd, got %s %s", name, endpoint)
+	}
+
+	touch(t, sockets[1].path)
+	if name, endpoint := detect(sockets); name != RuntimePodman || endpoint != "unix://"+sockets[1].path {
+		t.Fatalf("expected podman, got %s %s", name, endpoint)
+	}
+
+	touch(t, sockets[0].path)
+	if name, endpoint := detect(sockets); name != RuntimeDocker || endpoint != "unix://"+sockets[0].path {
+		t.Fatalf("expected docker, got %s %s", name, endpoint)
+	}
+}
+
+func TestNewUnknownRuntime(t *testing.T) {
+	if _, err := New(Options{Runtime: "rkt"}); err == nil {
+		t.Fatal("expected error for unknown runtime")
+	}
+}
+
+func TestSplitImageName(t *testing.T) {
+	cases := map[string][2]string{
+		"docker.io/library/nginx:1.25":  {"docker.io/library/nginx", "1.25"},
+		"docker.io/library/redis":       {"docker.io/library/redis", "latest"},
+		"localhost:5000/amprobe":        {"localhost:5000/amprobe", "latest"},
+		"localhost:5000/amprobe:v1.3.0": {"localhost:5000/amprobe", "v1.3.0"},
+		"ghcr.io/a/b:v1@sha256:abcdef":  {"ghcr.io/a/b", "v1"},
+	}
+	for ref, expected := range cases {
+		name, tag := splitImageName(ref)
+		if name != expected[0] || tag != expected[1] {
+			t.Errorf("%s: expected %v, got %s %s", ref, expected, name, tag)
+		}
+	}
+}
+
+func TestContainerState(t *testing.T) {
+	cases := map[containerd.ProcessStatus]string{
+		"":                 "created",
+		containerd.Running: "running",
+		containerd.Paused:  "paused",
+		containerd.Stopped: "exited",
+		containerd.Unknown: "dead",
+	}
+	for status, expected := range cases {
+		if state := containerState(status); state != expected {
+			t.Errorf("%q: expected %s, got %s", status, expected, state)
+		}
+	}
+}
+
+func TestContainerName(t *testing.T) {
+	id := "0123456789abcdef0123456789abcdef"
+	if name := containerName(id, map[string]string{nerdctlNameLabel: "web"}); name != "web" {
+		t.Fatalf("expected nerdctl name, got %s", name)
+	}
+	if name := containerName(id, nil); name != id[:12] {
+		t.Fatalf("expected short id, got %s", name)
+	}
+}
+
+func TestShortID(t *testing.T) {
+	if id := ShortID("0123456789abcdef"); id != "012345" {
+		t.Fatalf("unexpected short id %s", id)
+	}
+	// ctr run 可以使用任意名称作为容器 ID
+	if id := ShortID("web"); id != "web" {
+		t.Fatalf("unexpected short id %s", id)
+	}
+}
+
+func TestMatchID(t *testing.T) {
+	ids := []string{"0123456789abcdef", "01ffffffffffffff", "web"}
+	if id, err := matchID(ids, "012345"); err != nil || id != ids[0] {
+		t.Fatalf("unexpected match %s: %v", id, err)
+	}
+	if id, err := matchID(ids, "web"); err != nil || id != "web" {
+		t.Fatalf("unexpected match %s: %v", id, err)
+	}
+	if _, err := matchID(ids, "01"); err == nil {
+		t.Fatal("expected ambiguous prefix error")
+	}
+	if _, err := matchID(ids, "abc"); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -7,8 +7,10 @@ package contextx
 import "context"
 
 type (
-	userIDCtx   struct{}
-	usernameCtx struct{}
+	userIDCtx    struct{}
+	usernameCtx  struct{}
+	clientIPCtx  struct{}
+	userAgentCtx struct{}
 )
 
 func NewUserID(ctx context.Context, userID string) context.Context {
@@ -38,3 +40,31 @@ func FromUsername(ctx context.Context) string {
 	}
 	return ""
 }
+
+func NewClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPCtx{}, ip)
+}
+
+func FromClientIP(ctx context.Context) string {
+	v := ctx.Value(clientIPCtx{})
+	if v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func NewUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentCtx{}, userAgent)
+}
+
+func FromUserAgent(ctx context.Context) string {
+	v := ctx.Value(userAgentCtx{})
+	if v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
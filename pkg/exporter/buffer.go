@@ -0,0 +1,128 @@
+// Package exporter
+// Date: 2024/4/26 10:40
+// Author: Amu
+// Description:
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+)
+
+const bufferExt = ".batch"
+
+type bufferFile struct {
+	seq  uint64
+	size int64
+}
+
+// diskBuffer 远端不可用时暂存批次，每个批次一个文件，按序号先进先出
+type diskBuffer struct {
+	dir      string
+	maxBytes int64
+	files    []bufferFile
+	size     int64
+	nextSeq  uint64
+}
+
+func openDiskBuffer(dir string, maxBytes int64) (*diskBuffer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	b := &diskBuffer{dir: dir, maxBytes: maxBytes}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, bufferExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, bufferExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		b.files = append(b.files, bufferFile{seq: seq, size: info.Size()})
+		b.size += info.Size()
+		if seq >= b.nextSeq {
+			b.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(b.files, func(i, j int) bool { return b.files[i].seq < b.files[j].seq })
+	return b, nil
+}
+
+func (b *diskBuffer) path(seq uint64) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%020d%s", seq, bufferExt))
+}
+
+func (b *diskBuffer) Len() int {
+	return len(b.files)
+}
+
+// Push 写入一个批次，超出容量时删除最旧的批次
+func (b *diskBuffer) Push(batch []metricstore.Sample) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	seq := b.nextSeq
+	tmp := b.path(seq) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, b.path(seq)); err != nil {
+		return err
+	}
+	b.nextSeq++
+	b.files = append(b.files, bufferFile{seq: seq, size: int64(len(data))})
+	b.size += int64(len(data))
+	for b.size > b.maxBytes && len(b.files) > 1 {
+		if err := b.Pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Peek 读取最旧的批次
+func (b *diskBuffer) Peek() ([]metricstore.Sample, error) {
+	if len(b.files) == 0 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(b.path(b.files[0].seq))
+	if err != nil {
+		return nil, err
+	}
+	var batch []metricstore.Sample
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// Pop 删除最旧的批次
+func (b *diskBuffer) Pop() error {
+	if len(b.files) == 0 {
+		return nil
+	}
+	f := b.files[0]
+	b.files = b.files[1:]
+	b.size -= f.size
+	if err := os.Remove(b.path(f.seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+// Package exporter
+// Date: 2024/4/26 10:10
+// Author: Amu
+// Description: 将每轮采集的指标推送到远端时序库，支持批量发送、失败重试和磁盘缓冲
+package exporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+)
+
+// Client 远端写入协议的实现，实现 io.Closer 时随 Exporter 一起关闭
+type Client interface {
+	Name() string
+	// Send 发送一个批次，返回 RecoverableError 时该批次会被重试
+	Send(ctx context.Context, samples []metricstore.Sample) error
+}
+
+// RecoverableError 远端暂不可用(网络错误、429、5xx)，可以稍后重试
+type RecoverableError struct {
+	Err error
+}
+
+func (e RecoverableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e RecoverableError) Unwrap() error {
+	return e.Err
+}
+
+func isRecoverable(err error) bool {
+	var re RecoverableError
+	return errors.As(err, &re)
+}
+
+type Options struct {
+	// BatchSize 单次发送的最大采样数
+	BatchSize int
+	// FlushInterval 未攒满批次时的最长等待时间
+	FlushInterval time.Duration
+	// QueueSize 内存中等待重试的最大批次数，超出后写入磁盘缓冲
+	QueueSize int
+	// BufferDir 磁盘缓冲目录，为空时超出 QueueSize 的批次直接丢弃
+	BufferDir string
+	// BufferMaxBytes 磁盘缓冲上限，超出后删除最旧的批次
+	BufferMaxBytes int64
+	// Timeout 单次发送超时
+	Timeout time.Duration
+	// MinBackoff / MaxBackoff 失败重试的退避时间范围
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Labels 附加到每个采样的标签，不覆盖采样自带的同名标签
+	Labels metricstore.Labels
+}
+
+func (o *Options) setDefaults() {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 1000
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	if o.BufferMaxBytes <= 0 {
+		o.BufferMaxBytes = 100 << 20
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = time.Minute
+		if o.MaxBackoff < o.MinBackoff {
+			o.MaxBackoff = o.MinBackoff
+		}
+	}
+}
+
+// Exporter 异步推送采样，Export 不会阻塞采集流程
+type Exporter struct {
+	client Client
+	opts   Options
+	buffer *diskBuffer
+
+	in      chan []metricstore.Sample
+	pending []metricstore.Sample
+	queue   [][]metricstore.Sample
+	backoff time.Duration
+	retryAt time.Time
+
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	closeOnce sync.Once
+}
+
+func New(client Client, opts Options) (*Exporter, error) {
+	opts.setDefaults()
+	e := &Exporter{
+		client: client,
+		opts:   opts,
+		in:     make(chan []metricstore.Sample, 64),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	if opts.BufferDir != "" {
+		buffer, err := openDiskBuffer(opts.BufferDir, opts.BufferMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		e.buffer = buffer
+	}
+	go e.run()
+	return e, nil
+}
+
+// Export 提交一轮采集的采样，通道已满时丢弃并记录日志
+func (e *Exporter) Export(samples []metricstore.Sample) {
+	if len(samples) == 0 {
+		return
+	}
+	select {
+	case <-e.stopCh:
+		return
+	default:
+	}
+	select {
+	case e.in <- e.withLabels(samples):
+	default:
+		slog.Warn("exporter input is full, dropping samples", "exporter", e.client.Name(), "count", len(samples))
+	}
+}
+
+func (e *Exporter) withLabels(samples []metricstore.Sample) []metricstore.Sample {
+	res := make([]metricstore.Sample, len(samples))
+	for i, s := range samples {
+		if len(e.opts.Labels) > 0 {
+			labels := make(metricstore.Labels, len(s.Labels)+len(e.opts.Labels))
+			for k, v := range e.opts.Labels {
+				labels[k] = v
+			}
+			for k, v := range s.Labels {
+				labels[k] = v
+			}
+			s.Labels = labels
+		}
+		res[i] = s
+	}
+	return res
+}
+
+// Close 停止推送，尝试发送剩余数据，未发送成功的批次写入磁盘缓冲
+func (e *Exporter) Close() error {
+	e.closeOnce.Do(func() { close(e.stopCh) })
+	<-e.doneCh
+	if closer, ok := e.client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (e *Exporter) run() {
+	defer close(e.doneCh)
+	flushTicker := time.NewTicker(e.opts.FlushInterval)
+	defer flushTicker.Stop()
+	retryTimer := time.NewTimer(0)
+	defer retryTimer.Stop()
+
+	for {
+		select {
+		case samples := <-e.in:
+			e.add(samples)
+		case <-flushTicker.C:
+			e.flushPending()
+		case <-retryTimer.C:
+		case <-e.stopCh:
+			e.shutdown()
+			return
+		}
+		if wait := e.drain(); wait > 0 {
+			if !retryTimer.Stop() {
+				select {
+				case <-retryTimer.C:
+				default:
+				}
+			}
+			retryTimer.Reset(wait)
+		}
+	}
+}
+
+// add 追加到当前批次，攒满 BatchSize 后加入发送队列
+func (e *Exporter) add(samples []metricstore.Sample) {
+	e.pending = append(e.pending, samples...)
+	for len(e.pending) >= e.opts.BatchSize {
+		e.enqueue(e.pending[:e.opts.BatchSize:e.opts.BatchSize])
+		e.pending = e.pending[e.opts.BatchSize:]
+	}
+}
+
+func (e *Exporter) flushPending() {
+	if len(e.pending) > 0 {
+		e.enqueue(e.pending)
+		e.pending = nil
+	}
+}
+
+// enqueue 加入内存队列，超出 QueueSize 时将最旧的批次移入磁盘缓冲
+func (e *Exporter) enqueue(batch []metricstore.Sample) {
+	e.queue = append(e.queue, batch)
+	for len(e.queue) > e.opts.QueueSize {
+		e.spill(e.queue[0])
+		e.queue = e.queue[1:]
+	}
+}
+
+func (e *Exporter) spill(batch []metricstore.Sample) {
+	if e.buffer == nil {
+		slog.Warn("exporter queue is full, dropping batch", "exporter", e.client.Name(), "count", len(batch))
+		return
+	}
+	if err := e.buffer.Push(batch); err != nil {
+		slog.Error("failed to write exporter buffer", "exporter", e.client.Name(), "error", err)
+	}
+}
+
+// next 取出最旧的待发送批次，磁盘缓冲中的数据早于内存队列
+func (e *Exporter) next() ([]metricstore.Sample, bool) {
+	if e.buffer != nil {
+		for e.buffer.Len() > 0 {
+			batch, err := e.buffer.Peek()
+			if err == nil {
+				return batch, true
+			}
+			slog.Error("dropping unreadable exporter buffer", "exporter", e.client.Name(), "error", err)
+			_ = e.buffer.Pop()
+		}
+	}
+	if len(e.queue) > 0 {
+		return e.queue[0], true
+	}
+	return nil, false
+}
+
+func (e *Exporter) pop() {
+	if e.buffer != nil && e.buffer.Len() > 0 {
+		if err := e.buffer.Pop(); err != nil {
+			slog.Error("failed to remove exporter buffer", "exporter", e.client.Name(), "error", err)
+		}
+		return
+	}
+	e.queue = e.queue[1:]
+}
+
+// drain 依次发送待发送批次，远端不可用时返回距下次重试的等待时间
+func (e *Exporter) drain() time.Duration {
+	if wait := time.Until(e.retryAt); wait > 0 {
+		return wait
+	}
+	for {
+		batch, ok := e.next()
+		if !ok {
+			return 0
+		}
+		err := e.send(batch)
+		if err == nil {
+			e.backoff = 0
+			e.pop()
+			continue
+		}
+		if !isRecoverable(err) {
+			slog.Error("exporter rejected batch", "exporter", e.client.Name(), "count", len(batch), "error", err)
+			e.pop()
+			continue
+		}
+		if e.backoff == 0 {
+			e.backoff = e.opts.MinBackoff
+		} else if e.backoff *= 2; e.backoff > e.opts.MaxBackoff {
+			e.backoff = e.opts.MaxBackoff
+		}
+		e.retryAt = time.Now().Add(e.backoff)
+		slog.Warn("exporter send failed, will retry", "exporter", e.client.Name(), "backoff", e.backoff, "error", err)
+		return e.backoff
+	}
+}
+
+func (e *Exporter) send(batch []metricstore.Sample) error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.opts.Timeout)
+	defer cancel()
+	return e.client.Send(ctx, batch)
+}
+
+// shutdown 发送剩余数据一次，失败的批次写入磁盘缓冲
+func (e *Exporter) shutdown() {
+	for len(e.in) > 0 {
+		e.add(<-e.in)
+	}
+	e.flushPending()
+	e.retryAt = time.Time{}
+	e.drain()
+	for _, batch := range e.queue {
+		e.spill(batch)
+	}
+	e.queue = nil
+}
+
+// Exporters 多个推送目标
+type Exporters []*Exporter
+
+func (es Exporters) Export(samples []metricstore.Sample) {
+	for _, e := range es {
+		e.Export(samples)
+	}
+}
+
+func (es Exporters) Close() error {
+	var errs []error
+	for _, e := range es {
+		errs = append(errs, e.Close())
+	}
+	return errors.Join(errs...)
+}
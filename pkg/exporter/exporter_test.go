@@ -0,0 +1,340 @@
+// Package exporter
+// Date: 2024/4/26 15:00
+// Author: Amu
+// Description:
+package exporter
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type decodedSeries struct {
+	labels  map[string]string
+	samples []metricstore.Point
+}
+
+// decodeWriteRequest 解析 remote-write 请求体，用于校验编码结果
+func decodeWriteRequest(t *testing.T, data []byte) []decodedSeries {
+	t.Helper()
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			b = b[n:]
+			n = fn(num, typ, b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+	var res []decodedSeries
+	fields(data, func(_ protowire.Number, _ protowire.Type, b []byte) int {
+		ts, n := protowire.ConsumeBytes(b)
+		series := decodedSeries{labels: map[string]string{}}
+		fields(ts, func(num protowire.Number, _ protowire.Type, b []byte) int {
+			msg, n := protowire.ConsumeBytes(b)
+			switch num {
+			case 1:
+				var name, value string
+				fields(msg, func(num protowire.Number, _ protowire.Type, b []byte) int {
+					v, n := protowire.ConsumeString(b)
+					if num == 1 {
+						name = v
+					} else {
+						value = v
+					}
+					return n
+				})
+				series.labels[name] = value
+			case 2:
+				var p metricstore.Point
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if num == 1 {
+						v, n := protowire.ConsumeFixed64(b)
+						p.Value = math.Float64frombits(v)
+						return n
+					}
+					v, n := protowire.ConsumeVarint(b)
+					p.Timestamp = time.UnixMilli(int64(v))
+					return n
+				})
+				series.samples = append(series.samples, p)
+			}
+			return n
+		})
+		res = append(res, series)
+		return n
+	})
+	return res
+}
+
+func TestRemoteWrite(t *testing.T) {
+	ts := time.UnixMilli(1714100000123)
+	var series []decodedSeries
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		series = decodeWriteRequest(t, data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewRemoteWriteClient(HTTPConfig{URL: srv.URL, Token: "secret"})
+	err := client.Send(context.Background(), []metricstore.Sample{
+		{Metric: "cpu_percent", Timestamp: ts, Value: 12.5},
+		{Metric: "disk-read", Labels: metricstore.Labels{"device": "sda"}, Timestamp: ts, Value: 1},
+		{Metric: "cpu_percent", Timestamp: ts.Add(time.Second), Value: 13},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(series))
+	}
+	if series[0].labels["__name__"] != "cpu_percent" || len(series[0].samples) != 2 || series[0].samples[1].Value != 13 {
+		t.Fatalf("unexpected cpu series: %+v", series[0])
+	}
+	if !series[0].samples[0].Timestamp.Equal(ts) {
+		t.Fatalf("unexpected timestamp: %v", series[0].samples[0].Timestamp)
+	}
+	if series[1].labels["__name__"] != "disk_read" || series[1].labels["device"] != "sda" {
+		t.Fatalf("unexpected disk series: %+v", series[1])
+	}
+}
+
+func TestInfluxLines(t *testing.T) {
+	var body, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, auth = string(data), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ts := time.Unix(1714100000, 5)
+	client := NewInfluxClient(HTTPConfig{URL: srv.URL + "/api/v2/write?org=o&bucket=b", Token: "secret"})
+	err := client.Send(context.Background(), []metricstore.Sample{
+		{Metric: "net_recv", Labels: metricstore.Labels{"ethernet": "eth 0", "host": "a,b"}, Timestamp: ts, Value: 1024},
+		{Metric: "cpu_percent", Timestamp: ts, Value: 0.5},
+		{Metric: "cpu_percent", Timestamp: ts, Value: math.NaN()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "net_recv,ethernet=eth\\ 0,host=a\\,b value=1024 1714100000000000005\ncpu_percent value=0.5 1714100000000000005\n"
+	if body != want {
+		t.Fatalf("unexpected body:\n%q\nwant:\n%q", body, want)
+	}
+	if auth != "Token secret" {
+		t.Fatalf("unexpected auth header: %q", auth)
+	}
+}
+
+// recorder 记录成功写入的采样，down 为 true 时返回 503
+type recorder struct {
+	mu      sync.Mutex
+	down    atomic.Bool
+	status  int
+	samples []metricstore.Sample
+	calls   int
+}
+
+func (r *recorder) Name() string {
+	return "recorder"
+}
+
+func (r *recorder) Send(_ context.Context, samples []metricstore.Sample) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.down.Load() {
+		return RecoverableError{Err: io.ErrUnexpectedEOF}
+	}
+	if r.status != 0 {
+		return io.ErrClosedPipe
+	}
+	r.samples = append(r.samples, samples...)
+	return nil
+}
+
+func (r *recorder) received() []metricstore.Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]metricstore.Sample(nil), r.samples...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func cycle(i int) []metricstore.Sample {
+	ts := time.Unix(1714100000+int64(i), 0)
+	return []metricstore.Sample{
+		{Metric: "cpu_percent", Timestamp: ts, Value: float64(i)},
+		{Metric: "mem_percent", Timestamp: ts, Value: float64(i)},
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	rec := &recorder{}
+	rec.down.Store(true)
+	e, err := New(rec, Options{BatchSize: 2, FlushInterval: time.Hour, MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	for i := 0; i < 3; i++ {
+		e.Export(cycle(i))
+	}
+	waitFor(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.calls >= 3
+	})
+	rec.down.Store(false)
+	waitFor(t, func() bool { return len(rec.received()) == 6 })
+	for i, s := range rec.received() {
+		if s.Value != float64(i/2) {
+			t.Fatalf("samples out of order: %+v", rec.received())
+		}
+	}
+}
+
+func TestDiskBuffer(t *testing.T) {
+	dir := t.TempDir()
+	rec := &recorder{}
+	rec.down.Store(true)
+	opts := Options{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		QueueSize:     1,
+		BufferDir:     dir,
+		MinBackoff:    time.Hour,
+		Labels:        metricstore.Labels{"instance": "node1"},
+	}
+	e, err := New(rec, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		e.Export(cycle(i))
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 buffered batches, got %d", len(entries))
+	}
+
+	// 远端恢复后，重启的 Exporter 先发送磁盘中的数据
+	rec.down.Store(false)
+	opts.MinBackoff = 10 * time.Millisecond
+	e, err = New(rec, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.Export(cycle(3))
+	waitFor(t, func() bool { return len(rec.received()) == 8 })
+	for i, s := range rec.received() {
+		if s.Value != float64(i/2) || s.Labels["instance"] != "node1" {
+			t.Fatalf("unexpected buffered samples: %+v", rec.received())
+		}
+	}
+	waitFor(t, func() bool {
+		entries, _ := os.ReadDir(dir)
+		return len(entries) == 0
+	})
+}
+
+func TestDiskBufferLimit(t *testing.T) {
+	b, err := openDiskBuffer(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := b.Push(cycle(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.Len() != 1 {
+		t.Fatalf("expected oldest batches to be dropped, got %d", b.Len())
+	}
+	batch, err := b.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Value != 2 {
+		t.Fatalf("expected newest batch, got %+v", batch)
+	}
+}
+
+func TestRejectedBatchDropped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	err := NewInfluxClient(HTTPConfig{URL: srv.URL}).Send(context.Background(), cycle(0))
+	if err == nil || isRecoverable(err) {
+		t.Fatalf("expected non-recoverable error, got %v", err)
+	}
+
+	srv503 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv503.Close()
+	err = NewRemoteWriteClient(HTTPConfig{URL: srv503.URL}).Send(context.Background(), cycle(0))
+	if !isRecoverable(err) {
+		t.Fatalf("expected recoverable error, got %v", err)
+	}
+
+	rec := &recorder{status: http.StatusBadRequest}
+	e, err := New(rec, Options{BatchSize: 2, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Export(cycle(0))
+	waitFor(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.calls == 1
+	})
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.calls != 1 {
+		t.Fatalf("rejected batch should not be retried, got %d calls", rec.calls)
+	}
+}
@@ -0,0 +1,78 @@
+// Package exporter
+// Date: 2024/4/26 11:05
+// Author: Amu
+// Description:
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPConfig 远端 HTTP 写入地址及认证信息
+type HTTPConfig struct {
+	URL string
+	// Username / Password 使用 Basic 认证
+	Username string
+	Password string
+	// Token Prometheus 兼容端使用 Bearer 认证，InfluxDB 使用 Token 认证
+	Token   string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+type httpClient struct {
+	config     HTTPConfig
+	authScheme string
+	client     *http.Client
+}
+
+func newHTTPClient(config HTTPConfig, authScheme string) *httpClient {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &httpClient{
+		config:     config,
+		authScheme: authScheme,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// post 发送请求体，网络错误、429 和 5xx 返回 RecoverableError
+func (c *httpClient) post(ctx context.Context, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", c.authScheme+" "+c.config.Token)
+	} else if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return RecoverableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote write %s returned %s: %s", c.config.URL, resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return RecoverableError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+// Package exporter
+// Date: 2024/4/26 14:00
+// Author: Amu
+// Description: InfluxDB line protocol，URL 需包含库信息，如 v1 的 /write?db=amprobe 或 v2 的 /api/v2/write?org=x&bucket=y
+package exporter
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+)
+
+var _ Client = (*InfluxClient)(nil)
+
+type InfluxClient struct {
+	http *httpClient
+}
+
+func NewInfluxClient(config HTTPConfig) *InfluxClient {
+	return &InfluxClient{http: newHTTPClient(config, "Token")}
+}
+
+func (c *InfluxClient) Name() string {
+	return "influxdb"
+}
+
+func (c *InfluxClient) Send(ctx context.Context, samples []metricstore.Sample) error {
+	return c.http.post(ctx, encodeLines(samples), map[string]string{
+		"Content-Type": "text/plain; charset=utf-8",
+	})
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// encodeLines 每个采样一行: <metric>[,<tag>=<value>...] value=<float> <ns>
+func encodeLines(samples []metricstore.Sample) []byte {
+	var b strings.Builder
+	for _, s := range samples {
+		if s.Metric == "" || math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		b.WriteString(measurementEscaper.Replace(s.Metric))
+		keys := make([]string, 0, len(s.Labels))
+		for k := range s.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := s.Labels[k]
+			// 空标签值在行协议中不合法
+			if k == "" || v == "" {
+				continue
+			}
+			b.WriteByte(',')
+			b.WriteString(tagEscaper.Replace(k))
+			b.WriteByte('=')
+			b.WriteString(tagEscaper.Replace(v))
+		}
+		b.WriteString(" value=")
+		b.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(s.Timestamp.UnixNano(), 10))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,272 @@
+// Package exporter
+// Date: 2024/4/27 10:20
+// Author: Amu
+// Description: OpenTelemetry OTLP 指标推送 (HTTP / gRPC)，指标名称遵循 OTel 语义约定
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/amuluze/amprobe/service/model"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const scopeName = "github.com/amuluze/amprobe"
+
+// otelMetric 内部指标到 OTel 语义约定的映射
+type otelMetric struct {
+	name string
+	unit string
+	// scale 数值换算系数，如百分比转换为 0-1 的比例
+	scale float64
+	// attrs 固定附加的属性
+	attrs map[string]string
+	// labels 采样标签到属性名的映射
+	labels map[string]string
+}
+
+// 磁盘和网络为按秒计算的速率，以 Gauge 上报
+var otelMetrics = map[string]otelMetric{
+	model.MetricCPUPercent:      {name: "system.cpu.utilization", unit: "1", scale: 0.01},
+	model.MetricMemoryPercent:   {name: "system.memory.utilization", unit: "1", scale: 0.01, attrs: map[string]string{"state": "used"}},
+	model.MetricMemoryUsed:      {name: "system.memory.usage", unit: "By", attrs: map[string]string{"state": "used"}},
+	model.MetricMemoryTotal:     {name: "system.memory.limit", unit: "By"},
+	model.MetricMemoryAvailable: {name: "system.linux.memory.available", unit: "By"},
+	model.MetricMemoryBuffers:   {name: "system.memory.usage", unit: "By", attrs: map[string]string{"state": "buffers"}},
+	model.MetricMemoryCached:    {name: "system.memory.usage", unit: "By", attrs: map[string]string{"state": "cached"}},
+	model.MetricSwapUsed:        {name: "system.paging.usage", unit: "By", attrs: map[string]string{"state": "used"}},
+	model.MetricDiskRead: {name: "system.disk.io", unit: "By/s",
+		attrs: map[string]string{"direction": "read"}, labels: map[string]string{model.LabelDevice: "system.device"}},
+	model.MetricDiskWrite: {name: "system.disk.io", unit: "By/s",
+		attrs: map[string]string{"direction": "write"}, labels: map[string]string{model.LabelDevice: "system.device"}},
+	model.MetricNetRecv: {name: "system.network.io", unit: "By/s",
+		attrs: map[string]string{"direction": "receive"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetSend: {name: "system.network.io", unit: "By/s",
+		attrs: map[string]string{"direction": "transmit"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetPacketsRecv: {name: "system.network.packets", unit: "{packet}/s",
+		attrs: map[string]string{"direction": "receive"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetPacketsSent: {name: "system.network.packets", unit: "{packet}/s",
+		attrs: map[string]string{"direction": "transmit"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetErrIn: {name: "system.network.errors", unit: "{error}/s",
+		attrs: map[string]string{"direction": "receive"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetErrOut: {name: "system.network.errors", unit: "{error}/s",
+		attrs: map[string]string{"direction": "transmit"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetDropIn: {name: "system.network.dropped", unit: "{packet}/s",
+		attrs: map[string]string{"direction": "receive"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricNetDropOut: {name: "system.network.dropped", unit: "{packet}/s",
+		attrs: map[string]string{"direction": "transmit"}, labels: map[string]string{model.LabelEthernet: "system.device"}},
+	model.MetricTCPConnections: {name: "system.network.connections", unit: "{connection}",
+		attrs: map[string]string{"protocol": "tcp"}},
+	model.MetricTemperature: {name: "hw.temperature", unit: "Cel", labels: map[string]string{model.LabelSensor: "hw.name"}},
+	model.MetricFanSpeed:    {name: "hw.fan.speed", unit: "rpm", labels: map[string]string{model.LabelSensor: "hw.name"}},
+	model.MetricBatteryCapacity: {name: "hw.battery.charge", unit: "1", scale: 0.01,
+		labels: map[string]string{model.LabelSensor: "hw.name"}},
+	model.MetricContainerCPUPercent:    {name: "container.cpu.utilization", unit: "1", scale: 0.01},
+	model.MetricContainerMemoryPercent: {name: "container.memory.utilization", unit: "1", scale: 0.01},
+	model.MetricContainerMemoryUsage:   {name: "container.memory.usage", unit: "By"},
+	model.MetricContainerMemoryLimit:   {name: "container.memory.usage.limit", unit: "By"},
+	model.MetricContainerIORead:        {name: "container.disk.io", unit: "By/s", attrs: map[string]string{"direction": "read"}},
+	model.MetricContainerIOWrite:       {name: "container.disk.io", unit: "By/s", attrs: map[string]string{"direction": "write"}},
+}
+
+// containerAttrs 容器标签作为 Resource 属性上报
+var containerAttrs = map[string]string{
+	model.LabelContainer: "container.id",
+	model.LabelName:      "container.name",
+	model.LabelImage:     "container.image.name",
+}
+
+var _ Client = (*OTLPClient)(nil)
+
+// OTLPClient 通过 OTLP/HTTP 或 OTLP/gRPC 推送指标
+type OTLPClient struct {
+	resource map[string]string
+	http     *httpClient
+	conn     *grpc.ClientConn
+	grpc     collectorpb.MetricsServiceClient
+	headers  map[string]string
+}
+
+// defaultResource 主机级 Resource 属性，attrs 中的同名属性优先
+func defaultResource(attrs map[string]string) map[string]string {
+	res := map[string]string{
+		"service.name": "amprobe",
+		"os.type":      runtime.GOOS,
+	}
+	for k, v := range attrs {
+		res[k] = v
+	}
+	return res
+}
+
+// NewOTLPHTTPClient config.URL 为完整地址，如 http://127.0.0.1:4318/v1/metrics
+func NewOTLPHTTPClient(config HTTPConfig, resource map[string]string) *OTLPClient {
+	return &OTLPClient{
+		resource: defaultResource(resource),
+		http:     newHTTPClient(config, "Bearer"),
+	}
+}
+
+// NewOTLPGRPCClient endpoint 如 127.0.0.1:4317，insecure 为 true 时不使用 TLS
+func NewOTLPGRPCClient(endpoint string, insecureConn bool, config HTTPConfig, resource map[string]string) (*OTLPClient, error) {
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if insecureConn {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string, len(config.Headers)+1)
+	for k, v := range config.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	if config.Token != "" {
+		headers["authorization"] = "Bearer " + config.Token
+	}
+	return &OTLPClient{
+		resource: defaultResource(resource),
+		conn:     conn,
+		grpc:     collectorpb.NewMetricsServiceClient(conn),
+		headers:  headers,
+	}, nil
+}
+
+func (c *OTLPClient) Name() string {
+	if c.conn != nil {
+		return "otlp-grpc"
+	}
+	return "otlp-http"
+}
+
+func (c *OTLPClient) Send(ctx context.Context, samples []metricstore.Sample) error {
+	req := buildMetricsRequest(samples, c.resource)
+	if c.conn != nil {
+		if len(c.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.headers))
+		}
+		if _, err := c.grpc.Export(ctx, req); err != nil {
+			switch status.Code(err) {
+			case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted, codes.Canceled:
+				return RecoverableError{Err: err}
+			}
+			return err
+		}
+		return nil
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.http.post(ctx, body, map[string]string{"Content-Type": "application/x-protobuf"})
+}
+
+func (c *OTLPClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+func toKeyValues(attrs map[string]string) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, &commonpb.KeyValue{
+			Key:   k,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: attrs[k]}},
+		})
+	}
+	return kvs
+}
+
+// buildMetricsRequest 主机指标归入主机 Resource，容器指标按容器拆分 Resource
+func buildMetricsRequest(samples []metricstore.Sample, resource map[string]string) *collectorpb.ExportMetricsServiceRequest {
+	type resourceGroup struct {
+		rm      *metricspb.ResourceMetrics
+		metrics map[string]*metricspb.Metric
+	}
+	groups := make(map[string]*resourceGroup)
+	req := &collectorpb.ExportMetricsServiceRequest{}
+
+	for _, s := range samples {
+		m, ok := otelMetrics[s.Metric]
+		if !ok {
+			m = otelMetric{name: s.Metric}
+		}
+		resAttrs := resource
+		copied := false
+		attrs := make(map[string]string)
+		for k, v := range m.attrs {
+			attrs[k] = v
+		}
+		for k, v := range s.Labels {
+			if name, ok := containerAttrs[k]; ok {
+				if !copied {
+					resAttrs = make(map[string]string, len(resource)+len(containerAttrs))
+					for rk, rv := range resource {
+						resAttrs[rk] = rv
+					}
+					copied = true
+				}
+				resAttrs[name] = v
+				continue
+			}
+			if name, ok := m.labels[k]; ok {
+				k = name
+			}
+			attrs[k] = v
+		}
+
+		resKey := metricstore.Labels(resAttrs).String()
+		group, ok := groups[resKey]
+		if !ok {
+			group = &resourceGroup{
+				rm: &metricspb.ResourceMetrics{
+					Resource:     &resourcepb.Resource{Attributes: toKeyValues(resAttrs)},
+					ScopeMetrics: []*metricspb.ScopeMetrics{{Scope: &commonpb.InstrumentationScope{Name: scopeName}}},
+				},
+				metrics: make(map[string]*metricspb.Metric),
+			}
+			groups[resKey] = group
+			req.ResourceMetrics = append(req.ResourceMetrics, group.rm)
+		}
+		metric, ok := group.metrics[m.name]
+		if !ok {
+			metric = &metricspb.Metric{Name: m.name, Unit: m.unit, Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}}
+			group.metrics[m.name] = metric
+			scope := group.rm.ScopeMetrics[0]
+			scope.Metrics = append(scope.Metrics, metric)
+		}
+		value := s.Value
+		if m.scale != 0 {
+			value *= m.scale
+		}
+		gauge := metric.GetGauge()
+		gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   toKeyValues(attrs),
+			TimeUnixNano: uint64(s.Timestamp.UnixNano()),
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+		})
+	}
+	return req
+}
@@ -0,0 +1,171 @@
+// Package exporter
+// Date: 2024/4/27 11:30
+// Author: Amu
+// Description:
+package exporter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/amuluze/amprobe/service/model"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func otlpSamples() []metricstore.Sample {
+	ts := time.Unix(1714100000, 0)
+	return []metricstore.Sample{
+		{Metric: model.MetricCPUPercent, Timestamp: ts, Value: 25},
+		{Metric: model.MetricDiskRead, Labels: metricstore.Labels{model.LabelDevice: "sda"}, Timestamp: ts, Value: 100},
+		{Metric: model.MetricDiskWrite, Labels: metricstore.Labels{model.LabelDevice: "sda"}, Timestamp: ts, Value: 200},
+		{Metric: model.MetricContainerMemoryUsage, Labels: metricstore.Labels{
+			model.LabelContainer: "abc123", model.LabelName: "web", model.LabelImage: "nginx:1.25",
+		}, Timestamp: ts, Value: 1 << 20},
+	}
+}
+
+func attrMap(kvs []*commonpb.KeyValue) map[string]string {
+	res := make(map[string]string)
+	for _, kv := range kvs {
+		res[kv.Key] = kv.Value.GetStringValue()
+	}
+	return res
+}
+
+func findMetric(rm *metricspb.ResourceMetrics, name string) *metricspb.Metric {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func checkMetricsRequest(t *testing.T, req *collectorpb.ExportMetricsServiceRequest) {
+	t.Helper()
+	if len(req.ResourceMetrics) != 2 {
+		t.Fatalf("expected host and container resources, got %d", len(req.ResourceMetrics))
+	}
+	host, container := req.ResourceMetrics[0], req.ResourceMetrics[1]
+	if attrs := attrMap(host.Resource.Attributes); attrs["host.name"] != "node1" || attrs["service.name"] != "amprobe" {
+		t.Fatalf("unexpected host resource: %v", attrs)
+	}
+	cpu := findMetric(host, "system.cpu.utilization")
+	if cpu == nil || cpu.Unit != "1" || cpu.GetGauge().DataPoints[0].GetAsDouble() != 0.25 {
+		t.Fatalf("unexpected cpu metric: %v", cpu)
+	}
+	if cpu.GetGauge().DataPoints[0].TimeUnixNano != uint64(time.Unix(1714100000, 0).UnixNano()) {
+		t.Fatalf("unexpected cpu timestamp: %v", cpu)
+	}
+	disk := findMetric(host, "system.disk.io")
+	if disk == nil || len(disk.GetGauge().DataPoints) != 2 {
+		t.Fatalf("unexpected disk metric: %v", disk)
+	}
+	if attrs := attrMap(disk.GetGauge().DataPoints[1].Attributes); attrs["direction"] != "write" || attrs["system.device"] != "sda" {
+		t.Fatalf("unexpected disk attributes: %v", attrs)
+	}
+	attrs := attrMap(container.Resource.Attributes)
+	if attrs["container.id"] != "abc123" || attrs["container.name"] != "web" || attrs["container.image.name"] != "nginx:1.25" || attrs["host.name"] != "node1" {
+		t.Fatalf("unexpected container resource: %v", attrs)
+	}
+	mem := findMetric(container, "container.memory.usage")
+	if mem == nil || mem.Unit != "By" || mem.GetGauge().DataPoints[0].GetAsDouble() != 1<<20 {
+		t.Fatalf("unexpected container memory metric: %v", mem)
+	}
+	if len(mem.GetGauge().DataPoints[0].Attributes) != 0 {
+		t.Fatalf("container labels should only be resource attributes: %v", mem)
+	}
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	var req collectorpb.ExportMetricsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := proto.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewOTLPHTTPClient(HTTPConfig{URL: srv.URL + "/v1/metrics"}, map[string]string{"host.name": "node1"})
+	if err := client.Send(context.Background(), otlpSamples()); err != nil {
+		t.Fatal(err)
+	}
+	checkMetricsRequest(t, &req)
+}
+
+type metricsServer struct {
+	collectorpb.UnimplementedMetricsServiceServer
+	code codes.Code
+	auth string
+	req  *collectorpb.ExportMetricsServiceRequest
+}
+
+func (s *metricsServer) Export(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	if s.code != codes.OK {
+		return nil, status.Error(s.code, "unavailable")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		s.auth = md.Get("authorization")[0]
+	}
+	s.req = req
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	ms := &metricsServer{}
+	collectorpb.RegisterMetricsServiceServer(srv, ms)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	client, err := NewOTLPGRPCClient(lis.Addr().String(), true, HTTPConfig{Token: "secret"}, map[string]string{"host.name": "node1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Send(ctx, otlpSamples()); err != nil {
+		t.Fatal(err)
+	}
+	checkMetricsRequest(t, ms.req)
+	if ms.auth != "Bearer secret" {
+		t.Fatalf("unexpected authorization metadata: %q", ms.auth)
+	}
+
+	ms.code = codes.Unavailable
+	if err := client.Send(ctx, otlpSamples()); !isRecoverable(err) {
+		t.Fatalf("expected recoverable error, got %v", err)
+	}
+	ms.code = codes.InvalidArgument
+	if err := client.Send(ctx, otlpSamples()); err == nil || isRecoverable(err) {
+		t.Fatalf("expected non-recoverable error, got %v", err)
+	}
+}
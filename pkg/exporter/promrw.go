@@ -0,0 +1,124 @@
+// Package exporter
+// Date: 2024/4/26 11:30
+// Author: Amu
+// Description: Prometheus remote-write 协议 (snappy 压缩的 protobuf)，适用于 Prometheus、VictoriaMetrics 等
+package exporter
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var _ Client = (*RemoteWriteClient)(nil)
+
+type RemoteWriteClient struct {
+	http *httpClient
+}
+
+func NewRemoteWriteClient(config HTTPConfig) *RemoteWriteClient {
+	return &RemoteWriteClient{http: newHTTPClient(config, "Bearer")}
+}
+
+func (c *RemoteWriteClient) Name() string {
+	return "prometheus"
+}
+
+func (c *RemoteWriteClient) Send(ctx context.Context, samples []metricstore.Sample) error {
+	body := snappy.Encode(nil, encodeWriteRequest(samples))
+	return c.http.post(ctx, body, map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	})
+}
+
+// sanitizeName 将指标名和标签名中不合法的字符替换为下划线
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9')
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+type promLabel struct {
+	name  string
+	value string
+}
+
+type promSeries struct {
+	labels  []promLabel
+	samples []metricstore.Point
+}
+
+// groupSeries 按指标名和标签分组，标签按名称排序
+func groupSeries(samples []metricstore.Sample) []*promSeries {
+	index := make(map[string]*promSeries)
+	var list []*promSeries
+	for _, s := range samples {
+		labels := []promLabel{{name: "__name__", value: sanitizeName(s.Metric)}}
+		for k, v := range s.Labels {
+			labels = append(labels, promLabel{name: sanitizeName(k), value: v})
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+		var key strings.Builder
+		for _, l := range labels {
+			key.WriteString(l.name)
+			key.WriteByte(0)
+			key.WriteString(l.value)
+			key.WriteByte(0)
+		}
+		series, ok := index[key.String()]
+		if !ok {
+			series = &promSeries{labels: labels}
+			index[key.String()] = series
+			list = append(list, series)
+		}
+		series.samples = append(series.samples, metricstore.Point{Timestamp: s.Timestamp, Value: s.Value})
+	}
+	return list
+}
+
+// encodeWriteRequest 按 prometheus.WriteRequest 编码:
+// WriteRequest{1: repeated TimeSeries}
+// TimeSeries{1: repeated Label, 2: repeated Sample}
+// Label{1: name, 2: value}
+// Sample{1: double value, 2: int64 timestamp(ms)}
+func encodeWriteRequest(samples []metricstore.Sample) []byte {
+	var buf []byte
+	for _, series := range groupSeries(samples) {
+		var ts []byte
+		for _, l := range series.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		for _, p := range series.samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(p.Value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(p.Timestamp.UnixMilli()))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sb)
+		}
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+	return buf
+}
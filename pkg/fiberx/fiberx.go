@@ -24,6 +24,19 @@ func GetToken(c *fiber.Ctx) string {
 	return token
 }
 
+const loginUserKey = "login_user"
+
+// SetLoginUser 登录接口请求体中没有用户名时(如 TOTP 二次验证)，由处理函数设置登录用户，供审计记录使用
+func SetLoginUser(c *fiber.Ctx, username string) {
+	c.Locals(loginUserKey, username)
+}
+
+// LoginUser 返回处理函数设置的登录用户
+func LoginUser(c *fiber.Ctx) string {
+	username, _ := c.Locals(loginUserKey).(string)
+	return username
+}
+
 // Success response.status = 200
 func Success(c *fiber.Ctx, v interface{}) error {
 	return ReturnJson(c, http.StatusOK, v)
@@ -0,0 +1,130 @@
+// Package health
+// Date: 2024/5/7 10:00
+// Author: Amu
+// Description: 采集项运行状态和依赖检查，用于自身可观测性
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CollectorStatus 采集项状态，Errors 和 Skipped 为进程启动以来的累计次数
+type CollectorStatus struct {
+	Name        string
+	Running     bool
+	LastRun     time.Time
+	LastSuccess time.Time
+	LastError   string
+	Duration    time.Duration
+	Samples     int
+	Errors      uint64
+	Skipped     uint64
+}
+
+type collector struct {
+	status CollectorStatus
+}
+
+// Registry 记录各采集项的运行状态
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]*collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]*collector)}
+}
+
+func (r *Registry) get(name string) *collector {
+	c, ok := r.collectors[name]
+	if !ok {
+		c = &collector{status: CollectorStatus{Name: name}}
+		r.collectors[name] = c
+	}
+	return c
+}
+
+// Begin 标记采集项开始运行，上一次运行未结束时返回 false 并记录一次跳过
+func (r *Registry) Begin(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.get(name)
+	if c.status.Running {
+		c.status.Skipped++
+		return false
+	}
+	c.status.Running = true
+	return true
+}
+
+// End 记录采集项本次运行结果，samples 为产生的样本或记录数
+func (r *Registry) End(name string, start time.Time, samples int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.get(name)
+	c.status.Running = false
+	c.status.LastRun = start
+	c.status.Duration = time.Since(start)
+	c.status.Samples = samples
+	if err != nil {
+		c.status.LastError = err.Error()
+		c.status.Errors++
+		return
+	}
+	c.status.LastSuccess = start
+	c.status.LastError = ""
+}
+
+// Collectors 返回所有采集项状态，按名称排序
+func (r *Registry) Collectors() []CollectorStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]CollectorStatus, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		list = append(list, c.status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Check 依赖检查，如数据库、容器运行时
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// CheckResult 检查结果，Error 为空表示正常
+type CheckResult struct {
+	Name    string
+	Error   string
+	Latency time.Duration
+}
+
+// Run 并发执行检查，全部通过时 ok 为 true，结果顺序与 checks 一致
+func Run(ctx context.Context, checks []Check) ([]CheckResult, bool) {
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			start := time.Now()
+			results[i] = CheckResult{Name: check.Name}
+			if err := check.Check(ctx); err != nil {
+				results[i].Error = err.Error()
+			}
+			results[i].Latency = time.Since(start)
+		}(i, check)
+	}
+	wg.Wait()
+	ok := true
+	for _, res := range results {
+		if res.Error != "" {
+			ok = false
+		}
+	}
+	return results, ok
+}
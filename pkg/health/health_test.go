@@ -0,0 +1,75 @@
+// Package health
+// Date: 2024/5/7 10:00
+// Author: Amu
+// Description:
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	start := time.Now()
+	if !r.Begin("cpu") {
+		t.Fatal("expected first run to begin")
+	}
+	if r.Begin("cpu") {
+		t.Fatal("expected overlapping run to be skipped")
+	}
+	r.End("cpu", start, 3, nil)
+
+	status := r.Collectors()[0]
+	if status.Running || status.Samples != 3 || status.Skipped != 1 || status.Errors != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if !status.LastSuccess.Equal(start) || status.LastError != "" {
+		t.Fatalf("expected last success at %v, got %+v", start, status)
+	}
+
+	failed := time.Now()
+	if !r.Begin("cpu") {
+		t.Fatal("expected run to begin after end")
+	}
+	r.End("cpu", failed, 0, errors.New("boom"))
+	status = r.Collectors()[0]
+	if status.Errors != 1 || status.LastError != "boom" || !status.LastRun.Equal(failed) || !status.LastSuccess.Equal(start) {
+		t.Fatalf("unexpected status after failure: %+v", status)
+	}
+}
+
+func TestRegistryCollectorsSorted(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"memory", "container", "cpu"} {
+		r.Begin(name)
+		r.End(name, time.Now(), 0, nil)
+	}
+	list := r.Collectors()
+	if len(list) != 3 || list[0].Name != "container" || list[1].Name != "cpu" || list[2].Name != "memory" {
+		t.Fatalf("unexpected order: %+v", list)
+	}
+}
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "db", Check: func(ctx context.Context) error { return nil }},
+		{Name: "runtime", Check: func(ctx context.Context) error { return errors.New("connection refused") }},
+	}
+	results, ok := Run(context.Background(), checks)
+	if ok {
+		t.Fatal("expected failed check")
+	}
+	if results[0].Name != "db" || results[0].Error != "" {
+		t.Fatalf("unexpected db result: %+v", results[0])
+	}
+	if results[1].Name != "runtime" || results[1].Error != "connection refused" {
+		t.Fatalf("unexpected runtime result: %+v", results[1])
+	}
+
+	if _, ok := Run(context.Background(), checks[:1]); !ok {
+		t.Fatal("expected all checks to pass")
+	}
+}
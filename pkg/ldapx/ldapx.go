@@ -0,0 +1,152 @@
+// Package ldapx
+// Date: 2024/4/18 10:36
+// Author: Amu
+// Description: LDAP / AD 用户认证
+package ldapx
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var ErrInvalidCredentials = fmt.Errorf("invalid ldap credentials")
+
+type Config struct {
+	URL                string // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // 例如 (uid=%s) 或 (sAMAccountName=%s)
+	UsernameAttribute  string
+	GroupAttribute     string // 例如 memberOf
+	AdminGroups        []string
+	Timeout            int // 单位 s
+}
+
+type Entry struct {
+	DN       string
+	Username string
+	Groups   []string
+}
+
+// Authenticator 外部账号认证
+type Authenticator interface {
+	Authenticate(username, password string) (*Entry, error)
+	IsAdmin(entry *Entry) bool
+}
+
+var _ Authenticator = (*Client)(nil)
+
+type Client struct {
+	config *Config
+}
+
+func New(config *Config) *Client {
+	return &Client{config: config}
+}
+
+func (c *Client) dial() (*ldap.Conn, error) {
+	timeout := time.Duration(c.config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.config.InsecureSkipVerify}
+	conn, err := ldap.DialURL(c.config.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if c.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Authenticate 先用服务账号查找用户 DN，再以该 DN 和用户密码绑定校验
+func (c *Client) Authenticate(username, password string) (*Entry, error) {
+	// 空密码会被部分服务端当作匿名绑定处理
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.config.BindDN != "" {
+		err = conn.Bind(c.config.BindDN, c.config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap service bind: %w", err)
+	}
+
+	attrs := []string{"dn"}
+	if c.config.UsernameAttribute != "" {
+		attrs = append(attrs, c.config.UsernameAttribute)
+	}
+	if c.config.GroupAttribute != "" {
+		attrs = append(attrs, c.config.GroupAttribute)
+	}
+	req := ldap.NewSearchRequest(
+		c.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		BuildFilter(c.config.UserFilter, username),
+		attrs,
+		nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("ldap search: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	e := res.Entries[0]
+
+	if err := conn.Bind(e.DN, password); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry := &Entry{DN: e.DN, Username: username}
+	if c.config.UsernameAttribute != "" {
+		if v := e.GetAttributeValue(c.config.UsernameAttribute); v != "" {
+			entry.Username = v
+		}
+	}
+	if c.config.GroupAttribute != "" {
+		entry.Groups = e.GetAttributeValues(c.config.GroupAttribute)
+	}
+	return entry, nil
+}
+
+// IsAdmin 用户所属组命中 AdminGroups 时视为管理员，DN 比较不区分大小写
+func (c *Client) IsAdmin(entry *Entry) bool {
+	for _, g := range entry.Groups {
+		for _, admin := range c.config.AdminGroups {
+			if strings.EqualFold(strings.TrimSpace(g), strings.TrimSpace(admin)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BuildFilter 将转义后的用户名填入过滤器
+func BuildFilter(filter string, username string) string {
+	if filter == "" {
+		filter = "(uid=%s)"
+	}
+	return strings.ReplaceAll(filter, "%s", ldap.EscapeFilter(username))
+}
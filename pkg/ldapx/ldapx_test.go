@@ -0,0 +1,35 @@
+// Package ldapx
+// Date: 2024/4/18 10:36
+// Author: Amu
+// Description:
+package ldapx
+
+import "testing"
+
+func TestBuildFilter(t *testing.T) {
+	got := BuildFilter("(&(objectClass=person)(uid=%s))", "bob*)(uid=*")
+	want := `(&(objectClass=person)(uid=bob\2a\29\28uid=\2a))`
+	if got != want {
+		t.Fatalf("BuildFilter() = %s, want %s", got, want)
+	}
+	if got := BuildFilter("", "alice"); got != "(uid=alice)" {
+		t.Fatalf("default filter = %s", got)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	c := New(&Config{AdminGroups: []string{"cn=ops,ou=groups,dc=example,dc=com"}})
+	if !c.IsAdmin(&Entry{Groups: []string{"CN=Ops,OU=Groups,DC=example,DC=com"}}) {
+		t.Fatal("admin group not matched")
+	}
+	if c.IsAdmin(&Entry{Groups: []string{"cn=dev,ou=groups,dc=example,dc=com"}}) {
+		t.Fatal("non admin group matched")
+	}
+}
+
+func TestAuthenticateRejectsEmptyPassword(t *testing.T) {
+	c := New(&Config{URL: "ldap://127.0.0.1:1"})
+	if _, err := c.Authenticate("alice", ""); err != ErrInvalidCredentials {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+// Package gormstore
+// Date: 2024/4/25 15:30
+// Author: Amu
+// Description: 基于数据库表的指标存储，主机指标沿用 s_cpu/s_memory/s_disk/s_net，其余指标写入 s_metric_sample
+package gormstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"gorm.io/gorm"
+)
+
+var _ metricstore.MetricStore = (*Store)(nil)
+
+// column 指标与专用表字段的对应关系
+type column struct {
+	table       interface{}
+	timeColumn  string
+	valueColumn string
+	labelName   string
+	labelColumn string
+}
+
+var columns = map[string]column{
+	model.MetricCPUPercent:      {&model.CPU{}, "timestamp", "cpu_percent", "", ""},
+	model.MetricMemoryPercent:   {&model.Memory{}, "timestamp", "mem_percent", "", ""},
+	model.MetricMemoryTotal:     {&model.Memory{}, "timestamp", "mem_total", "", ""},
+	model.MetricMemoryUsed:      {&model.Memory{}, "timestamp", "mem_used", "", ""},
+	model.MetricMemoryAvailable: {&model.Memory{}, "timestamp", "mem_available", "", ""},
+	model.MetricMemoryBuffers:   {&model.Memory{}, "timestamp", "mem_buffers", "", ""},
+	model.MetricMemoryCached:    {&model.Memory{}, "timestamp", "mem_cached", "", ""},
+	model.MetricSwapTotal:       {&model.Memory{}, "timestamp", "swap_total", "", ""},
+	model.MetricSwapUsed:        {&model.Memory{}, "timestamp", "swap_used", "", ""},
+	model.MetricSwapIn:          {&model.Memory{}, "timestamp", "swap_in", "", ""},
+	model.MetricSwapOut:         {&model.Memory{}, "timestamp", "swap_out", "", ""},
+	model.MetricDiskRead:        {&model.Disk{}, "created_at", "disk_read", model.LabelDevice, "device"},
+	model.MetricDiskWrite:       {&model.Disk{}, "created_at", "disk_write", model.LabelDevice, "device"},
+	model.MetricDiskReadIOPS:    {&model.Disk{}, "created_at", "disk_read_iops", model.LabelDevice, "device"},
+	model.MetricDiskWriteIOPS:   {&model.Disk{}, "created_at", "disk_write_iops", model.LabelDevice, "device"},
+	model.MetricDiskAwait:       {&model.Disk{}, "created_at", "disk_await", model.LabelDevice, "device"},
+	model.MetricDiskQueueDepth:  {&model.Disk{}, "created_at", "disk_queue_depth", model.LabelDevice, "device"},
+	model.MetricDiskUtil:        {&model.Disk{}, "created_at", "disk_util", model.LabelDevice, "device"},
+	model.MetricNetRecv:         {&model.Net{}, "created_at", "net_recv", model.LabelEthernet, "ethernet"},
+	model.MetricNetSend:         {&model.Net{}, "created_at", "net_send", model.LabelEthernet, "ethernet"},
+	model.MetricNetPacketsRecv:  {&model.Net{}, "created_at", "net_packets_recv", model.LabelEthernet, "ethernet"},
+	model.MetricNetPacketsSent:  {&model.Net{}, "created_at", "net_packets_sent", model.LabelEthernet, "ethernet"},
+	model.MetricNetErrIn:        {&model.Net{}, "created_at", "net_err_in", model.LabelEthernet, "ethernet"},
+	model.MetricNetErrOut:       {&model.Net{}, "created_at", "net_err_out", model.LabelEthernet, "ethernet"},
+	model.MetricNetDropIn:       {&model.Net{}, "created_at", "net_drop_in", model.LabelEthernet, "ethernet"},
+	model.MetricNetDropOut:      {&model.Net{}, "created_at", "net_drop_out", model.LabelEthernet, "ethernet"},
+}
+
+// memoryFields 写入 s_memory 的指标
+var memoryFields = map[string]func(m *model.Memory, v float64){
+	model.MetricMemoryPercent:   func(m *model.Memory, v float64) { m.MemPercent = v },
+	model.MetricMemoryTotal:     func(m *model.Memory, v float64) { m.MemTotal = v },
+	model.MetricMemoryUsed:      func(m *model.Memory, v float64) { m.MemUsed = v },
+	model.MetricMemoryAvailable: func(m *model.Memory, v float64) { m.MemAvailable = v },
+	model.MetricMemoryBuffers:   func(m *model.Memory, v float64) { m.MemBuffers = v },
+	model.MetricMemoryCached:    func(m *model.Memory, v float64) { m.MemCached = v },
+	model.MetricSwapTotal:       func(m *model.Memory, v float64) { m.SwapTotal = v },
+	model.MetricSwapUsed:        func(m *model.Memory, v float64) { m.SwapUsed = v },
+	model.MetricSwapIn:          func(m *model.Memory, v float64) { m.SwapIn = v },
+	model.MetricSwapOut:         func(m *model.Memory, v float64) { m.SwapOut = v },
+}
+
+// diskFields 写入 s_disk 的指标，按 device 标签合并为一行
+var diskFields = map[string]func(d *model.Disk, v float64){
+	model.MetricDiskRead:       func(d *model.Disk, v float64) { d.DiskRead = v },
+	model.MetricDiskWrite:      func(d *model.Disk, v float64) { d.DiskWrite = v },
+	model.MetricDiskReadIOPS:   func(d *model.Disk, v float64) { d.DiskReadIOPS = v },
+	model.MetricDiskWriteIOPS:  func(d *model.Disk, v float64) { d.DiskWriteIOPS = v },
+	model.MetricDiskAwait:      func(d *model.Disk, v float64) { d.DiskAwait = v },
+	model.MetricDiskQueueDepth: func(d *model.Disk, v float64) { d.DiskQueueDepth = v },
+	model.MetricDiskUtil:       func(d *model.Disk, v float64) { d.DiskUtil = v },
+}
+
+// netFields 写入 s_net 的指标，按 ethernet 标签合并为一行
+var netFields = map[string]func(n *model.Net, v float64){
+	model.MetricNetRecv:        func(n *model.Net, v float64) { n.NetRecv = v },
+	model.MetricNetSend:        func(n *model.Net, v float64) { n.NetSend = v },
+	model.MetricNetPacketsRecv: func(n *model.Net, v float64) { n.NetPacketsRecv = v },
+	model.MetricNetPacketsSent: func(n *model.Net, v float64) { n.NetPacketsSent = v },
+	model.MetricNetErrIn:       func(n *model.Net, v float64) { n.NetErrIn = v },
+	model.MetricNetErrOut:      func(n *model.Net, v float64) { n.NetErrOut = v },
+	model.MetricNetDropIn:      func(n *model.Net, v float64) { n.NetDropIn = v },
+	model.MetricNetDropOut:     func(n *model.Net, v float64) { n.NetDropOut = v },
+}
+
+type Store struct {
+	DB *database.DB
+}
+
+func New(db *database.DB) *Store {
+	return &Store{DB: db}
+}
+
+type rowKey struct {
+	label string
+	ts    int64
+}
+
+func (s *Store) Write(ctx context.Context, samples []metricstore.Sample) error {
+	var cpus []model.CPU
+	memories := make(map[int64]*model.Memory)
+	disks := make(map[rowKey]*model.Disk)
+	nets := make(map[rowKey]*model.Net)
+	var others []model.MetricSample
+	var memoryOrder []int64
+	var diskOrder, netOrder []rowKey
+
+	for _, sample := range samples {
+		ts := sample.Timestamp
+		if set, ok := memoryFields[sample.Metric]; ok {
+			m, ok := memories[ts.UnixNano()]
+			if !ok {
+				m = &model.Memory{Timestamp: ts}
+				memories[ts.UnixNano()] = m
+				memoryOrder = append(memoryOrder, ts.UnixNano())
+			}
+			set(m, sample.Value)
+			continue
+		}
+		if set, ok := diskFields[sample.Metric]; ok {
+			key := rowKey{sample.Labels[model.LabelDevice], ts.UnixNano()}
+			d, ok := disks[key]
+			if !ok {
+				d = &model.Disk{Device: key.label}
+				d.CreatedAt = ts
+				disks[key] = d
+				diskOrder = append(diskOrder, key)
+			}
+			set(d, sample.Value)
+			continue
+		}
+		if set, ok := netFields[sample.Metric]; ok {
+			key := rowKey{sample.Labels[model.LabelEthernet], ts.UnixNano()}
+			n, ok := nets[key]
+			if !ok {
+				n = &model.Net{Ethernet: key.label}
+				n.CreatedAt = ts
+				nets[key] = n
+				netOrder = append(netOrder, key)
+			}
+			set(n, sample.Value)
+			continue
+		}
+		switch sample.Metric {
+		case model.MetricCPUPercent:
+			cpus = append(cpus, model.CPU{Timestamp: ts, CPUPercent: sample.Value})
+		default:
+			others = append(others, model.MetricSample{
+				Metric:    sample.Metric,
+				Labels:    sample.Labels.String(),
+				Timestamp: ts,
+				Value:     sample.Value,
+			})
+		}
+	}
+
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(cpus) > 0 {
+			if err := tx.Create(&cpus).Error; err != nil {
+				return err
+			}
+		}
+		for _, k := range memoryOrder {
+			if err := tx.Create(memories[k]).Error; err != nil {
+				return err
+			}
+		}
+		for _, k := range diskOrder {
+			if err := tx.Create(disks[k]).Error; err != nil {
+				return err
+			}
+		}
+		for _, k := range netOrder {
+			if err := tx.Create(nets[k]).Error; err != nil {
+				return err
+			}
+		}
+		if len(others) > 0 {
+			if err := tx.CreateInBatches(&others, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type row struct {
+	TS    time.Time
+	Value float64
+	Label string
+}
+
+func (s *Store) Query(ctx context.Context, q metricstore.Query) ([]metricstore.Series, error) {
+	var series []metricstore.Series
+	var err error
+	if c, ok := columns[q.Metric]; ok {
+		series, err = s.queryColumn(ctx, c, q)
+	} else {
+		series, err = s.querySample(ctx, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metricstore.Finalize(series, q)
+}
+
+func (s *Store) queryColumn(ctx context.Context, c column, q metricstore.Query) ([]metricstore.Series, error) {
+	label := "''"
+	if c.labelColumn != "" {
+		label = c.labelColumn
+	}
+	tx := s.DB.WithContext(ctx).Model(c.table).
+		Select(fmt.Sprintf("%s AS ts, %s AS value, %s AS label", c.timeColumn, c.valueColumn, label)).
+		Where(fmt.Sprintf("%s >= ? AND %s <= ?", c.timeColumn, c.timeColumn), q.Start, q.End).
+		Order(c.timeColumn + " ASC")
+	for k, v := range q.Labels {
+		if k != c.labelName || c.labelColumn == "" {
+			// 专用表没有该标签，不会有匹配的数据
+			return nil, nil
+		}
+		tx = tx.Where(c.labelColumn+" = ?", v)
+	}
+	var rows []row
+	if err := tx.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	var series []metricstore.Series
+	for _, r := range rows {
+		i, ok := index[r.Label]
+		if !ok {
+			labels := metricstore.Labels{}
+			if c.labelName != "" {
+				labels[c.labelName] = r.Label
+			}
+			series = append(series, metricstore.Series{Metric: q.Metric, Labels: labels})
+			i = len(series) - 1
+			index[r.Label] = i
+		}
+		series[i].Points = append(series[i].Points, metricstore.Point{Timestamp: r.TS, Value: r.Value})
+	}
+	return series, nil
+}
+
+func (s *Store) querySample(ctx context.Context, q metricstore.Query) ([]metricstore.Series, error) {
+	var samples []model.MetricSample
+	err := s.DB.WithContext(ctx).Model(&model.MetricSample{}).
+		Where("metric = ? AND timestamp >= ? AND timestamp <= ?", q.Metric, q.Start, q.End).
+		Order("timestamp ASC").
+		Find(&samples).Error
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	var series []metricstore.Series
+	for _, sample := range samples {
+		i, ok := index[sample.Labels]
+		if !ok {
+			labels, err := metricstore.ParseLabels(sample.Labels)
+			if err != nil {
+				return nil, err
+			}
+			series = append(series, metricstore.Series{Metric: q.Metric, Labels: labels})
+			i = len(series) - 1
+			index[sample.Labels] = i
+		}
+		series[i].Points = append(series[i].Points, metricstore.Point{Timestamp: sample.Timestamp, Value: sample.Value})
+	}
+	var res []metricstore.Series
+	for _, s := range series {
+		if s.Labels.Match(q.Labels) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package gormstore
+// Date: 2024/4/25 16:20
+// Author: Amu
+// Description:
+package gormstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "metric.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.CPU{}, &model.Memory{}, &model.Disk{}, &model.Net{}, &model.MetricSample{}); err != nil {
+		t.Fatal(err)
+	}
+	return New(&database.DB{DB: db})
+}
+
+func TestWriteQuery(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var samples []metricstore.Sample
+	for i := 0; i < 4; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		samples = append(samples,
+			metricstore.Sample{Metric: model.MetricCPUPercent, Timestamp: ts, Value: float64(i)},
+			metricstore.Sample{Metric: model.MetricMemoryPercent, Timestamp: ts, Value: 50},
+			metricstore.Sample{Metric: model.MetricMemoryUsed, Timestamp: ts, Value: 1024},
+			metricstore.Sample{Metric: model.MetricSwapIn, Timestamp: ts, Value: float64(i * 10)},
+			metricstore.Sample{Metric: model.MetricDiskRead, Labels: metricstore.Labels{model.LabelDevice: "sda"}, Timestamp: ts, Value: 1},
+			metricstore.Sample{Metric: model.MetricDiskWrite, Labels: metricstore.Labels{model.LabelDevice: "sda"}, Timestamp: ts, Value: 2},
+			metricstore.Sample{Metric: model.MetricDiskUtil, Labels: metricstore.Labels{model.LabelDevice: "sda"}, Timestamp: ts, Value: 75},
+			metricstore.Sample{Metric: model.MetricDiskRead, Labels: metricstore.Labels{model.LabelDevice: "sdb"}, Timestamp: ts, Value: 3},
+			metricstore.Sample{Metric: "load1", Labels: metricstore.Labels{"host": "a"}, Timestamp: ts, Value: float64(i) / 2},
+		)
+	}
+	if err := store.Write(ctx, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	var memories int64
+	store.DB.Model(&model.Memory{}).Count(&memories)
+	if memories != 4 {
+		t.Fatalf("expected memory metrics merged into 4 rows, got %d", memories)
+	}
+
+	var disks int64
+	store.DB.Model(&model.Disk{}).Count(&disks)
+	if disks != 8 {
+		t.Fatalf("expected disk metrics merged into 8 rows, got %d", disks)
+	}
+
+	res, err := store.Query(ctx, metricstore.Query{Metric: model.MetricSwapIn, Start: start, End: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Points) != 4 || res[0].Points[2].Value != 20 {
+		t.Fatalf("unexpected swap result: %+v", res)
+	}
+
+	q := metricstore.Query{Metric: model.MetricCPUPercent, Start: start, End: start.Add(time.Hour)}
+	res, err = store.Query(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Points) != 4 || res[0].Points[3].Value != 3 {
+		t.Fatalf("unexpected cpu result: %+v", res)
+	}
+
+	res, err = store.Query(ctx, metricstore.Query{Metric: model.MetricDiskRead, Start: start, End: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Labels[model.LabelDevice] != "sda" || res[1].Points[0].Value != 3 {
+		t.Fatalf("unexpected disk result: %+v", res)
+	}
+
+	res, err = store.Query(ctx, metricstore.Query{
+		Metric: model.MetricDiskWrite,
+		Labels: metricstore.Labels{model.LabelDevice: "sda"},
+		Start:  start,
+		End:    start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Points) != 4 {
+		t.Fatalf("unexpected filtered disk result: %+v", res)
+	}
+
+	res, err = store.Query(ctx, metricstore.Query{Metric: model.MetricDiskUtil, Start: start, End: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Points[0].Value != 75 || res[1].Points[0].Value != 0 {
+		t.Fatalf("unexpected disk util result: %+v", res)
+	}
+
+	res, err = store.Query(ctx, metricstore.Query{
+		Metric:      "load1",
+		Labels:      metricstore.Labels{"host": "a"},
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Step:        2 * time.Minute,
+		Aggregation: metricstore.AggregationSum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Points) != 2 || res[0].Points[1].Value != 2.5 {
+		t.Fatalf("unexpected generic result: %+v", res)
+	}
+}
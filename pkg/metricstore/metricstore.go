@@ -0,0 +1,191 @@
+// Package metricstore
+// Date: 2024/4/25 09:10
+// Author: Amu
+// Description: 时序指标存储接口，采集任务写入样本，查询接口按时间范围聚合读取
+package metricstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+var ErrUnknownAggregation = errors.New("unknown aggregation")
+
+// Labels 指标标签，例如 {"device": "sda"}
+type Labels map[string]string
+
+// String 返回按 key 排序的 JSON 规范形式，用于序列唯一标识
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(map[string]string(l))
+	return string(data)
+}
+
+// ParseLabels 解析 String 生成的规范形式
+func ParseLabels(s string) (Labels, error) {
+	labels := Labels{}
+	if s == "" {
+		return labels, nil
+	}
+	if err := json.Unmarshal([]byte(s), &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// Match 判断是否包含 matcher 中的全部标签
+func (l Labels) Match(matcher Labels) bool {
+	for k, v := range matcher {
+		if l[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Sample 单个采样点
+type Sample struct {
+	Metric    string
+	Labels    Labels
+	Timestamp time.Time
+	Value     float64
+}
+
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Series 同一指标、同一组标签下的采样序列，按时间升序
+type Series struct {
+	Metric string
+	Labels Labels
+	Points []Point
+}
+
+type Aggregation string
+
+const (
+	AggregationNone Aggregation = ""
+	AggregationAvg  Aggregation = "avg"
+	AggregationMin  Aggregation = "min"
+	AggregationMax  Aggregation = "max"
+	AggregationSum  Aggregation = "sum"
+	AggregationLast Aggregation = "last"
+)
+
+// Query 范围查询，Step > 0 时按 Step 对齐分桶并使用 Aggregation 聚合
+type Query struct {
+	Metric      string
+	Labels      Labels
+	Start       time.Time
+	End         time.Time
+	Step        time.Duration
+	Aggregation Aggregation
+}
+
+type MetricStore interface {
+	// Write 写入一批采样点
+	Write(ctx context.Context, samples []Sample) error
+	// Query 查询时间范围 [Start, End] 内的序列
+	Query(ctx context.Context, q Query) ([]Series, error)
+	Close() error
+}
+
+// Aggregate 将采样点按 step 分桶聚合，桶的时间戳为桶起始时间
+func Aggregate(points []Point, start time.Time, step time.Duration, agg Aggregation) ([]Point, error) {
+	if step <= 0 || len(points) == 0 {
+		return points, nil
+	}
+	if agg == AggregationNone {
+		agg = AggregationAvg
+	}
+	var reduce func(values []float64) float64
+	switch agg {
+	case AggregationAvg:
+		reduce = func(values []float64) float64 {
+			var sum float64
+			for _, v := range values {
+				sum += v
+			}
+			return sum / float64(len(values))
+		}
+	case AggregationMin:
+		reduce = func(values []float64) float64 {
+			m := math.Inf(1)
+			for _, v := range values {
+				m = math.Min(m, v)
+			}
+			return m
+		}
+	case AggregationMax:
+		reduce = func(values []float64) float64 {
+			m := math.Inf(-1)
+			for _, v := range values {
+				m = math.Max(m, v)
+			}
+			return m
+		}
+	case AggregationSum:
+		reduce = func(values []float64) float64 {
+			var sum float64
+			for _, v := range values {
+				sum += v
+			}
+			return sum
+		}
+	case AggregationLast:
+		reduce = func(values []float64) float64 {
+			return values[len(values)-1]
+		}
+	default:
+		return nil, ErrUnknownAggregation
+	}
+
+	var res []Point
+	var bucket int64 = -1
+	var values []float64
+	flush := func() {
+		if len(values) > 0 {
+			res = append(res, Point{Timestamp: start.Add(time.Duration(bucket) * step), Value: reduce(values)})
+		}
+		values = values[:0]
+	}
+	for _, p := range points {
+		b := int64(p.Timestamp.Sub(start) / step)
+		if p.Timestamp.Before(start) {
+			b = -1 - int64(start.Sub(p.Timestamp)/step)
+		}
+		if b != bucket {
+			flush()
+			bucket = b
+		}
+		values = append(values, p.Value)
+	}
+	flush()
+	return res, nil
+}
+
+// Finalize 对查询结果做聚合并按标签排序，供各实现复用
+func Finalize(series []Series, q Query) ([]Series, error) {
+	for i := range series {
+		sort.Slice(series[i].Points, func(a, b int) bool {
+			return series[i].Points[a].Timestamp.Before(series[i].Points[b].Timestamp)
+		})
+		points, err := Aggregate(series[i].Points, q.Start, q.Step, q.Aggregation)
+		if err != nil {
+			return nil, err
+		}
+		series[i].Points = points
+	}
+	sort.Slice(series, func(a, b int) bool {
+		return series[a].Labels.String() < series[b].Labels.String()
+	})
+	return series, nil
+}
@@ -0,0 +1,62 @@
+// Package metricstore
+// Date: 2024/4/25 09:40
+// Author: Amu
+// Description:
+package metricstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var points []Point
+	for i := 0; i < 6; i++ {
+		points = append(points, Point{Timestamp: start.Add(time.Duration(i) * 10 * time.Second), Value: float64(i)})
+	}
+	cases := []struct {
+		agg  Aggregation
+		want []float64
+	}{
+		{AggregationAvg, []float64{1, 4}},
+		{AggregationMin, []float64{0, 3}},
+		{AggregationMax, []float64{2, 5}},
+		{AggregationSum, []float64{3, 12}},
+		{AggregationLast, []float64{2, 5}},
+	}
+	for _, c := range cases {
+		res, err := Aggregate(points, start, 30*time.Second, c.agg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != len(c.want) {
+			t.Fatalf("%s: expected %d points, got %d", c.agg, len(c.want), len(res))
+		}
+		for i, p := range res {
+			if p.Value != c.want[i] {
+				t.Fatalf("%s: point %d expected %v, got %v", c.agg, i, c.want[i], p.Value)
+			}
+			if !p.Timestamp.Equal(start.Add(time.Duration(i) * 30 * time.Second)) {
+				t.Fatalf("%s: unexpected bucket time %v", c.agg, p.Timestamp)
+			}
+		}
+	}
+	if _, err := Aggregate(points, start, time.Second, "median"); err != ErrUnknownAggregation {
+		t.Fatalf("expected unknown aggregation, got %v", err)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	l := Labels{"device": "sda", "host": "a"}
+	if l.String() != `{"device":"sda","host":"a"}` {
+		t.Fatalf("unexpected labels string %s", l.String())
+	}
+	parsed, err := ParseLabels(l.String())
+	if err != nil || parsed.String() != l.String() {
+		t.Fatalf("unexpected parsed labels %v %v", parsed, err)
+	}
+	if !l.Match(Labels{"device": "sda"}) || l.Match(Labels{"device": "sdb"}) {
+		t.Fatal("unexpected match result")
+	}
+}
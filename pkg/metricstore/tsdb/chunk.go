@@ -0,0 +1,255 @@
+// Package tsdb
+// Date: 2024/4/25 10:05
+// Author: Amu
+// Description: Gorilla 压缩块，时间戳使用 delta-of-delta，数值使用 XOR 编码
+package tsdb
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var errEndOfStream = errors.New("end of stream")
+
+type bstream struct {
+	data  []byte
+	count uint8 // 最后一个字节剩余可写的位数
+}
+
+func (b *bstream) writeBit(bit bool) {
+	if b.count == 0 {
+		b.data = append(b.data, 0)
+		b.count = 8
+	}
+	if bit {
+		b.data[len(b.data)-1] |= 1 << (b.count - 1)
+	}
+	b.count--
+}
+
+func (b *bstream) writeBits(u uint64, n int) {
+	for n > 0 {
+		n--
+		b.writeBit((u>>uint(n))&1 == 1)
+	}
+}
+
+type breader struct {
+	data []byte
+	pos  int // 已读取的位数
+}
+
+func (r *breader) readBit() (bool, error) {
+	if r.pos >= len(r.data)*8 {
+		return false, errEndOfStream
+	}
+	bit := r.data[r.pos/8]&(1<<(7-uint(r.pos%8))) != 0
+	r.pos++
+	return bit, nil
+}
+
+func (r *breader) readBits(n int) (uint64, error) {
+	var u uint64
+	for i := 0; i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		u <<= 1
+		if bit {
+			u |= 1
+		}
+	}
+	return u, nil
+}
+
+// chunk 单个序列的一段压缩数据，时间戳单位为毫秒且必须递增
+type chunk struct {
+	b        bstream
+	n        int
+	minT     int64
+	maxT     int64
+	tDelta   int64
+	vLast    uint64
+	leading  uint8
+	trailing uint8
+}
+
+// dod 分段编码: 前缀位数、数值位数
+var dodBuckets = []struct {
+	prefix, prefixLen uint64
+	bits              int
+}{
+	{0b10, 2, 7},
+	{0b110, 3, 9},
+	{0b1110, 4, 12},
+}
+
+func (c *chunk) append(t int64, v float64) {
+	vb := math.Float64bits(v)
+	switch c.n {
+	case 0:
+		c.b.writeBits(uint64(t), 64)
+		c.b.writeBits(vb, 64)
+		c.minT = t
+	default:
+		delta := t - c.maxT
+		dod := delta - c.tDelta
+		c.writeDod(dod)
+		c.tDelta = delta
+		c.writeXOR(vb)
+	}
+	c.maxT = t
+	c.vLast = vb
+	c.n++
+}
+
+func (c *chunk) writeDod(dod int64) {
+	if dod == 0 {
+		c.b.writeBit(false)
+		return
+	}
+	for _, bucket := range dodBuckets {
+		limit := int64(1) << uint(bucket.bits-1)
+		if dod >= -limit+1 && dod <= limit {
+			c.b.writeBits(bucket.prefix, int(bucket.prefixLen))
+			c.b.writeBits(uint64(dod), bucket.bits)
+			return
+		}
+	}
+	c.b.writeBits(0b1111, 4)
+	c.b.writeBits(uint64(dod), 64)
+}
+
+func (c *chunk) writeXOR(vb uint64) {
+	xor := vb ^ c.vLast
+	if xor == 0 {
+		c.b.writeBit(false)
+		return
+	}
+	c.b.writeBit(true)
+	leading := uint8(bits.LeadingZeros64(xor))
+	trailing := uint8(bits.TrailingZeros64(xor))
+	if leading > 31 {
+		leading = 31
+	}
+	if c.leading != 0xff && leading >= c.leading && trailing >= c.trailing {
+		// 复用上一次的有效位窗口
+		c.b.writeBit(false)
+		c.b.writeBits(xor>>c.trailing, 64-int(c.leading)-int(c.trailing))
+		return
+	}
+	c.leading, c.trailing = leading, trailing
+	c.b.writeBit(true)
+	c.b.writeBits(uint64(leading), 5)
+	sigbits := 64 - leading - trailing
+	// 64 位有效数据用 0 表示
+	c.b.writeBits(uint64(sigbits&0x3f), 6)
+	c.b.writeBits(xor>>trailing, int(sigbits))
+}
+
+func newChunk() *chunk {
+	return &chunk{leading: 0xff}
+}
+
+// decodeChunk 解码 n 个采样点
+func decodeChunk(data []byte, n int) ([]int64, []float64, error) {
+	r := &breader{data: data}
+	ts := make([]int64, 0, n)
+	vs := make([]float64, 0, n)
+	if n == 0 {
+		return ts, vs, nil
+	}
+	t, err := r.readBits(64)
+	if err != nil {
+		return nil, nil, err
+	}
+	vb, err := r.readBits(64)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts = append(ts, int64(t))
+	vs = append(vs, math.Float64frombits(vb))
+	var tDelta int64
+	var leading, trailing uint8
+	prevT := int64(t)
+	for i := 1; i < n; i++ {
+		dod, err := readDod(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		tDelta += dod
+		prevT += tDelta
+		ts = append(ts, prevT)
+
+		bit, err := r.readBit()
+		if err != nil {
+			return nil, nil, err
+		}
+		if bit {
+			newWindow, err := r.readBit()
+			if err != nil {
+				return nil, nil, err
+			}
+			if newWindow {
+				l, err := r.readBits(5)
+				if err != nil {
+					return nil, nil, err
+				}
+				sig, err := r.readBits(6)
+				if err != nil {
+					return nil, nil, err
+				}
+				if sig == 0 {
+					sig = 64
+				}
+				leading = uint8(l)
+				trailing = 64 - leading - uint8(sig)
+			}
+			sigbits := 64 - int(leading) - int(trailing)
+			xor, err := r.readBits(sigbits)
+			if err != nil {
+				return nil, nil, err
+			}
+			vb ^= xor << trailing
+		}
+		vs = append(vs, math.Float64frombits(vb))
+	}
+	return ts, vs, nil
+}
+
+func readDod(r *breader) (int64, error) {
+	var prefix int
+	for prefix < 4 {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		prefix++
+	}
+	var n int
+	switch prefix {
+	case 0:
+		return 0, nil
+	case 1, 2, 3:
+		n = dodBuckets[prefix-1].bits
+	default:
+		n = 64
+	}
+	u, err := r.readBits(n)
+	if err != nil {
+		return 0, err
+	}
+	if n == 64 {
+		return int64(u), nil
+	}
+	// 有符号数还原
+	if u > 1<<uint(n-1) {
+		return int64(u) - (1 << uint(n)), nil
+	}
+	return int64(u), nil
+}
@@ -0,0 +1,63 @@
+// Package tsdb
+// Date: 2024/4/25 10:48
+// Author: Amu
+// Description:
+package tsdb
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestChunkRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	c := newChunk()
+	var ts []int64
+	var vs []float64
+	now := int64(1713999600000)
+	for i := 0; i < 500; i++ {
+		// 模拟采集间隔抖动和偶发的长时间中断
+		now += 60000 + int64(r.Intn(200)) - 100
+		if i%97 == 0 {
+			now += 3600000
+		}
+		v := math.Round(r.Float64()*10000) / 100
+		if i%5 == 0 && i > 0 {
+			v = vs[len(vs)-1]
+		}
+		c.append(now, v)
+		ts = append(ts, now)
+		vs = append(vs, v)
+	}
+	gotT, gotV, err := decodeChunk(c.b.data, c.n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range ts {
+		if gotT[i] != ts[i] || gotV[i] != vs[i] {
+			t.Fatalf("sample %d mismatch: got (%d, %v) want (%d, %v)", i, gotT[i], gotV[i], ts[i], vs[i])
+		}
+	}
+	// 压缩后应远小于原始 16 字节/点
+	if len(c.b.data) >= len(ts)*16/2 {
+		t.Fatalf("poor compression: %d bytes for %d samples", len(c.b.data), len(ts))
+	}
+}
+
+func TestChunkSpecialValues(t *testing.T) {
+	values := []float64{0, -1, math.MaxFloat64, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), 1e-300, 42}
+	c := newChunk()
+	for i, v := range values {
+		c.append(int64(i*1000), v)
+	}
+	_, got, err := decodeChunk(c.b.data, c.n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range values {
+		if got[i] != values[i] {
+			t.Fatalf("value %d mismatch: got %v want %v", i, got[i], values[i])
+		}
+	}
+}
@@ -0,0 +1,526 @@
+// Package tsdb
+// Date: 2024/4/25 11:20
+// Author: Amu
+// Description: 嵌入式追加写时序库
+//
+// 目录结构:
+//
+//	series.jsonl        序列定义(ref、指标名、标签)，追加写
+//	wal.log             未落盘样本的预写日志，head 全部落盘后截断
+//	chunks/YYYYMMDD.dat 按天切分的 Gorilla 压缩块，过期后整文件删除
+package tsdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+)
+
+const (
+	seriesFileName = "series.jsonl"
+	walFileName    = "wal.log"
+	chunksDirName  = "chunks"
+	segmentSuffix  = ".dat"
+	segmentLayout  = "20060102"
+
+	defaultChunkSamples  = 120
+	defaultFlushInterval = 10 * time.Minute
+)
+
+var _ metricstore.MetricStore = (*DB)(nil)
+
+var errCorruptRecord = errors.New("corrupt chunk record")
+
+type Options struct {
+	Dir string
+	// Retention 数据保留时长，<= 0 表示不清理
+	Retention time.Duration
+	// ChunkSamples 每个压缩块的样本数
+	ChunkSamples int
+	// FlushInterval head 全部落盘并截断 WAL 的间隔
+	FlushInterval time.Duration
+}
+
+type chunkMeta struct {
+	segment string
+	offset  int64
+	size    int
+	minT    int64
+	maxT    int64
+}
+
+type series struct {
+	ref    uint64
+	metric string
+	labels metricstore.Labels
+	head   *chunk
+	chunks []chunkMeta
+	// maxT 已写入的最大时间戳(毫秒)，用于丢弃乱序样本
+	maxT int64
+}
+
+type seriesRecord struct {
+	Ref    uint64             `json:"ref"`
+	Metric string             `json:"metric"`
+	Labels metricstore.Labels `json:"labels"`
+}
+
+type DB struct {
+	opts Options
+
+	mu         sync.RWMutex
+	series     map[string]*series
+	byRef      map[uint64]*series
+	nextRef    uint64
+	seriesFile *os.File
+	wal        *os.File
+	segments   map[string]*os.File
+	lastFlush  time.Time
+}
+
+func seriesKey(metric string, labels metricstore.Labels) string {
+	return metric + labels.String()
+}
+
+func Open(opts Options) (*DB, error) {
+	if opts.ChunkSamples <= 0 {
+		opts.ChunkSamples = defaultChunkSamples
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if err := os.MkdirAll(filepath.Join(opts.Dir, chunksDirName), 0o755); err != nil {
+		return nil, err
+	}
+	db := &DB{
+		opts:      opts,
+		series:    make(map[string]*series),
+		byRef:     make(map[uint64]*series),
+		segments:  make(map[string]*os.File),
+		lastFlush: time.Now(),
+	}
+	if err := db.loadSeries(); err != nil {
+		return nil, err
+	}
+	if err := db.loadChunks(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+	if err := db.replayWAL(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) loadSeries() error {
+	f, err := os.OpenFile(filepath.Join(db.opts.Dir, seriesFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	db.seriesFile = f
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec seriesRecord
+		// 最后一行可能因异常退出而不完整，直接忽略
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		s := &series{ref: rec.Ref, metric: rec.Metric, labels: rec.Labels, maxT: math.MinInt64}
+		db.series[seriesKey(rec.Metric, rec.Labels)] = s
+		db.byRef[rec.Ref] = s
+		if rec.Ref >= db.nextRef {
+			db.nextRef = rec.Ref + 1
+		}
+	}
+	return scanner.Err()
+}
+
+func (db *DB) loadChunks() error {
+	entries, err := os.ReadDir(filepath.Join(db.opts.Dir, chunksDirName))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		segment := strings.TrimSuffix(name, segmentSuffix)
+		f, err := db.segment(segment)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.NewSectionReader(f, 0, math.MaxInt64))
+		if err != nil {
+			return err
+		}
+		var offset int64
+		for offset < int64(len(data)) {
+			ref, meta, size, err := parseRecord(data[offset:])
+			if err != nil {
+				// 截断写了一半的记录
+				slog.Warn("tsdb truncate corrupt segment", "segment", name, "offset", offset, "error", err)
+				if err := f.Truncate(offset); err != nil {
+					return err
+				}
+				break
+			}
+			meta.segment = segment
+			meta.offset = offset
+			if s, ok := db.byRef[ref]; ok {
+				s.chunks = append(s.chunks, meta)
+				if meta.maxT > s.maxT {
+					s.maxT = meta.maxT
+				}
+			}
+			offset += int64(size)
+		}
+	}
+	return nil
+}
+
+func (db *DB) replayWAL() error {
+	path := filepath.Join(db.opts.Dir, walFileName)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		ref, err := binary.ReadUvarint(r)
+		if err != nil {
+			break
+		}
+		t, err := binary.ReadVarint(r)
+		if err != nil {
+			break
+		}
+		var vb uint64
+		if err := binary.Read(r, binary.BigEndian, &vb); err != nil {
+			break
+		}
+		if s, ok := db.byRef[ref]; ok {
+			db.appendHead(s, t, math.Float64frombits(vb))
+		}
+	}
+	db.wal, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	return err
+}
+
+func (db *DB) segment(name string) (*os.File, error) {
+	if f, ok := db.segments[name]; ok {
+		return f, nil
+	}
+	path := filepath.Join(db.opts.Dir, chunksDirName, name+segmentSuffix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	db.segments[name] = f
+	return f, nil
+}
+
+// 块记录格式: [4 字节 body 长度][body][4 字节 CRC32]
+// body: uvarint ref | varint minT | varint maxT | uvarint 样本数 | 压缩数据
+func encodeRecord(ref uint64, c *chunk) []byte {
+	body := make([]byte, 0, len(c.b.data)+4*binary.MaxVarintLen64)
+	body = binary.AppendUvarint(body, ref)
+	body = binary.AppendVarint(body, c.minT)
+	body = binary.AppendVarint(body, c.maxT)
+	body = binary.AppendUvarint(body, uint64(c.n))
+	body = append(body, c.b.data...)
+	rec := make([]byte, 4, len(body)+8)
+	binary.BigEndian.PutUint32(rec, uint32(len(body)))
+	rec = append(rec, body...)
+	return binary.BigEndian.AppendUint32(rec, crc32.ChecksumIEEE(body))
+}
+
+func parseRecord(data []byte) (uint64, chunkMeta, int, error) {
+	var meta chunkMeta
+	if len(data) < 8 {
+		return 0, meta, 0, errCorruptRecord
+	}
+	n := int(binary.BigEndian.Uint32(data))
+	if len(data) < n+8 {
+		return 0, meta, 0, errCorruptRecord
+	}
+	body := data[4 : 4+n]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[4+n:]) {
+		return 0, meta, 0, errCorruptRecord
+	}
+	r := bytes.NewReader(body)
+	ref, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, meta, 0, errCorruptRecord
+	}
+	if meta.minT, err = binary.ReadVarint(r); err != nil {
+		return 0, meta, 0, errCorruptRecord
+	}
+	if meta.maxT, err = binary.ReadVarint(r); err != nil {
+		return 0, meta, 0, errCorruptRecord
+	}
+	meta.size = n + 8
+	return ref, meta, n + 8, nil
+}
+
+func (db *DB) readChunk(meta chunkMeta) ([]int64, []float64, error) {
+	f, ok := db.segments[meta.segment]
+	if !ok {
+		return nil, nil, os.ErrNotExist
+	}
+	data := make([]byte, meta.size)
+	if _, err := f.ReadAt(data, meta.offset); err != nil {
+		return nil, nil, err
+	}
+	body := data[4 : meta.size-4]
+	r := bytes.NewReader(body)
+	for i := 0; i < 3; i++ {
+		if _, err := binary.ReadVarint(r); err != nil {
+			return nil, nil, errCorruptRecord
+		}
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, nil, errCorruptRecord
+	}
+	return decodeChunk(body[len(body)-r.Len():], int(n))
+}
+
+// appendHead 写入 head，乱序或重复的样本直接丢弃
+func (db *DB) appendHead(s *series, t int64, v float64) bool {
+	if t <= s.maxT {
+		return false
+	}
+	if s.head == nil {
+		s.head = newChunk()
+	}
+	s.head.append(t, v)
+	s.maxT = t
+	return true
+}
+
+// cut 将 head 写入按天切分的块文件
+func (db *DB) cut(s *series) error {
+	if s.head == nil || s.head.n == 0 {
+		return nil
+	}
+	name := time.UnixMilli(s.head.minT).UTC().Format(segmentLayout)
+	f, err := db.segment(name)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	rec := encodeRecord(s.ref, s.head)
+	if _, err := f.Write(rec); err != nil {
+		return err
+	}
+	s.chunks = append(s.chunks, chunkMeta{segment: name, offset: info.Size(), size: len(rec), minT: s.head.minT, maxT: s.head.maxT})
+	s.head = nil
+	return nil
+}
+
+func (db *DB) getOrCreate(metric string, labels metricstore.Labels) (*series, error) {
+	key := seriesKey(metric, labels)
+	if s, ok := db.series[key]; ok {
+		return s, nil
+	}
+	copied := make(metricstore.Labels, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	s := &series{ref: db.nextRef, metric: metric, labels: copied, maxT: math.MinInt64}
+	data, err := json.Marshal(seriesRecord{Ref: s.ref, Metric: metric, Labels: copied})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.seriesFile.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+	db.nextRef++
+	db.series[key] = s
+	db.byRef[s.ref] = s
+	return s, nil
+}
+
+func (db *DB) Write(ctx context.Context, samples []metricstore.Sample) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var wal []byte
+	for _, sample := range samples {
+		s, err := db.getOrCreate(sample.Metric, sample.Labels)
+		if err != nil {
+			return err
+		}
+		t := sample.Timestamp.UnixMilli()
+		if !db.appendHead(s, t, sample.Value) {
+			continue
+		}
+		wal = binary.AppendUvarint(wal, s.ref)
+		wal = binary.AppendVarint(wal, t)
+		wal = binary.BigEndian.AppendUint64(wal, math.Float64bits(sample.Value))
+		if s.head.n >= db.opts.ChunkSamples {
+			if err := db.cut(s); err != nil {
+				return err
+			}
+		}
+	}
+	if len(wal) > 0 {
+		if _, err := db.wal.Write(wal); err != nil {
+			return err
+		}
+	}
+	if time.Since(db.lastFlush) >= db.opts.FlushInterval {
+		return db.flush()
+	}
+	return nil
+}
+
+// flush 所有 head 落盘、截断 WAL 并清理过期数据，调用方需持有写锁
+func (db *DB) flush() error {
+	for _, s := range db.series {
+		if err := db.cut(s); err != nil {
+			return err
+		}
+	}
+	for _, f := range db.segments {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := db.seriesFile.Sync(); err != nil {
+		return err
+	}
+	if err := db.wal.Truncate(0); err != nil {
+		return err
+	}
+	db.lastFlush = time.Now()
+	return db.applyRetention()
+}
+
+func (db *DB) applyRetention() error {
+	if db.opts.Retention <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-db.opts.Retention).UTC()
+	expired := make(map[string]struct{})
+	entries, err := os.ReadDir(filepath.Join(db.opts.Dir, chunksDirName))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), segmentSuffix)
+		day, err := time.Parse(segmentLayout, name)
+		if err != nil {
+			continue
+		}
+		// 当天的块可能包含跨天的样本，整天过期后再删除
+		if day.Add(48 * time.Hour).Before(cutoff) {
+			expired[name] = struct{}{}
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	for name := range expired {
+		if f, ok := db.segments[name]; ok {
+			_ = f.Close()
+			delete(db.segments, name)
+		}
+		if err := os.Remove(filepath.Join(db.opts.Dir, chunksDirName, name+segmentSuffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for _, s := range db.series {
+		kept := s.chunks[:0]
+		for _, meta := range s.chunks {
+			if _, ok := expired[meta.segment]; !ok {
+				kept = append(kept, meta)
+			}
+		}
+		s.chunks = kept
+	}
+	return nil
+}
+
+func (db *DB) Query(ctx context.Context, q metricstore.Query) ([]metricstore.Series, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	minT, maxT := q.Start.UnixMilli(), q.End.UnixMilli()
+	var res []metricstore.Series
+	for _, s := range db.series {
+		if s.metric != q.Metric || !s.labels.Match(q.Labels) {
+			continue
+		}
+		var points []metricstore.Point
+		collect := func(ts []int64, vs []float64) {
+			for i, t := range ts {
+				if t >= minT && t <= maxT {
+					points = append(points, metricstore.Point{Timestamp: time.UnixMilli(t), Value: vs[i]})
+				}
+			}
+		}
+		for _, meta := range s.chunks {
+			if meta.maxT < minT || meta.minT > maxT {
+				continue
+			}
+			ts, vs, err := db.readChunk(meta)
+			if err != nil {
+				return nil, err
+			}
+			collect(ts, vs)
+		}
+		if s.head != nil && s.head.n > 0 && s.head.maxT >= minT && s.head.minT <= maxT {
+			ts, vs, err := decodeChunk(s.head.b.data, s.head.n)
+			if err != nil {
+				return nil, err
+			}
+			collect(ts, vs)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		res = append(res, metricstore.Series{Metric: s.metric, Labels: s.labels, Points: points})
+	}
+	return metricstore.Finalize(res, q)
+}
+
+func (db *DB) closeFiles() {
+	for _, f := range db.segments {
+		_ = f.Close()
+	}
+	if db.seriesFile != nil {
+		_ = db.seriesFile.Close()
+	}
+	if db.wal != nil {
+		_ = db.wal.Close()
+	}
+}
+
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	err := db.flush()
+	db.closeFiles()
+	return err
+}
@@ -0,0 +1,160 @@
+// Package tsdb
+// Date: 2024/4/25 14:30
+// Author: Amu
+// Description:
+package tsdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+)
+
+func writeSamples(t *testing.T, db *DB, start time.Time, n int) {
+	t.Helper()
+	var samples []metricstore.Sample
+	for i := 0; i < n; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		samples = append(samples,
+			metricstore.Sample{Metric: "cpu_percent", Timestamp: ts, Value: float64(i)},
+			metricstore.Sample{Metric: "disk_read", Labels: metricstore.Labels{"device": "sda"}, Timestamp: ts, Value: float64(i * 2)},
+			metricstore.Sample{Metric: "disk_read", Labels: metricstore.Labels{"device": "sdb"}, Timestamp: ts, Value: float64(i * 3)},
+		)
+	}
+	if err := db.Write(context.Background(), samples); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteQueryReopen(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(Options{Dir: dir, ChunkSamples: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Minute)
+	writeSamples(t, db, start, 150)
+
+	check := func(db *DB) {
+		t.Helper()
+		res, err := db.Query(context.Background(), metricstore.Query{
+			Metric: "disk_read",
+			Labels: metricstore.Labels{"device": "sdb"},
+			Start:  start,
+			End:    start.Add(149 * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || len(res[0].Points) != 150 {
+			t.Fatalf("unexpected result: %d series", len(res))
+		}
+		if res[0].Points[149].Value != 447 || !res[0].Points[10].Timestamp.Equal(start.Add(10*time.Minute)) {
+			t.Fatalf("unexpected point: %+v", res[0].Points[149])
+		}
+
+		res, err = db.Query(context.Background(), metricstore.Query{
+			Metric:      "cpu_percent",
+			Start:       start,
+			End:         start.Add(time.Hour),
+			Step:        30 * time.Minute,
+			Aggregation: metricstore.AggregationMax,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || len(res[0].Points) != 3 || res[0].Points[1].Value != 59 {
+			t.Fatalf("unexpected aggregated result: %+v", res)
+		}
+	}
+	check(db)
+
+	// 未落盘的 head 通过 WAL 恢复
+	db.closeFiles()
+	db, err = Open(Options{Dir: dir, ChunkSamples: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(db)
+
+	// 乱序样本被丢弃
+	if err := db.Write(context.Background(), []metricstore.Sample{{Metric: "cpu_percent", Timestamp: start, Value: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(Options{Dir: dir, ChunkSamples: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	check(db)
+}
+
+func TestTruncatedSegment(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(Options{Dir: dir, ChunkSamples: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	writeSamples(t, db, start, 20)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写入一半时异常退出
+	segment := filepath.Join(dir, chunksDirName, start.UTC().Format(segmentLayout)+segmentSuffix)
+	f, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{0, 0, 1, 0, 42})
+	_ = f.Close()
+
+	db, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.Query(context.Background(), metricstore.Query{Metric: "cpu_percent", Start: start, End: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Points) != 20 {
+		t.Fatalf("unexpected result after truncation: %+v", res)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(Options{Dir: dir, Retention: 24 * time.Hour, ChunkSamples: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	writeSamples(t, db, old, 10)
+	writeSamples(t, db, time.Now().Add(-time.Hour), 10)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, chunksDirName, old.UTC().Format(segmentLayout)+segmentSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("expected expired segment to be removed, got %v", err)
+	}
+	db, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.Query(context.Background(), metricstore.Query{Metric: "cpu_percent", Start: old, End: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0].Points) != 10 {
+		t.Fatalf("unexpected result after retention: %+v", res)
+	}
+}
@@ -0,0 +1,117 @@
+// Package migrate
+// Date: 2024/4/24 09:20
+// Author: Amu
+// Description: 版本化数据库迁移，已执行的版本记录在 schema_migrations 表中
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次数据库变更，Version 递增且不可重复
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// Record 已执行的迁移记录
+type Record struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (r *Record) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func sorted(migrations []Migration) ([]Migration, error) {
+	list := make([]Migration, len(migrations))
+	copy(list, migrations)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := range list {
+		if list[i].Version <= 0 {
+			return nil, fmt.Errorf("invalid migration version %d", list[i].Version)
+		}
+		if i > 0 && list[i].Version == list[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", list[i].Version)
+		}
+	}
+	return list, nil
+}
+
+func applied(db *gorm.DB) (map[int]Record, error) {
+	if err := db.AutoMigrate(&Record{}); err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[int]Record, len(records))
+	for _, r := range records {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// Up 按版本顺序执行所有未执行的迁移，返回本次执行的版本
+func Up(db *gorm.DB, migrations []Migration) ([]int, error) {
+	list, err := sorted(migrations)
+	if err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for _, m := range list {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		// MySQL 的 DDL 会隐式提交，事务只能保证 PostgreSQL/SQLite 上的原子性
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&Record{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return versions, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		versions = append(versions, m.Version)
+	}
+	return versions, nil
+}
+
+// List 返回每个迁移的执行状态
+func List(db *gorm.DB, migrations []Migration) ([]Status, error) {
+	list, err := sorted(migrations)
+	if err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(list))
+	for _, m := range list {
+		r, ok := done[m.Version]
+		res = append(res, Status{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: r.AppliedAt})
+	}
+	return res, nil
+}
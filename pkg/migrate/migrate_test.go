@@ -0,0 +1,93 @@
+// Package migrate
+// Date: 2024/4/24 10:02
+// Author: Amu
+// Description:
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUp(t *testing.T) {
+	db := openTestDB(t)
+	calls := 0
+	migrations := []Migration{
+		{Version: 2, Name: "add index", Up: func(tx *gorm.DB) error {
+			calls++
+			return tx.Exec("CREATE INDEX idx_widget_name ON widgets(name)").Error
+		}},
+		{Version: 1, Name: "create widgets", Up: func(tx *gorm.DB) error {
+			calls++
+			return tx.Migrator().CreateTable(&widget{})
+		}},
+	}
+	versions, err := Up(db, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Fatalf("unexpected versions: %v", versions)
+	}
+	// 再次执行不会重复迁移
+	versions, err = Up(db, migrations)
+	if err != nil || len(versions) != 0 || calls != 2 {
+		t.Fatalf("expected no migration, got %v %v calls=%d", versions, err, calls)
+	}
+	status, err := List(db, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if !s.Applied {
+			t.Fatalf("migration %d not applied", s.Version)
+		}
+	}
+}
+
+func TestUpFailed(t *testing.T) {
+	db := openTestDB(t)
+	migrations := []Migration{
+		{Version: 1, Name: "create widgets", Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&widget{})
+		}},
+		{Version: 2, Name: "broken", Up: func(tx *gorm.DB) error {
+			return errors.New("boom")
+		}},
+	}
+	versions, err := Up(db, migrations)
+	if err == nil || len(versions) != 1 {
+		t.Fatalf("expected failure after version 1, got %v %v", versions, err)
+	}
+	status, _ := List(db, migrations)
+	if !status[0].Applied || status[1].Applied {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestDuplicateVersion(t *testing.T) {
+	db := openTestDB(t)
+	noop := func(tx *gorm.DB) error { return nil }
+	_, err := Up(db, []Migration{{Version: 1, Up: noop}, {Version: 1, Up: noop}})
+	if err == nil {
+		t.Fatal("expected duplicate version error")
+	}
+}
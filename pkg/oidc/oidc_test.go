@@ -0,0 +1,141 @@
+// Package oidc
+// Date: 2024/4/17 14:20
+// Author: Amu
+// Description:
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// mockProvider 本地模拟的 OIDC provider
+type mockProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T, clientID string) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key, clientID: clientID}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Discovery{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []jwk{{
+				Kid: "test",
+				Kty: "RSA",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("code") != "good-code" || Challenge(r.Form.Get("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   m.server.URL,
+			"aud":   m.clientID,
+			"sub":   "1234",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": m.nonce,
+		}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		raw, _ := token.SignedString(key)
+		_ = json.NewEncoder(w).Encode(TokenResponse{AccessToken: "at", TokenType: "Bearer", IDToken: raw})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	mock := newMockProvider(t, "amprobe")
+	mock.claims = jwt.MapClaims{"preferred_username": "alice", "groups": []string{"dev", "ops"}}
+	p := NewProvider(&Config{Issuer: mock.server.URL, ClientID: "amprobe", RedirectURL: "http://localhost/callback"})
+
+	verifier, state, nonce := NewVerifier(), NewState(), NewState()
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("state") != state {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+	// 模拟用户在 provider 完成登录
+	mock.challenge = q.Get("code_challenge")
+	mock.nonce = q.Get("nonce")
+
+	if _, err := p.Exchange(ctx, "good-code", "wrong-verifier"); err == nil {
+		t.Fatal("exchange with wrong verifier succeeded")
+	}
+	token, err := p.Exchange(ctx, "good-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["preferred_username"] != "alice" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+	if groups := ClaimValues(claims, "groups"); len(groups) != 2 || groups[1] != "ops" {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+	if _, err := p.VerifyIDToken(ctx, token.IDToken, "other"); err != ErrNonceMismatch {
+		t.Fatalf("expected nonce mismatch, got %v", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsAudience(t *testing.T) {
+	ctx := context.Background()
+	mock := newMockProvider(t, "someone-else")
+	p := NewProvider(&Config{Issuer: mock.server.URL, ClientID: "amprobe"})
+	verifier := NewVerifier()
+	mock.challenge = Challenge(verifier)
+	token, err := p.Exchange(ctx, "good-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyIDToken(ctx, token.IDToken, ""); err != ErrInvalidIDToken {
+		t.Fatalf("expected invalid id token, got %v", err)
+	}
+}
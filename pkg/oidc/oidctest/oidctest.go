@@ -0,0 +1,109 @@
+// Package oidctest
+// Date: 2024/5/10 16:30
+// Author: Amu
+// Description: 测试用的本地 OIDC provider，授权码固定为 Code，PKCE 和 nonce 从授权地址中读取
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/oidc"
+	"github.com/golang-jwt/jwt"
+)
+
+// Code provider 接受的授权码
+const Code = "good-code"
+
+type Provider struct {
+	URL      string
+	ClientID string
+	// Claims 附加到 id token 中的声明，如 preferred_username
+	Claims jwt.MapClaims
+
+	key       *rsa.PrivateKey
+	mu        sync.Mutex
+	challenge string
+	nonce     string
+}
+
+func New(t *testing.T, clientID string) *Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{ClientID: clientID, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidc.Discovery{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			JWKSURI:               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	p.URL = server.URL
+	return p
+}
+
+// Authorize 模拟用户在 provider 完成登录，记录授权地址中的 code_challenge 和 nonce
+func (p *Provider) Authorize(t *testing.T, authURL string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.challenge = u.Query().Get("code_challenge")
+	p.nonce = u.Query().Get("nonce")
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r.Form.Get("code") != Code || oidc.Challenge(r.Form.Get("code_verifier")) != p.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	claims := jwt.MapClaims{
+		"iss":   p.URL,
+		"aud":   p.ClientID,
+		"sub":   "1234",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": p.nonce,
+	}
+	for k, v := range p.Claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	raw, _ := token.SignedString(p.key)
+	_ = json.NewEncoder(w).Encode(oidc.TokenResponse{AccessToken: "at", TokenType: "Bearer", IDToken: raw})
+}
@@ -0,0 +1,25 @@
+// Package psutil
+// Date: 2024/4/29 10:10
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"math"
+	"math/bits"
+)
+
+// KernelCounterBits 内核以 unsigned long 保存的计数(/proc/diskstats、/proc/vmstat)的位宽，32 位内核上会回绕
+const KernelCounterBits = bits.UintSize
+
+// CounterDelta 计算累计计数的增量，width 为计数的位宽。只有已知为 32 位的计数变小时才视为回绕，
+// 其余计数变小都视为计数被重置(设备重新挂载、网卡重建、宿主机重启等)，返回 0, false
+func CounterDelta(prev, cur uint64, width int) (uint64, bool) {
+	if cur >= prev {
+		return cur - prev, true
+	}
+	if width == 32 && prev <= math.MaxUint32 && prev > math.MaxUint32/2 {
+		return cur + (math.MaxUint32 - prev) + 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,36 @@
+// Package psutil
+// Date: 2024/4/29 10:10
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCounterDelta(t *testing.T) {
+	cases := []struct {
+		prev, cur uint64
+		width     int
+		delta     uint64
+		ok        bool
+	}{
+		{prev: 100, cur: 150, width: 64, delta: 50, ok: true},
+		{prev: 100, cur: 100, width: 64, delta: 0, ok: true},
+		// 32 位计数回绕
+		{prev: math.MaxUint32 - 9, cur: 5, width: 32, delta: 15, ok: true},
+		// 64 位计数在 32 位上限附近被重置，不能视为回绕
+		{prev: math.MaxUint32 - 9, cur: 5, width: 64, ok: false},
+		// 64 位计数被重置
+		{prev: math.MaxUint32 + 100, cur: 10, width: 64, ok: false},
+		// 较小的值变小视为重置
+		{prev: 1000, cur: 10, width: 32, ok: false},
+	}
+	for _, c := range cases {
+		delta, ok := CounterDelta(c.prev, c.cur, c.width)
+		if delta != c.delta || ok != c.ok {
+			t.Fatalf("CounterDelta(%d, %d, %d) = %d, %v, expected %d, %v", c.prev, c.cur, c.width, delta, ok, c.delta, c.ok)
+		}
+	}
+}
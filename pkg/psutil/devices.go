@@ -0,0 +1,105 @@
+// Package psutil
+// Date: 2024/5/10 10:00
+// Author: Amu
+// Description: 枚举主机磁盘设备和网卡，用于配置校验和生成默认配置
+package psutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// 虚拟磁盘设备前缀
+var virtualDiskPrefixes = []string{"loop", "ram", "zram", "sr", "fd", "nbd"}
+
+// 虚拟网卡前缀，容器、网桥、隧道等
+var virtualNICPrefixes = []string{"lo", "docker", "veth", "br-", "virbr", "cni", "flannel", "cali", "vxlan", "tunl", "kube-", "podman", "cilium", "weave", "dummy", "ifb"}
+
+// DiskDevices 返回可采集 IO 的磁盘设备，如 /dev/sda、/dev/sda1，按名称排序
+func DiskDevices() ([]string, error) {
+	stat, err := disk.IOCounters()
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]string, 0, len(stat))
+	for name := range stat {
+		devices = append(devices, "/dev/"+name)
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// NetInterfaces 返回可采集 IO 的网卡，按名称排序
+func NetInterfaces() ([]string, error) {
+	stat, err := net.IOCounters(true)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(stat))
+	for _, s := range stat {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// WholeDisks 过滤掉虚拟设备，以及所属磁盘也在列表中的分区，如同时存在 /dev/sda 和 /dev/sda1 时只保留 /dev/sda
+func WholeDisks(devices []string) []string {
+	set := make(map[string]struct{}, len(devices))
+	for _, device := range devices {
+		set[device] = struct{}{}
+	}
+	var disks []string
+	for _, device := range devices {
+		name := strings.TrimPrefix(device, "/dev/")
+		if hasPrefix(name, virtualDiskPrefixes) {
+			continue
+		}
+		if parent, ok := parentDisk(device); ok {
+			if _, exists := set[parent]; exists {
+				continue
+			}
+		}
+		disks = append(disks, device)
+	}
+	return disks
+}
+
+// parentDisk 分区所属的磁盘，sda1 -> sda，nvme0n1p1 -> nvme0n1，mmcblk0p1 -> mmcblk0
+func parentDisk(device string) (string, bool) {
+	trimmed := strings.TrimRight(device, "0123456789")
+	if trimmed == device {
+		return "", false
+	}
+	if strings.HasSuffix(trimmed, "p") {
+		base := strings.TrimSuffix(trimmed, "p")
+		if base != "" && base[len(base)-1] >= '0' && base[len(base)-1] <= '9' {
+			return base, true
+		}
+	}
+	return trimmed, true
+}
+
+// PhysicalInterfaces 过滤掉回环、容器和网桥等虚拟网卡
+func PhysicalInterfaces(names []string) []string {
+	var nics []string
+	for _, name := range names {
+		if hasPrefix(name, virtualNICPrefixes) {
+			continue
+		}
+		nics = append(nics, name)
+	}
+	return nics
+}
+
+func hasPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+// Package psutil
+// Date: 2024/5/10 10:00
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWholeDisks(t *testing.T) {
+	devices := []string{"/dev/loop0", "/dev/mmcblk0", "/dev/mmcblk0p1", "/dev/nvme0n1", "/dev/nvme0n1p1", "/dev/nvme0n1p2", "/dev/sda", "/dev/sda1", "/dev/sdb1", "/dev/sr0", "/dev/vda"}
+	expected := []string{"/dev/mmcblk0", "/dev/nvme0n1", "/dev/sda", "/dev/sdb1", "/dev/vda"}
+	if disks := WholeDisks(devices); !reflect.DeepEqual(disks, expected) {
+		t.Fatalf("unexpected disks: %v", disks)
+	}
+}
+
+func TestPhysicalInterfaces(t *testing.T) {
+	names := []string{"br-1a2b3c", "cni0", "docker0", "eno1", "enp3s0", "eth0", "flannel.1", "ifb0", "lo", "veth12ab", "wlan0"}
+	expected := []string{"eno1", "enp3s0", "eth0", "wlan0"}
+	if nics := PhysicalInterfaces(names); !reflect.DeepEqual(nics, expected) {
+		t.Fatalf("unexpected interfaces: %v", nics)
+	}
+}
+
+func TestDiskDevices(t *testing.T) {
+	devices, err := DiskDevices()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Log(devices, WholeDisks(devices))
+}
@@ -0,0 +1,65 @@
+// Package psutil
+// Date: 2024/4/28 15:30
+// Author: Amu
+// Description:
+package psutil
+
+import "time"
+
+// DiskStat 两次采样间的磁盘指标，含义与 iostat -x 一致
+type DiskStat struct {
+	// ReadBytes / WriteBytes 读写速率，单位 B/s
+	ReadBytes  float64
+	WriteBytes float64
+	ReadIOPS   float64
+	WriteIOPS  float64
+	// Await 平均每次 IO 的耗时(含排队)，单位 ms
+	Await float64
+	// QueueDepth 平均队列深度
+	QueueDepth float64
+	// Util 设备忙碌时间占比(%)
+	Util float64
+}
+
+// DiskRate 根据相邻两次采样计算整个间隔内的平均值，elapsed 为两次采样的实际间隔。
+// 间隔无效或有计数被重置时返回 false
+func DiskRate(prev, cur DiskIO, elapsed time.Duration) (DiskStat, bool) {
+	var stat DiskStat
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return stat, false
+	}
+	var deltas [8]uint64
+	pairs := [8][2]uint64{
+		{prev.Read, cur.Read},
+		{prev.Write, cur.Write},
+		{prev.ReadCount, cur.ReadCount},
+		{prev.WriteCount, cur.WriteCount},
+		{prev.ReadTime, cur.ReadTime},
+		{prev.WriteTime, cur.WriteTime},
+		{prev.IoTime, cur.IoTime},
+		{prev.WeightedIO, cur.WeightedIO},
+	}
+	for i, p := range pairs {
+		d, ok := CounterDelta(p[0], p[1], KernelCounterBits)
+		if !ok {
+			return stat, false
+		}
+		deltas[i] = d
+	}
+	ms := seconds * 1000
+	reads, writes := float64(deltas[2]), float64(deltas[3])
+	stat.ReadBytes = float64(deltas[0]) / seconds
+	stat.WriteBytes = float64(deltas[1]) / seconds
+	stat.ReadIOPS = reads / seconds
+	stat.WriteIOPS = writes / seconds
+	if reads+writes > 0 {
+		stat.Await = float64(deltas[4]+deltas[5]) / (reads + writes)
+	}
+	stat.QueueDepth = float64(deltas[7]) / ms
+	stat.Util = float64(deltas[6]) / ms * 100
+	if stat.Util > 100 {
+		stat.Util = 100
+	}
+	return stat, true
+}
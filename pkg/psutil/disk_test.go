@@ -0,0 +1,49 @@
+// Package psutil
+// Date: 2024/4/28 15:30
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDiskRate(t *testing.T) {
+	prev := DiskIO{Read: 1000, Write: 2000, ReadCount: 10, WriteCount: 20, ReadTime: 100, WriteTime: 200, IoTime: 500, WeightedIO: 1000}
+	cur := DiskIO{Read: 5000, Write: 10000, ReadCount: 30, WriteCount: 60, ReadTime: 200, WriteTime: 500, IoTime: 1500, WeightedIO: 5000}
+	stat, ok := DiskRate(prev, cur, 2*time.Second)
+	if !ok {
+		t.Fatal("expected valid disk stat")
+	}
+	expected := DiskStat{
+		ReadBytes:  2000,
+		WriteBytes: 4000,
+		ReadIOPS:   10,
+		WriteIOPS:  20,
+		Await:      400.0 / 60,
+		QueueDepth: 2,
+		Util:       50,
+	}
+	if math.Abs(stat.Await-expected.Await) > 1e-9 {
+		t.Fatalf("unexpected await: %v", stat.Await)
+	}
+	stat.Await = expected.Await
+	if stat != expected {
+		t.Fatalf("unexpected disk stat: %+v", stat)
+	}
+
+	// 多队列设备的 io_time 可能超过采样间隔
+	cur.IoTime = prev.IoTime + 3000
+	if stat, _ := DiskRate(prev, cur, 2*time.Second); stat.Util != 100 {
+		t.Fatalf("expected util capped at 100, got %v", stat.Util)
+	}
+	if stat, ok := DiskRate(prev, prev, time.Second); !ok || stat != (DiskStat{}) {
+		t.Fatalf("expected idle device, got %+v", stat)
+	}
+	// 重新挂载后计数清零
+	if _, ok := DiskRate(cur, prev, time.Second); ok {
+		t.Fatal("expected counter reset to be rejected")
+	}
+}
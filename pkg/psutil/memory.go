@@ -0,0 +1,65 @@
+// Package psutil
+// Date: 2024/4/28 10:05
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"os"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+type MemoryStat struct {
+	Total       uint64
+	Used        uint64
+	UsedPercent float64
+	// Available 可用内存，包含可回收的页缓存
+	Available uint64
+	Buffers   uint64
+	Cached    uint64
+	SwapTotal uint64
+	SwapUsed  uint64
+	// SwapIn / SwapOut 开机以来累计换入/换出的字节数
+	SwapIn  uint64
+	SwapOut uint64
+}
+
+func GetMemoryStat() (*MemoryStat, error) {
+	v, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	stat := &MemoryStat{
+		Total:       v.Total,
+		Used:        v.Used,
+		UsedPercent: v.UsedPercent,
+		Available:   v.Available,
+		Buffers:     v.Buffers,
+		Cached:      v.Cached,
+	}
+	// 部分平台或容器内无法读取 swap 信息，不影响内存指标
+	if s, err := mem.SwapMemory(); err == nil {
+		stat.SwapTotal = s.Total
+		stat.SwapUsed = s.Used
+		stat.SwapIn = s.Sin
+		stat.SwapOut = s.Sout
+	}
+	return stat, nil
+}
+
+// hostProc 与 gopsutil 一致，通过 HOST_PROC 指定宿主机 /proc 的挂载位置
+func hostProc() string {
+	if p := os.Getenv("HOST_PROC"); p != "" {
+		return p
+	}
+	return "/proc"
+}
+
+// hostSys 与 gopsutil 一致，通过 HOST_SYS 指定宿主机 /sys 的挂载位置
+func hostSys() string {
+	if p := os.Getenv("HOST_SYS"); p != "" {
+		return p
+	}
+	return "/sys"
+}
@@ -0,0 +1,144 @@
+// Package psutil
+// Date: 2024/4/28 17:20
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// NetStat 两次采样间的网卡指标，均为每秒速率
+type NetStat struct {
+	// RecvBytes / SendBytes 单位 B/s
+	RecvBytes   float64
+	SendBytes   float64
+	PacketsRecv float64
+	PacketsSent float64
+	ErrIn       float64
+	ErrOut      float64
+	DropIn      float64
+	DropOut     float64
+}
+
+// NetRate 根据相邻两次采样计算整个间隔内的平均速率，elapsed 为两次采样的实际间隔。
+// 间隔无效或有计数被重置时返回 false
+func NetRate(prev, cur NetIO, elapsed time.Duration) (NetStat, bool) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return NetStat{}, false
+	}
+	valid := true
+	rate := func(prev, cur uint64) float64 {
+		// /proc/net/dev 的计数在 32 位内核上同样是 64 位
+		d, ok := CounterDelta(prev, cur, 64)
+		if !ok {
+			valid = false
+		}
+		return float64(d) / seconds
+	}
+	stat := NetStat{
+		RecvBytes:   rate(prev.Recv, cur.Recv),
+		SendBytes:   rate(prev.Send, cur.Send),
+		PacketsRecv: rate(prev.PacketsRecv, cur.PacketsRecv),
+		PacketsSent: rate(prev.PacketsSent, cur.PacketsSent),
+		ErrIn:       rate(prev.ErrIn, cur.ErrIn),
+		ErrOut:      rate(prev.ErrOut, cur.ErrOut),
+		DropIn:      rate(prev.DropIn, cur.DropIn),
+		DropOut:     rate(prev.DropOut, cur.DropOut),
+	}
+	if !valid {
+		return NetStat{}, false
+	}
+	return stat, true
+}
+
+// ListenPort 监听端口及所属进程，无权限读取时 PID 为 0
+type ListenPort struct {
+	Protocol string
+	Address  string
+	Port     uint32
+	PID      int32
+	Process  string
+}
+
+// ConnectionStat TCP 各状态连接数及监听端口
+type ConnectionStat struct {
+	States  map[string]int
+	Listens []ListenPort
+}
+
+// GetConnections 统计本机 IPv4/IPv6 的 TCP、UDP 连接
+func GetConnections() (ConnectionStat, error) {
+	conns, err := net.Connections("inet")
+	if err != nil {
+		return ConnectionStat{States: map[string]int{}}, err
+	}
+	stat := summarizeConnections(conns)
+	names := make(map[int32]string)
+	for i := range stat.Listens {
+		pid := stat.Listens[i].PID
+		if pid == 0 {
+			continue
+		}
+		name, ok := names[pid]
+		if !ok {
+			if p, err := process.NewProcess(pid); err == nil {
+				name, _ = p.Name()
+			}
+			names[pid] = name
+		}
+		stat.Listens[i].Process = name
+	}
+	return stat, nil
+}
+
+// summarizeConnections TCP 按状态计数；TCP 的 LISTEN 及未连接的 UDP 视为监听端口
+func summarizeConnections(conns []net.ConnectionStat) ConnectionStat {
+	stat := ConnectionStat{States: make(map[string]int)}
+	seen := make(map[ListenPort]struct{})
+	for _, c := range conns {
+		protocol := ""
+		switch c.Type {
+		case syscall.SOCK_STREAM:
+			protocol = "tcp"
+			stat.States[c.Status]++
+			if c.Status != "LISTEN" {
+				continue
+			}
+		case syscall.SOCK_DGRAM:
+			protocol = "udp"
+			if c.Raddr.Port != 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		if c.Family == syscall.AF_INET6 {
+			protocol += "6"
+		}
+		listen := ListenPort{Protocol: protocol, Address: c.Laddr.IP, Port: c.Laddr.Port, PID: c.Pid}
+		if _, ok := seen[listen]; ok {
+			// SO_REUSEPORT 时同一进程可能有多个监听 socket
+			continue
+		}
+		seen[listen] = struct{}{}
+		stat.Listens = append(stat.Listens, listen)
+	}
+	sort.Slice(stat.Listens, func(i, j int) bool {
+		a, b := stat.Listens[i], stat.Listens[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Address < b.Address
+	})
+	return stat
+}
@@ -0,0 +1,63 @@
+// Package psutil
+// Date: 2024/4/28 17:20
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+func TestNetRate(t *testing.T) {
+	prev := NetIO{Recv: 1000, Send: 500, PacketsRecv: 10, PacketsSent: 5, ErrIn: 1, DropOut: 2}
+	cur := NetIO{Recv: 3000, Send: 1500, PacketsRecv: 30, PacketsSent: 25, ErrIn: 5, DropOut: 2}
+	stat, ok := NetRate(prev, cur, 2*time.Second)
+	expected := NetStat{RecvBytes: 1000, SendBytes: 500, PacketsRecv: 10, PacketsSent: 10, ErrIn: 2}
+	if !ok || stat != expected {
+		t.Fatalf("unexpected net stat: %+v", stat)
+	}
+
+	// 网卡重建后计数清零
+	if _, ok := NetRate(cur, NetIO{Recv: 10}, 2*time.Second); ok {
+		t.Fatal("expected counter reset to be rejected")
+	}
+	if _, ok := NetRate(prev, cur, 0); ok {
+		t.Fatal("expected zero interval to be rejected")
+	}
+}
+
+func TestSummarizeConnections(t *testing.T) {
+	conns := []net.ConnectionStat{
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: "0.0.0.0", Port: 8000}, Status: "LISTEN", Pid: 10},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: "0.0.0.0", Port: 8000}, Status: "LISTEN", Pid: 10},
+		{Family: syscall.AF_INET6, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: "::", Port: 22}, Status: "LISTEN", Pid: 1},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: "10.0.0.1", Port: 22},
+			Raddr: net.Addr{IP: "10.0.0.2", Port: 50000}, Status: "ESTABLISHED", Pid: 1},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: "10.0.0.1", Port: 40000},
+			Raddr: net.Addr{IP: "10.0.0.3", Port: 443}, Status: "TIME_WAIT"},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Laddr: net.Addr{IP: "127.0.0.53", Port: 53}, Status: "NONE", Pid: 5},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Laddr: net.Addr{IP: "10.0.0.1", Port: 41000},
+			Raddr: net.Addr{IP: "8.8.8.8", Port: 53}, Status: "NONE", Pid: 6},
+	}
+	stat := summarizeConnections(conns)
+	if stat.States["LISTEN"] != 3 || stat.States["ESTABLISHED"] != 1 || stat.States["TIME_WAIT"] != 1 || len(stat.States) != 3 {
+		t.Fatalf("unexpected states: %+v", stat.States)
+	}
+	expected := []ListenPort{
+		{Protocol: "tcp6", Address: "::", Port: 22, PID: 1},
+		{Protocol: "udp", Address: "127.0.0.53", Port: 53, PID: 5},
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 8000, PID: 10},
+	}
+	if len(stat.Listens) != len(expected) {
+		t.Fatalf("unexpected listens: %+v", stat.Listens)
+	}
+	for i, l := range expected {
+		if stat.Listens[i] != l {
+			t.Fatalf("unexpected listen %d: %+v", i, stat.Listens[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package psutil
+// Date: 2024/4/28 10:30
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PressureResources 内核 PSI 支持的资源类型
+var PressureResources = []string{"cpu", "memory", "io"}
+
+type PressureStat struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	// Total 累计阻塞时间，单位 us
+	Total uint64
+}
+
+// Pressure /proc/pressure/<resource> 的内容，some 表示至少一个任务阻塞，full 表示全部任务阻塞
+type Pressure struct {
+	Resource string
+	Some     PressureStat
+	Full     PressureStat
+	// HasFull 早期内核的 cpu 没有 full 行
+	HasFull bool
+}
+
+// ParsePressure 解析形如 "some avg10=0.00 avg60=0.00 avg300=0.00 total=0" 的内容
+func ParsePressure(resource, data string) (Pressure, error) {
+	p := Pressure{Resource: resource}
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var stat PressureStat
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return p, fmt.Errorf("invalid pressure field %q", field)
+			}
+			var err error
+			switch key {
+			case "avg10":
+				stat.Avg10, err = strconv.ParseFloat(value, 64)
+			case "avg60":
+				stat.Avg60, err = strconv.ParseFloat(value, 64)
+			case "avg300":
+				stat.Avg300, err = strconv.ParseFloat(value, 64)
+			case "total":
+				stat.Total, err = strconv.ParseUint(value, 10, 64)
+			}
+			if err != nil {
+				return p, fmt.Errorf("invalid pressure field %q: %w", field, err)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			p.Some = stat
+		case "full":
+			p.Full = stat
+			p.HasFull = true
+		}
+	}
+	return p, nil
+}
+
+// GetPressure 读取 CPU、内存、IO 的 PSI，内核未开启 PSI 时返回空列表
+func GetPressure() ([]Pressure, error) {
+	return readPressure(filepath.Join(hostProc(), "pressure"))
+}
+
+func readPressure(dir string) ([]Pressure, error) {
+	var list []Pressure
+	for _, resource := range PressureResources {
+		data, err := os.ReadFile(filepath.Join(dir, resource))
+		if err != nil {
+			// 文件不存在或 psi=0 时读取返回 EOPNOTSUPP
+			continue
+		}
+		p, err := ParsePressure(resource, string(data))
+		if err != nil {
+			return list, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
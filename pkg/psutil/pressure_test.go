@@ -0,0 +1,55 @@
+// Package psutil
+// Date: 2024/4/28 11:00
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPressure(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cpu":    "some avg10=1.50 avg60=0.80 avg300=0.20 total=123456\n",
+		"memory": "some avg10=0.00 avg60=0.00 avg300=0.00 total=10\nfull avg10=2.25 avg60=0.00 avg300=0.00 total=5\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := readPressure(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected cpu and memory pressure, got %+v", list)
+	}
+	cpu, memory := list[0], list[1]
+	if cpu.Resource != "cpu" || cpu.Some.Avg10 != 1.5 || cpu.Some.Total != 123456 || cpu.HasFull {
+		t.Fatalf("unexpected cpu pressure: %+v", cpu)
+	}
+	if memory.Resource != "memory" || !memory.HasFull || memory.Full.Avg10 != 2.25 || memory.Full.Total != 5 {
+		t.Fatalf("unexpected memory pressure: %+v", memory)
+	}
+
+	if _, err := ParsePressure("io", "some avg10=abc"); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if list, err := readPressure(filepath.Join(dir, "missing")); err != nil || len(list) != 0 {
+		t.Fatalf("expected empty result without psi, got %+v %v", list, err)
+	}
+}
+
+func TestGetMemoryStat(t *testing.T) {
+	stat, err := GetMemoryStat()
+	if err != nil {
+		t.Skip(err)
+	}
+	if stat.Total == 0 || stat.Available > stat.Total {
+		t.Fatalf("unexpected memory stat: %+v", stat)
+	}
+}
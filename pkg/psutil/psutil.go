@@ -18,19 +18,32 @@ import (
 
 type DiskInfo struct {
 	Mountpoint string
-	Total   uint64
-	Percent float64
-	Used    uint64
+	Total      uint64
+	Percent    float64
+	Used       uint64
 }
 
 type NetIO struct {
-	Recv uint64 `json:"recv"`
-	Send uint64 `json:"send"`
+	Recv        uint64 `json:"recv"`
+	Send        uint64 `json:"send"`
+	PacketsRecv uint64 `json:"packets_recv"`
+	PacketsSent uint64 `json:"packets_sent"`
+	ErrIn       uint64 `json:"errin"`
+	ErrOut      uint64 `json:"errout"`
+	DropIn      uint64 `json:"dropin"`
+	DropOut     uint64 `json:"dropout"`
 }
 
+// DiskIO 磁盘累计计数，时间单位为 ms
 type DiskIO struct {
-	Read  uint64 `json:"read"`
-	Write uint64 `json:"write"`
+	Read       uint64 `json:"read"`
+	Write      uint64 `json:"write"`
+	ReadCount  uint64 `json:"read_count"`
+	WriteCount uint64 `json:"write_count"`
+	ReadTime   uint64 `json:"read_time"`
+	WriteTime  uint64 `json:"write_time"`
+	IoTime     uint64 `json:"io_time"`
+	WeightedIO uint64 `json:"weighted_io"`
 }
 
 type SystemInfo struct {
@@ -72,9 +85,9 @@ func GetDiskInfo(devices map[string]struct{}) (map[string]DiskInfo, error) {
 		}
 		if _, ok := diskMap[info.Device]; !ok {
 			diskMap[info.Device] = DiskInfo{
-				Total:   usedInfo.Total,
-				Percent: usedInfo.UsedPercent,
-				Used:    usedInfo.Used,
+				Total:      usedInfo.Total,
+				Percent:    usedInfo.UsedPercent,
+				Used:       usedInfo.Used,
 				Mountpoint: info.Mountpoint,
 			}
 		}
@@ -97,8 +110,14 @@ func GetDiskIO(devices map[string]struct{}) (map[string]DiskIO, error) {
 			continue
 		}
 		diskMap[deviceName] = DiskIO{
-			Read:  v.ReadBytes,
-			Write: v.WriteBytes,
+			Read:       v.ReadBytes,
+			Write:      v.WriteBytes,
+			ReadCount:  v.ReadCount,
+			WriteCount: v.WriteCount,
+			ReadTime:   v.ReadTime,
+			WriteTime:  v.WriteTime,
+			IoTime:     v.IoTime,
+			WeightedIO: v.WeightedIO,
 		}
 	}
 	return diskMap, nil
@@ -115,8 +134,14 @@ func GetNetworkIO(eth map[string]struct{}) (map[string]NetIO, error) {
 			continue
 		}
 		netMap[stat.Name] = NetIO{
-			Recv: stat.BytesRecv,
-			Send: stat.BytesSent,
+			Recv:        stat.BytesRecv,
+			Send:        stat.BytesSent,
+			PacketsRecv: stat.PacketsRecv,
+			PacketsSent: stat.PacketsSent,
+			ErrIn:       stat.Errin,
+			ErrOut:      stat.Errout,
+			DropIn:      stat.Dropin,
+			DropOut:     stat.Dropout,
 		}
 	}
 	return netMap, nil
@@ -142,7 +167,8 @@ func GetSystemInfo() (*SystemInfo, error) {
 
 // disk device -> mountpoint
 var deviceMountCache = sync.Map{}
-func GetDiskDeviceMount(device string) (string) {
+
+func GetDiskDeviceMount(device string) string {
 	if v, ok := deviceMountCache.Load(device); ok {
 		return v.(string)
 	}
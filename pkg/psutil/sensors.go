@@ -0,0 +1,140 @@
+// Package psutil
+// Date: 2024/4/29 14:00
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/common"
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// Temperature 温度传感器，单位 ℃，High / Critical 为 0 表示未提供阈值
+type Temperature struct {
+	Name     string
+	Value    float64
+	High     float64
+	Critical float64
+}
+
+// Fan 风扇转速，单位 RPM
+type Fan struct {
+	Name string
+	RPM  float64
+}
+
+// Battery 电池电量(%)及状态，如 Charging / Discharging / Full
+type Battery struct {
+	Name     string
+	Capacity float64
+	Status   string
+}
+
+type SensorStat struct {
+	Temperatures []Temperature
+	Fans         []Fan
+	Batteries    []Battery
+}
+
+// SensorReader 读取 hwmon / thermal_zone / power_supply 下的传感器
+type SensorReader struct {
+	root string
+}
+
+// NewSensorReader root 为 sysfs 挂载点，为空时使用 HOST_SYS 或 /sys
+func NewSensorReader(root string) *SensorReader {
+	if root == "" {
+		root = hostSys()
+	}
+	return &SensorReader{root: root}
+}
+
+// Read 读取全部传感器，没有对应硬件时返回空列表，部分传感器读取失败不影响其他传感器
+func (r *SensorReader) Read(ctx context.Context) (SensorStat, error) {
+	var stat SensorStat
+	ctx = context.WithValue(ctx, common.EnvKey, common.EnvMap{common.HostSysEnvKey: r.root})
+	temps, err := host.SensorsTemperaturesWithContext(ctx)
+	var warnings *host.Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		return stat, err
+	}
+	for _, t := range temps {
+		stat.Temperatures = append(stat.Temperatures, Temperature{
+			Name:     t.SensorKey,
+			Value:    t.Temperature,
+			High:     t.High,
+			Critical: t.Critical,
+		})
+	}
+	sort.Slice(stat.Temperatures, func(i, j int) bool { return stat.Temperatures[i].Name < stat.Temperatures[j].Name })
+	stat.Fans = r.fans()
+	stat.Batteries = r.batteries()
+	return stat, nil
+}
+
+// fans 读取 hwmon*/fan*_input，名称格式与 gopsutil 温度传感器一致: <name>_<label>
+func (r *SensorReader) fans() []Fan {
+	var fans []Fan
+	files, _ := filepath.Glob(filepath.Join(r.root, "class/hwmon/hwmon*/fan*_input"))
+	for _, file := range files {
+		rpm, ok := readFloat(file)
+		if !ok {
+			continue
+		}
+		dir := filepath.Dir(file)
+		base := strings.TrimSuffix(filepath.Base(file), "_input")
+		label := base
+		if data, err := os.ReadFile(filepath.Join(dir, base+"_label")); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+			label = strings.Join(strings.Fields(strings.ToLower(string(data))), "_")
+		}
+		name := label
+		if data, err := os.ReadFile(filepath.Join(dir, "name")); err == nil {
+			name = strings.TrimSpace(string(data)) + "_" + label
+		}
+		fans = append(fans, Fan{Name: name, RPM: rpm})
+	}
+	sort.Slice(fans, func(i, j int) bool { return fans[i].Name < fans[j].Name })
+	return fans
+}
+
+// batteries 读取 power_supply 下 type 为 Battery 的设备
+func (r *SensorReader) batteries() []Battery {
+	var batteries []Battery
+	dirs, _ := filepath.Glob(filepath.Join(r.root, "class/power_supply/*"))
+	for _, dir := range dirs {
+		kind, err := os.ReadFile(filepath.Join(dir, "type"))
+		if err != nil || strings.TrimSpace(string(kind)) != "Battery" {
+			continue
+		}
+		capacity, ok := readFloat(filepath.Join(dir, "capacity"))
+		if !ok {
+			continue
+		}
+		battery := Battery{Name: filepath.Base(dir), Capacity: capacity}
+		if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+			battery.Status = strings.TrimSpace(string(status))
+		}
+		batteries = append(batteries, battery)
+	}
+	return batteries
+}
+
+func readFloat(file string) (float64, bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
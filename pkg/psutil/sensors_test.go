@@ -0,0 +1,83 @@
+// Package psutil
+// Date: 2024/4/29 14:00
+// Author: Amu
+// Description:
+package psutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSysfs(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSensorReader(t *testing.T) {
+	root := t.TempDir()
+	writeSysfs(t, root, map[string]string{
+		"class/hwmon/hwmon0/name":          "coretemp\n",
+		"class/hwmon/hwmon0/temp1_input":   "45000\n",
+		"class/hwmon/hwmon0/temp1_label":   "Core 0\n",
+		"class/hwmon/hwmon0/temp1_max":     "80000\n",
+		"class/hwmon/hwmon0/temp1_crit":    "100000\n",
+		"class/hwmon/hwmon1/name":          "nct6775\n",
+		"class/hwmon/hwmon1/fan1_input":    "1200\n",
+		"class/hwmon/hwmon1/fan2_input":    "800\n",
+		"class/hwmon/hwmon1/fan2_label":    "CPU Fan\n",
+		"class/power_supply/AC/type":       "Mains\n",
+		"class/power_supply/AC/online":     "1\n",
+		"class/power_supply/BAT0/type":     "Battery\n",
+		"class/power_supply/BAT0/capacity": "87\n",
+		"class/power_supply/BAT0/status":   "Discharging\n",
+		"class/thermal/thermal_zone0/type": "x86_pkg_temp\n",
+		"class/thermal/thermal_zone0/temp": "50000\n",
+	})
+
+	stat, err := NewSensorReader(root).Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stat.Temperatures) != 1 {
+		t.Fatalf("expected hwmon temperature only, got %+v", stat.Temperatures)
+	}
+	temp := stat.Temperatures[0]
+	if temp.Name != "coretemp_core_0" || temp.Value != 45 || temp.High != 80 || temp.Critical != 100 {
+		t.Fatalf("unexpected temperature: %+v", temp)
+	}
+	if len(stat.Fans) != 2 || stat.Fans[0] != (Fan{Name: "nct6775_cpu_fan", RPM: 800}) || stat.Fans[1] != (Fan{Name: "nct6775_fan1", RPM: 1200}) {
+		t.Fatalf("unexpected fans: %+v", stat.Fans)
+	}
+	if len(stat.Batteries) != 1 || stat.Batteries[0] != (Battery{Name: "BAT0", Capacity: 87, Status: "Discharging"}) {
+		t.Fatalf("unexpected batteries: %+v", stat.Batteries)
+	}
+}
+
+func TestSensorReaderThermalZone(t *testing.T) {
+	root := t.TempDir()
+	writeSysfs(t, root, map[string]string{
+		"class/thermal/thermal_zone0/type": "cpu-thermal\n",
+		"class/thermal/thermal_zone0/temp": "61500\n",
+	})
+	stat, err := NewSensorReader(root).Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stat.Temperatures) != 1 || stat.Temperatures[0].Name != "cpu-thermal" || stat.Temperatures[0].Value != 61.5 {
+		t.Fatalf("unexpected temperatures: %+v", stat.Temperatures)
+	}
+	if len(stat.Fans) != 0 || len(stat.Batteries) != 0 {
+		t.Fatalf("expected no fans and batteries, got %+v", stat)
+	}
+}
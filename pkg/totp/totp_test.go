@@ -0,0 +1,63 @@
+// Package totp
+// Date: 2024/4/16 10:12
+// Author: Amu
+// Description:
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 中 SHA1 的测试向量
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	cases := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for ts, want := range cases {
+		if got := hotp(key, uint64(ts/Period), 8); got != want {
+			t.Errorf("hotp(%d) = %s, want %s", ts, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, err := GenerateCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Validate(secret, code, now) {
+		t.Fatal("current code rejected")
+	}
+	if !Validate(secret, code, now.Add(Period*time.Second)) {
+		t.Fatal("code within skew rejected")
+	}
+	if Validate(secret, code, now.Add(3*Period*time.Second)) {
+		t.Fatal("expired code accepted")
+	}
+	if Validate(secret, "12345", now) {
+		t.Fatal("short code accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("amprobe", "admin", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/amprobe:admin?") {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Fatalf("secret missing in %s", uri)
+	}
+}
@@ -7,6 +7,7 @@ package hash
 import (
 	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 )
@@ -34,3 +35,15 @@ func SHA1(b []byte) string {
 func SHA1String(s string) string {
 	return SHA1([]byte(s))
 }
+
+// SHA256 SHA256哈希值
+func SHA256(b []byte) string {
+	h := sha256.New()
+	_, _ = h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SHA256String SHA256哈希值
+func SHA256String(s string) string {
+	return SHA256([]byte(s))
+}
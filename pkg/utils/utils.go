@@ -4,7 +4,10 @@
 // Description:
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
 func ConvertBytesToReadable(bytes float64) string {
 	var units = []string{"B", "KB", "MB", "GB", "TB"}
@@ -17,3 +20,14 @@ func ConvertBytesToReadable(bytes float64) string {
 	}
 	return fmt.Sprintf("%.2f", bytes) + " " + units[index]
 }
+
+// Truncate 截断到最多 max 字节，不截断多字节字符
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,20 @@
+// Package utils
+// Date: 2024/5/10 15:00
+// Author: Amu
+// Description:
+package utils
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	if s := Truncate("abc", 5); s != "abc" {
+		t.Fatalf("unexpected %q", s)
+	}
+	if s := Truncate("abcdef", 3); s != "abc" {
+		t.Fatalf("unexpected %q", s)
+	}
+	// "进" 占 3 字节，不能截断在字符中间
+	if s := Truncate("a进程", 3); s != "a" {
+		t.Fatalf("unexpected %q", s)
+	}
+}
@@ -0,0 +1,74 @@
+// Package service
+// Date: 2024/4/22 11:20
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auditlog"
+	"github.com/amuluze/amutool/database"
+)
+
+func auditChainKey(config *Config) []byte {
+	if config.Audit.ChainKey != "" {
+		return []byte(config.Audit.ChainKey)
+	}
+	return []byte(config.Auth.SigningKey)
+}
+
+func InitAuditChain(config *Config, db *database.DB) (*auditlog.Chain, func(), error) {
+	var sinks []auditlog.Sink
+	switch config.Audit.Sink {
+	case "":
+	case "file":
+		sink, err := auditlog.NewFileSink(config.Audit.SinkFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinks = append(sinks, sink)
+	case "syslog":
+		sink, err := auditlog.NewSyslogSink(config.Audit.SyslogNetwork, config.Audit.SyslogAddr, config.Audit.SyslogTag)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinks = append(sinks, sink)
+	default:
+		return nil, nil, fmt.Errorf("unknown audit sink: %s", config.Audit.Sink)
+	}
+	chain := auditlog.NewChain(db, auditChainKey(config), sinks...)
+	// 升级前已有的记录没有链尾，启动时以最后一条记录初始化
+	if err := chain.Init(); err != nil {
+		_ = chain.Close()
+		return nil, nil, err
+	}
+	var err error
+	cleanFunc := func() { err = chain.Close() }
+	return chain, cleanFunc, err
+}
+
+func InitAuditArchiver(config *Config, chain *auditlog.Chain) *auditlog.Archiver {
+	retention := time.Duration(config.Audit.RetentionDays) * 24 * time.Hour
+	return auditlog.NewArchiver(chain, config.Audit.ArchiveDir, retention)
+}
+
+// AuditVerify 校验审计日志哈希链，供命令行使用
+func AuditVerify(ctx context.Context, opts ...Option) (*auditlog.VerifyResult, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	config, err := NewConfig(o.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return auditlog.NewChain(db, auditChainKey(config)).Verify(ctx)
+}
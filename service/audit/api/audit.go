@@ -5,12 +5,19 @@
 package api
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"github.com/amuluze/amprobe/pkg/fiberx"
 	"github.com/amuluze/amprobe/pkg/validatex"
 	"github.com/amuluze/amprobe/service/audit/service"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/gofiber/fiber/v2"
 	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type AuditAPI struct {
@@ -40,3 +47,103 @@ func (a *AuditAPI) AuditQuery(ctx *fiber.Ctx) error {
 	}
 	return fiberx.Success(ctx, audits)
 }
+
+// csvFormulaPrefixes 表格软件会把以这些字符开头的单元格当作公式执行
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvCell 用户名、UA 等字段可由未登录的请求写入，加 ' 前缀防止导出的 CSV 被当作公式执行
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+var auditCSVHeader = []string{"id", "created", "username", "operate", "resource_id", "method", "path", "status", "duration_ms", "client_ip", "user_agent", "params"}
+
+func (a *AuditAPI) AuditExport(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+
+	var args schema.AuditExportArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	if err := validatex.ValidateStruct(&args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	audits, err := a.AuditService.AuditExport(c, &args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	filename := fmt.Sprintf("audit_%s", time.Now().Format("20060102150405"))
+	if args.Format == "json" {
+		data, err := json.Marshal(audits)
+		if err != nil {
+			return fiberx.Failure(ctx, err)
+		}
+		ctx.Attachment(filename + ".json")
+		ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+		return ctx.Send(data)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(auditCSVHeader)
+	for _, audit := range audits {
+		_ = w.Write([]string{
+			strconv.FormatUint(uint64(audit.ID), 10),
+			audit.Created,
+			csvCell(audit.Username),
+			csvCell(audit.Operate),
+			csvCell(audit.ResourceID),
+			csvCell(audit.Method),
+			csvCell(audit.Path),
+			strconv.Itoa(audit.Status),
+			strconv.FormatInt(audit.Duration, 10),
+			csvCell(audit.ClientIP),
+			csvCell(audit.UserAgent),
+			csvCell(audit.Params),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	ctx.Attachment(filename + ".csv")
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	return ctx.Send(buf.Bytes())
+}
+
+func (a *AuditAPI) AuditVerify(ctx *fiber.Ctx) error {
+	res, err := a.AuditService.AuditVerify(ctx.UserContext())
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuditAPI) AuditArchiveList(ctx *fiber.Ctx) error {
+	res, err := a.AuditService.AuditArchiveList(ctx.UserContext())
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuditAPI) AuditArchiveDownload(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+
+	var args schema.AuditArchiveDownloadArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	if err := validatex.ValidateStruct(&args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	path, err := a.AuditService.AuditArchivePath(c, &args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return ctx.Download(path, args.Name)
+}
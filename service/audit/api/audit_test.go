@@ -0,0 +1,55 @@
+// Package api
+// Date: 2024/5/11 10:00
+// Author: Amu
+// Description:
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amuluze/amprobe/service/audit/service"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/gofiber/fiber/v2"
+)
+
+type fakeAuditService struct {
+	service.IAuditService
+	audits []schema.Audit
+}
+
+func (f *fakeAuditService) AuditExport(ctx context.Context, args *schema.AuditExportArgs) ([]schema.Audit, error) {
+	return f.audits, nil
+}
+
+func TestAuditExportCSVFormula(t *testing.T) {
+	api := NewAuditAPI(&fakeAuditService{audits: []schema.Audit{{
+		ID:        1,
+		Username:  `=HYPERLINK("http://evil","x")`,
+		Operate:   "登录",
+		UserAgent: "@SUM(1+1)",
+		Params:    "-1",
+		Status:    400,
+	}}})
+	app := fiber.New()
+	app.Get("/export", api.AuditExport)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/export?format=csv", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and one row, got %v", records)
+	}
+	row := records[1]
+	if row[2] != `'=HYPERLINK("http://evil","x")` || row[3] != "登录" || row[10] != "'@SUM(1+1)" || row[11] != "'-1" {
+		t.Fatalf("formula cells not escaped: %q", row)
+	}
+}
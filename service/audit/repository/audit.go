@@ -6,39 +6,92 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/amuluze/amutool/database"
 	"github.com/google/wire"
+	"github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	// MaxExportRows 单次导出的最大条数
+	MaxExportRows = 100000
+	// countCacheTTL 总数缓存时间，避免每次翻页都对大表做 COUNT
+	countCacheTTL = 30 * time.Second
 )
 
 var AuditRepoSet = wire.NewSet(NewAuditRepo, wire.Bind(new(IAuditRepo), new(*AuditRepo)))
 
 type IAuditRepo interface {
 	AuditQuery(ctx context.Context, args *schema.AuditQueryArgs) (model.Audits, error)
-	AuditCount(ctx context.Context) (int, error)
+	AuditCount(ctx context.Context, filter *schema.AuditFilter) (int, error)
+	AuditExport(ctx context.Context, filter *schema.AuditFilter) (model.Audits, error)
 }
 
 type AuditRepo struct {
-	DB *database.DB
+	DB    *database.DB
+	count *cache.Cache
 }
 
 func NewAuditRepo(db *database.DB) *AuditRepo {
-	return &AuditRepo{DB: db}
+	return &AuditRepo{DB: db, count: cache.New(countCacheTTL, time.Minute)}
+}
+
+func (a *AuditRepo) filter(filter *schema.AuditFilter) *gorm.DB {
+	tx := a.DB.Model(&model.Audit{})
+	if filter.Username != "" {
+		tx = tx.Where("username = ?", filter.Username)
+	}
+	if filter.Operate != "" {
+		tx = tx.Where("operate = ?", filter.Operate)
+	}
+	if filter.ResourceID != "" {
+		tx = tx.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.StartTime > 0 {
+		tx = tx.Where("created_at >= ?", time.Unix(filter.StartTime, 0))
+	}
+	if filter.EndTime > 0 {
+		tx = tx.Where("created_at <= ?", time.Unix(filter.EndTime, 0))
+	}
+	return tx
 }
 
 func (a *AuditRepo) AuditQuery(ctx context.Context, args *schema.AuditQueryArgs) (model.Audits, error) {
 	var audits model.Audits
-	if err := a.DB.Model(&model.Audit{}).Order("created_at DESC").Offset((args.Page - 1) * args.Size).Limit(args.Size).Find(&audits).Error; err != nil {
+	// 按主键倒序分页，ID 与写入顺序一致，避免对 created_at 排序
+	tx := a.filter(&args.AuditFilter).Order("id DESC").Limit(args.Size)
+	if args.BeforeID > 0 {
+		tx = tx.Where("id < ?", args.BeforeID)
+	} else {
+		tx = tx.Offset((args.Page - 1) * args.Size)
+	}
+	if err := tx.Find(&audits).Error; err != nil {
 		return audits, err
 	}
 	return audits, nil
 }
 
-func (a *AuditRepo) AuditCount(ctx context.Context) (int, error) {
+func (a *AuditRepo) AuditCount(ctx context.Context, filter *schema.AuditFilter) (int, error) {
+	key := fmt.Sprintf("%+v", *filter)
+	if v, ok := a.count.Get(key); ok {
+		return v.(int), nil
+	}
 	var count int64
-	if err := a.DB.Model(&model.Audit{}).Count(&count).Error; err != nil {
+	if err := a.filter(filter).Count(&count).Error; err != nil {
 		return int(count), err
 	}
+	a.count.Set(key, int(count), cache.DefaultExpiration)
 	return int(count), nil
 }
+
+func (a *AuditRepo) AuditExport(ctx context.Context, filter *schema.AuditFilter) (model.Audits, error) {
+	var audits model.Audits
+	if err := a.filter(filter).Order("id DESC").Limit(MaxExportRows).Find(&audits).Error; err != nil {
+		return audits, err
+	}
+	return audits, nil
+}
@@ -6,7 +6,9 @@ package service
 
 import (
 	"context"
+	"github.com/amuluze/amprobe/pkg/auditlog"
 	"github.com/amuluze/amprobe/service/audit/repository"
+	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/amuluze/amutool/errors"
 	"github.com/google/wire"
@@ -16,14 +18,37 @@ var AuditServiceSet = wire.NewSet(NewAuditService, wire.Bind(new(IAuditService),
 
 type IAuditService interface {
 	AuditQuery(ctx context.Context, args *schema.AuditQueryArgs) (*schema.AuditQueryReply, error)
+	AuditExport(ctx context.Context, args *schema.AuditExportArgs) ([]schema.Audit, error)
+	AuditVerify(ctx context.Context) (*schema.AuditVerifyReply, error)
+	AuditArchiveList(ctx context.Context) (*schema.AuditArchiveListReply, error)
+	AuditArchivePath(ctx context.Context, args *schema.AuditArchiveDownloadArgs) (string, error)
 }
 
 type AuditService struct {
 	AuditRepo repository.IAuditRepo
+	Chain     *auditlog.Chain
+	Archiver  *auditlog.Archiver
 }
 
-func NewAuditService(repo repository.IAuditRepo) *AuditService {
-	return &AuditService{AuditRepo: repo}
+func NewAuditService(repo repository.IAuditRepo, chain *auditlog.Chain, archiver *auditlog.Archiver) *AuditService {
+	return &AuditService{AuditRepo: repo, Chain: chain, Archiver: archiver}
+}
+
+func toAudit(audit *model.Audit) schema.Audit {
+	return schema.Audit{
+		ID:         audit.ID,
+		Username:   audit.Username,
+		Operate:    audit.Operate,
+		ResourceID: audit.ResourceID,
+		Params:     audit.Params,
+		ClientIP:   audit.ClientIP,
+		UserAgent:  audit.UserAgent,
+		Method:     audit.Method,
+		Path:       audit.Path,
+		Status:     audit.Status,
+		Duration:   audit.Duration,
+		Created:    audit.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
 }
 
 func (a AuditService) AuditQuery(ctx context.Context, args *schema.AuditQueryArgs) (*schema.AuditQueryReply, error) {
@@ -33,14 +58,53 @@ func (a AuditService) AuditQuery(ctx context.Context, args *schema.AuditQueryArg
 	}
 
 	var list []schema.Audit
-	for _, audit := range audits {
-		list = append(list, schema.Audit{
-			ID:       audit.ID,
-			Username: audit.Username,
-			Operate:  audit.Operate,
-			Created:  audit.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+	for i := range audits {
+		list = append(list, toAudit(&audits[i]))
 	}
-	total, _ := a.AuditRepo.AuditCount(ctx)
+	total, _ := a.AuditRepo.AuditCount(ctx, &args.AuditFilter)
 	return &schema.AuditQueryReply{Data: list, Total: total, Page: args.Page, Size: args.Size}, nil
 }
+
+func (a AuditService) AuditExport(ctx context.Context, args *schema.AuditExportArgs) ([]schema.Audit, error) {
+	audits, err := a.AuditRepo.AuditExport(ctx, &args.AuditFilter)
+	if err != nil {
+		return nil, errors.New400Error(err.Error())
+	}
+	list := make([]schema.Audit, 0, len(audits))
+	for i := range audits {
+		list = append(list, toAudit(&audits[i]))
+	}
+	return list, nil
+}
+
+func (a AuditService) AuditVerify(ctx context.Context) (*schema.AuditVerifyReply, error) {
+	res, err := a.Chain.Verify(ctx)
+	if err != nil {
+		return nil, errors.New500Error(err.Error())
+	}
+	return &schema.AuditVerifyReply{OK: res.OK, Checked: res.Checked, BrokenID: res.BrokenID, Reason: res.Reason}, nil
+}
+
+func (a AuditService) AuditArchiveList(ctx context.Context) (*schema.AuditArchiveListReply, error) {
+	files, err := a.Archiver.List()
+	if err != nil {
+		return nil, errors.New500Error(err.Error())
+	}
+	list := make([]schema.AuditArchive, 0, len(files))
+	for _, f := range files {
+		list = append(list, schema.AuditArchive{
+			Name:    f.Name,
+			Size:    f.Size,
+			ModTime: f.ModTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return &schema.AuditArchiveListReply{Data: list}, nil
+}
+
+func (a AuditService) AuditArchivePath(ctx context.Context, args *schema.AuditArchiveDownloadArgs) (string, error) {
+	path, err := a.Archiver.Path(args.Name)
+	if err != nil {
+		return "", errors.New400Error(err.Error())
+	}
+	return path, nil
+}
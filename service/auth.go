@@ -5,26 +5,53 @@
 package service
 
 import (
+	"context"
+	"github.com/amuluze/amprobe/pkg/auditlog"
 	"github.com/amuluze/amprobe/pkg/auth"
 	"github.com/amuluze/amprobe/pkg/auth/jwtauth"
+	"github.com/amuluze/amprobe/pkg/ldapx"
+	"github.com/amuluze/amprobe/pkg/oidc"
+	authRepository "github.com/amuluze/amprobe/service/auth/repository"
+	authService "github.com/amuluze/amprobe/service/auth/service"
 	"github.com/amuluze/amutool/database"
 	"github.com/golang-jwt/jwt"
 	"github.com/patrickmn/go-cache"
+	"github.com/redis/go-redis/v9"
 	"time"
 )
 
-func InitAuthStore(config *Config) (*jwtauth.Store, func(), error) {
+func InitAuthStore(config *Config, db *database.DB) (jwtauth.Storer, func(), error) {
 	var err error
-	authStore := &jwtauth.Store{
-		Storage: cache.New(5*time.Minute, 60*time.Second),
-		Prefix:  config.Auth.Prefix,
+	var authStore jwtauth.Storer
+	switch config.Auth.Store {
+	case "db":
+		authStore = &jwtauth.DBStore{DB: db}
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     config.Redis.Addr,
+			Password: config.Redis.Password,
+			DB:       config.Redis.DB,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			_ = client.Close()
+			return nil, nil, err
+		}
+		authStore = &jwtauth.RedisStore{Client: client, Prefix: config.Auth.Prefix}
+	default:
+		authStore = &jwtauth.Store{
+			Storage: cache.New(5*time.Minute, 60*time.Second),
+			Prefix:  config.Auth.Prefix,
+		}
 	}
 	cleanFunc := func() { err = authStore.Close() }
 	return authStore, cleanFunc, err
 }
 
-func InitAuth(config *Config, authStore *jwtauth.Store, db *database.DB) (auth.Auther, func(), error) {
+func InitAuth(config *Config, authStore jwtauth.Storer, db *database.DB, auditChain *auditlog.Chain) (auth.Auther, func(), error) {
 	var opts []jwtauth.Option
+	opts = append(opts, jwtauth.SetAuditChain(auditChain))
 	opts = append(opts, jwtauth.SetExpired(config.Auth.Expired))
 	opts = append(opts, jwtauth.SetRefreshExpired(config.Auth.RefreshExpired))
 	opts = append(opts, jwtauth.SetSigningKey([]byte(config.Auth.SigningKey)))
@@ -51,3 +78,58 @@ func InitAuth(config *Config, authStore *jwtauth.Store, db *database.DB) (auth.A
 	}
 	return jAuth, cleanFunc, err
 }
+
+func InitAuthOptions(config *Config) *authService.Options {
+	issuer := config.Auth.TOTPIssuer
+	if issuer == "" {
+		issuer = config.Fiber.AppName
+	}
+	opts := &authService.Options{
+		TOTPIssuer:       issuer,
+		AdminRequireTOTP: config.Auth.AdminRequireTOTP,
+	}
+	if config.OIDC.Enable {
+		usernameClaim := config.OIDC.UsernameClaim
+		if usernameClaim == "" {
+			usernameClaim = "preferred_username"
+		}
+		opts.OIDC = &authService.OIDCOptions{
+			Provider: oidc.NewProvider(&oidc.Config{
+				Issuer:       config.OIDC.Issuer,
+				ClientID:     config.OIDC.ClientID,
+				ClientSecret: config.OIDC.ClientSecret,
+				RedirectURL:  config.OIDC.RedirectURL,
+				Scopes:       config.OIDC.Scopes,
+			}),
+			UsernameClaim: usernameClaim,
+			AdminClaim:    config.OIDC.AdminClaim,
+			AdminValues:   config.OIDC.AdminValues,
+			FrontendURL:   config.OIDC.FrontendURL,
+		}
+	}
+	return opts
+}
+
+func InitAuthRepoOptions(config *Config) *authRepository.Options {
+	provider := config.Auth.Provider
+	if provider == "" {
+		provider = authRepository.ProviderLocal
+	}
+	opts := &authRepository.Options{Provider: provider}
+	if provider == authRepository.ProviderLDAP || provider == authRepository.ProviderLocalLDAP {
+		opts.LDAP = ldapx.New(&ldapx.Config{
+			URL:                config.LDAP.URL,
+			StartTLS:           config.LDAP.StartTLS,
+			InsecureSkipVerify: config.LDAP.InsecureSkipVerify,
+			BindDN:             config.LDAP.BindDN,
+			BindPassword:       config.LDAP.BindPassword,
+			BaseDN:             config.LDAP.BaseDN,
+			UserFilter:         config.LDAP.UserFilter,
+			UsernameAttribute:  config.LDAP.UsernameAttribute,
+			GroupAttribute:     config.LDAP.GroupAttribute,
+			AdminGroups:        config.LDAP.AdminGroups,
+			Timeout:            config.LDAP.Timeout,
+		})
+	}
+	return opts
+}
@@ -12,6 +12,15 @@ import (
 	"github.com/amuluze/amprobe/service/auth/service"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/gofiber/fiber/v2"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	oidcCookiePath = "/api/v1/auth/oidc/"
+	// oidcCookieMaxAge 与服务端保存 state 的时间一致
+	oidcCookieMaxAge = 5 * time.Minute
 )
 
 type AuthAPI struct {
@@ -80,3 +89,241 @@ func (a *AuthAPI) TokenUpdate(ctx *fiber.Ctx) error {
 	}
 	return fiberx.Success(ctx, res)
 }
+
+func (a *AuthAPI) LoginTOTP(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+
+	var args schema.LoginTOTPArgs
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	// 成功或验证码错误次数过多时临时令牌会被删除，先取出用户名供审计记录使用
+	fiberx.SetLoginUser(ctx, a.AuthService.ChallengeUsername(c, args.TOTPToken))
+	res, err := a.AuthService.LoginTOTP(c, &args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) LoginTOTPSetup(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+
+	var args schema.LoginTOTPSetupArgs
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	res, err := a.AuthService.LoginTOTPSetup(c, &args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) TOTPEnroll(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	res, err := a.AuthService.TOTPEnroll(c, userID)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) TOTPActivate(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	var args schema.TOTPCodeArgs
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := a.AuthService.TOTPActivate(c, userID, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.NoContent(ctx)
+}
+
+func (a *AuthAPI) TOTPDisable(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	var args schema.TOTPCodeArgs
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := a.AuthService.TOTPDisable(c, userID, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.NoContent(ctx)
+}
+
+func (a *AuthAPI) OIDCLogin(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	authURL, state, err := a.AuthService.OIDCLogin(c)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	// 回调由身份提供方跨站跳转回来，SameSite 只能使用 Lax
+	ctx.Cookie(&fiber.Cookie{
+		Name:     service.OIDCStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		MaxAge:   int(oidcCookieMaxAge.Seconds()),
+		Secure:   ctx.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return ctx.Redirect(authURL, fiber.StatusFound)
+}
+
+func (a *AuthAPI) OIDCCallback(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+
+	var args schema.OIDCCallbackArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	stateCookie := ctx.Cookies(service.OIDCStateCookie)
+	// state 只能使用一次，无论结果如何都清除
+	ctx.Cookie(&fiber.Cookie{
+		Name:     service.OIDCStateCookie,
+		Path:     oidcCookiePath,
+		Expires:  time.Unix(0, 0),
+		Secure:   ctx.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	res, err := a.AuthService.OIDCCallback(c, &args, stateCookie)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	if frontend := a.AuthService.Options.OIDC.FrontendURL; frontend != "" {
+		// 令牌放在 fragment 中，不会出现在服务端访问日志里
+		fragment := url.Values{}
+		if res.TOTPToken != "" {
+			// 需要二次验证时前端凭临时令牌调用 login_totp 或 login_totp_setup
+			fragment.Set("totp_token", res.TOTPToken)
+			fragment.Set("totp_required", strconv.FormatBool(res.TOTPRequired))
+			fragment.Set("totp_setup_required", strconv.FormatBool(res.TOTPSetupRequired))
+		} else {
+			fragment.Set("access_token", res.AccessToken)
+			fragment.Set("refresh_token", res.RefreshToken)
+		}
+		return ctx.Redirect(frontend+"#"+fragment.Encode(), fiber.StatusFound)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) APITokenCreate(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	var args schema.APITokenCreateArgs
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	res, err := a.AuthService.APITokenCreate(c, userID, &args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) APITokenList(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	res, err := a.AuthService.APITokenList(c, userID)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) APITokenRevoke(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	var args schema.APITokenRevokeArgs
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+
+	if err := a.AuthService.APITokenRevoke(c, userID, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.NoContent(ctx)
+}
+
+func (a *AuthAPI) SessionList(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	res, err := a.AuthService.SessionList(c, userID, fiberx.GetToken(ctx))
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, res)
+}
+
+func (a *AuthAPI) LogoutAll(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	userID := contextx.FromUserID(c)
+	if userID == "" {
+		return fiberx.Unauthorized(ctx)
+	}
+	if err := a.AuthService.LogoutAll(c, userID); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.NoContent(ctx)
+}
@@ -6,36 +6,99 @@ package repository
 
 import (
 	"context"
+	"github.com/amuluze/amprobe/pkg/ldapx"
 	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/pkg/utils/uuid"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/amuluze/amutool/database"
 	"github.com/amuluze/amutool/errors"
 	"github.com/google/wire"
 	"gorm.io/gorm"
+	"log/slog"
 )
 
+const (
+	// ProviderLocal 仅使用本地账号
+	ProviderLocal = "local"
+	// ProviderLDAP 仅使用 LDAP 账号
+	ProviderLDAP = "ldap"
+	// ProviderLocalLDAP 本地账号校验失败后再尝试 LDAP
+	ProviderLocalLDAP = "local+ldap"
+	// ProviderOIDC OIDC 单点登录创建的用户来源
+	ProviderOIDC = "oidc"
+)
+
+// Options 登录认证方式
+type Options struct {
+	Provider string
+	LDAP     ldapx.Authenticator
+}
+
 var AuthRepoSet = wire.NewSet(NewAuthRepo, wire.Bind(new(IAuthRepository), new(*AuthRepo)))
 
 type IAuthRepository interface {
 	Login(ctx context.Context, args *schema.LoginArgs) (*model.User, error)
 	PassUpdate(ctx context.Context, args *schema.PasswordUpdateArgs) error
+	UserByID(ctx context.Context, userID string) (*model.User, error)
+	TOTPUpdate(ctx context.Context, userID string, secret string, enabled bool, recoveryCodes string) error
+	ProvisionUser(ctx context.Context, username string, source string, isAdmin string) (*model.User, error)
+	APITokenCreate(ctx context.Context, token *model.APIToken) error
+	APITokenList(ctx context.Context, userID string) (model.APITokens, error)
+	APITokenRevoke(ctx context.Context, userID string, id uint) error
+	APITokenRevokeAll(ctx context.Context, userID string) error
 }
 
 type AuthRepo struct {
-	DB *database.DB
+	DB      *database.DB
+	Options *Options
 }
 
-func NewAuthRepo(db *database.DB) *AuthRepo {
-	return &AuthRepo{DB: db}
+func NewAuthRepo(db *database.DB, opts *Options) *AuthRepo {
+	return &AuthRepo{DB: db, Options: opts}
 }
 
 func (a *AuthRepo) Login(ctx context.Context, args *schema.LoginArgs) (*model.User, error) {
+	switch a.Options.Provider {
+	case ProviderLDAP:
+		return a.ldapLogin(ctx, args)
+	case ProviderLocalLDAP:
+		user, err := a.localLogin(ctx, args)
+		if err == nil {
+			return user, nil
+		}
+		slog.Info("local login failed, fallback to ldap", "username", args.Username, "error", err)
+		return a.ldapLogin(ctx, args)
+	default:
+		return a.localLogin(ctx, args)
+	}
+}
+
+// ldapLogin LDAP 认证通过后创建或同步本地影子用户
+func (a *AuthRepo) ldapLogin(ctx context.Context, args *schema.LoginArgs) (*model.User, error) {
+	if a.Options.LDAP == nil {
+		return nil, errors.New("ldap is not configured")
+	}
+	entry, err := a.Options.LDAP.Authenticate(args.Username, args.Password)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin := "0"
+	if a.Options.LDAP.IsAdmin(entry) {
+		isAdmin = "1"
+	}
+	return a.ProvisionUser(ctx, entry.Username, ProviderLDAP, isAdmin)
+}
+
+func (a *AuthRepo) localLogin(ctx context.Context, args *schema.LoginArgs) (*model.User, error) {
 	var user model.User
 	err := a.DB.RunInTransaction(func(tx *gorm.DB) error {
 		if err := tx.Where("username = ?", args.Username).First(&user).Error; err != nil {
 			return err
 		}
+		if user.Source != "" && user.Source != ProviderLocal {
+			return errors.New("user must login with " + user.Source)
+		}
 		if user.Password != hash.SHA1String(args.Password) {
 			return errors.New("invalid password")
 		}
@@ -67,3 +130,65 @@ func (a *AuthRepo) PassUpdate(ctx context.Context, args *schema.PasswordUpdateAr
 	}
 	return nil
 }
+
+func (a *AuthRepo) UserByID(ctx context.Context, userID string) (*model.User, error) {
+	var user model.User
+	if err := a.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (a *AuthRepo) TOTPUpdate(ctx context.Context, userID string, secret string, enabled bool, recoveryCodes string) error {
+	return a.DB.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_enabled":   enabled,
+		"recovery_codes": recoveryCodes,
+	}).Error
+}
+
+// TOTPUseStep 记录通过的 TOTP 时间窗口，窗口不大于上次记录时返回 false，
+// 条件更新保证并发请求中同一验证码只有一个能通过
+func (a *AuthRepo) TOTPUseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res := a.DB.Model(&model.User{}).Where("id = ? AND totp_last_step < ?", userID, step).Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ProvisionUser 外部认证成功后创建或同步本地用户；
+// 已存在来源不同的同名用户时返回错误，不允许外部账号顶替本地账号或其他来源的账号
+func (a *AuthRepo) ProvisionUser(ctx context.Context, username string, source string, isAdmin string) (*model.User, error) {
+	var user model.User
+	err := a.DB.RunInTransaction(func(tx *gorm.DB) error {
+		err := tx.Where("username = ?", username).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = model.User{
+				ID:       model.NewUUID(),
+				Username: username,
+				// 外部用户不允许使用本地密码登录
+				Password: hash.SHA1String(uuid.MustString()),
+				Status:   1,
+				IsAdmin:  isAdmin,
+				Source:   source,
+			}
+			return tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+		if user.Source != source {
+			return errors.New("username conflicts with a " + user.Source + " user")
+		}
+		if user.IsAdmin == isAdmin {
+			return nil
+		}
+		user.IsAdmin = isAdmin
+		return tx.Model(&model.User{}).Where("id = ?", user.ID).Update("is_admin", isAdmin).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,40 @@
+// Package repository
+// Date: 2024/4/19 10:32
+// Author: Amu
+// Description:
+package repository
+
+import (
+	"context"
+
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/errors"
+)
+
+func (a *AuthRepo) APITokenCreate(ctx context.Context, token *model.APIToken) error {
+	return a.DB.Create(token).Error
+}
+
+func (a *AuthRepo) APITokenList(ctx context.Context, userID string) (model.APITokens, error) {
+	var tokens model.APITokens
+	if err := a.DB.Model(&model.APIToken{}).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return tokens, err
+	}
+	return tokens, nil
+}
+
+func (a *AuthRepo) APITokenRevoke(ctx context.Context, userID string, id uint) error {
+	res := a.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&model.APIToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("api token not found")
+	}
+	return nil
+}
+
+// APITokenRevokeAll 吊销用户的全部访问令牌
+func (a *AuthRepo) APITokenRevokeAll(ctx context.Context, userID string) error {
+	return a.DB.Where("user_id = ?", userID).Delete(&model.APIToken{}).Error
+}
@@ -8,28 +8,63 @@ import (
 	"context"
 	"github.com/amuluze/amprobe/pkg/auth"
 	"github.com/amuluze/amprobe/service/auth/repository"
+	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/amuluze/amutool/errors"
 	"github.com/google/wire"
+	"github.com/patrickmn/go-cache"
 	"log/slog"
+	"time"
 )
 
 var AuthServiceSet = wire.NewSet(NewAuthService, wire.Bind(new(IAuthService), new(*AuthService)))
 
+var _ IAuthService = (*AuthService)(nil)
+
 type IAuthService interface {
 	Login(ctx context.Context, args *schema.LoginArgs) (*schema.LoginResult, error)
 	Logout(ctx context.Context, userID, token string) error
 	PassUpdate(ctx context.Context, args *schema.PasswordUpdateArgs) error
 	TokenUpdate(ctx context.Context, token string) (*schema.LoginResult, error)
+	LoginTOTP(ctx context.Context, args *schema.LoginTOTPArgs) (*schema.LoginResult, error)
+	ChallengeUsername(ctx context.Context, token string) string
+	LoginTOTPSetup(ctx context.Context, args *schema.LoginTOTPSetupArgs) (*schema.TOTPEnrollResult, error)
+	TOTPEnroll(ctx context.Context, userID string) (*schema.TOTPEnrollResult, error)
+	TOTPActivate(ctx context.Context, userID string, args *schema.TOTPCodeArgs) error
+	TOTPDisable(ctx context.Context, userID string, args *schema.TOTPCodeArgs) error
+	OIDCLogin(ctx context.Context) (string, string, error)
+	OIDCCallback(ctx context.Context, args *schema.OIDCCallbackArgs, stateCookie string) (*schema.LoginResult, error)
+	APITokenCreate(ctx context.Context, userID string, args *schema.APITokenCreateArgs) (*schema.APITokenCreateResult, error)
+	APITokenList(ctx context.Context, userID string) (*schema.APITokenListReply, error)
+	APITokenRevoke(ctx context.Context, userID string, args *schema.APITokenRevokeArgs) error
+	SessionList(ctx context.Context, userID, token string) (*schema.SessionListReply, error)
+	LogoutAll(ctx context.Context, userID string) error
+}
+
+// Options 认证相关配置
+type Options struct {
+	TOTPIssuer       string
+	AdminRequireTOTP bool
+	// OIDC 未启用时为 nil
+	OIDC *OIDCOptions
 }
 
 type AuthService struct {
 	Auth     auth.Auther
 	AuthRepo *repository.AuthRepo
+	Options  *Options
+
+	// 两步登录中间态: totp_token -> user id
+	challenges *cache.Cache
 }
 
-func NewAuthService(auth auth.Auther, authRepo *repository.AuthRepo) *AuthService {
-	return &AuthService{Auth: auth, AuthRepo: authRepo}
+func NewAuthService(auth auth.Auther, authRepo *repository.AuthRepo, opts *Options) *AuthService {
+	return &AuthService{
+		Auth:       auth,
+		AuthRepo:   authRepo,
+		Options:    opts,
+		challenges: cache.New(5*time.Minute, 60*time.Second),
+	}
 }
 
 func (a *AuthService) Login(ctx context.Context, args *schema.LoginArgs) (*schema.LoginResult, error) {
@@ -38,6 +73,18 @@ func (a *AuthService) Login(ctx context.Context, args *schema.LoginArgs) (*schem
 		slog.Error("auth repo login failed", "error", err)
 		return nil, errors.New400Error(err.Error())
 	}
+	return a.issueToken(u)
+}
+
+// issueToken 第一步认证(密码、LDAP 或 OIDC)通过后签发令牌；
+// 启用了 TOTP 或管理员被要求绑定 TOTP 时返回临时令牌，需要完成二次验证
+func (a *AuthService) issueToken(u *model.User) (*schema.LoginResult, error) {
+	if u.TOTPEnabled {
+		return a.newChallenge(u.ID.String(), false)
+	}
+	if a.Options.AdminRequireTOTP && u.IsAdmin == "1" {
+		return a.newChallenge(u.ID.String(), true)
+	}
 	tokenInfo, err := a.Auth.GenerateToken(u.ID.String(), u.Username, u.IsAdmin)
 	if err != nil {
 		slog.Error("generate token failed", "error", err)
@@ -0,0 +1,277 @@
+// Package service_test
+// Date: 2024/5/10 16:00
+// Author: Amu
+// Description: 认证相关的集成测试
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth/jwtauth"
+	"github.com/amuluze/amprobe/pkg/migrate"
+	"github.com/amuluze/amprobe/pkg/oidc"
+	"github.com/amuluze/amprobe/pkg/oidc/oidctest"
+	"github.com/amuluze/amprobe/pkg/totp"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	authRepository "github.com/amuluze/amprobe/service/auth/repository"
+	authService "github.com/amuluze/amprobe/service/auth/service"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amutool/database"
+	"github.com/golang-jwt/jwt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openTestDB 在临时目录打开 SQLite 测试库，执行全部迁移
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "probe.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if _, err := migrate.Up(db, model.NewModels().Migrations()); err != nil {
+		t.Fatal(err)
+	}
+	return &database.DB{DB: db}
+}
+
+// wrongCode 返回当前时间窗口内无效的验证码
+func wrongCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(code)
+	for {
+		n = (n + 123457) % 1000000
+		wrong := fmt.Sprintf("%06d", n)
+		if !totp.Validate(secret, wrong, time.Now()) {
+			return wrong
+		}
+	}
+}
+
+func TestTOTPLockout(t *testing.T) {
+	db := openTestDB(t)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{ID: model.NewUUID(), Username: "admin", Password: hash.SHA1String("password"), IsAdmin: "1", Status: 1, TOTPSecret: secret, TOTPEnabled: true}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := authRepository.NewAuthRepo(db, &authRepository.Options{Provider: authRepository.ProviderLocal})
+	svc := authService.NewAuthService(nil, repo, &authService.Options{})
+
+	ctx := context.Background()
+	res, err := svc.Login(ctx, &schema.LoginArgs{Username: "admin", Password: "password"})
+	if err != nil || !res.TOTPRequired {
+		t.Fatalf("expected totp challenge, got %+v %v", res, err)
+	}
+	wrong := wrongCode(t, secret)
+	for i := 0; i < 5; i++ {
+		if _, err := svc.LoginTOTP(ctx, &schema.LoginTOTPArgs{TOTPToken: res.TOTPToken, Code: wrong}); err == nil {
+			t.Fatal("expected invalid code")
+		}
+	}
+	// 达到错误次数后临时令牌失效，正确的验证码也无法登录
+	code, _ := totp.GenerateCode(secret, time.Now())
+	if _, err := svc.LoginTOTP(ctx, &schema.LoginTOTPArgs{TOTPToken: res.TOTPToken, Code: code}); err == nil || err.Error() != "totp token expired" {
+		t.Fatalf("expected challenge to be revoked, got %v", err)
+	}
+}
+
+func TestTOTPReplay(t *testing.T) {
+	db := openTestDB(t)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{ID: model.NewUUID(), Username: "admin", Password: hash.SHA1String("password"), IsAdmin: "1", Status: 1, TOTPSecret: secret, TOTPEnabled: true}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := authRepository.NewAuthRepo(db, &authRepository.Options{Provider: authRepository.ProviderLocal})
+	auther := jwtauth.New(nil, db, jwtauth.SetSigningMethod(jwt.SigningMethodHS256), jwtauth.SetSigningKey([]byte("0123456789abcdef0123456789abcdef")))
+	svc := authService.NewAuthService(auther, repo, &authService.Options{})
+
+	ctx := context.Background()
+	code, _ := totp.GenerateCode(secret, time.Now())
+	for i, want := range []bool{true, false} {
+		res, err := svc.Login(ctx, &schema.LoginArgs{Username: "admin", Password: "password"})
+		if err != nil || !res.TOTPRequired {
+			t.Fatalf("expected totp challenge, got %+v %v", res, err)
+		}
+		// 第二次登录重放同一个验证码
+		_, err = svc.LoginTOTP(ctx, &schema.LoginTOTPArgs{TOTPToken: res.TOTPToken, Code: code})
+		if (err == nil) != want {
+			t.Fatalf("login %d: expected success %v, got %v", i+1, want, err)
+		}
+	}
+}
+
+// newOIDCService 返回接入测试身份提供方的认证服务
+func newOIDCService(t *testing.T, db *database.DB, claims map[string]interface{}) (*authService.AuthService, *oidctest.Provider) {
+	t.Helper()
+	provider := oidctest.New(t, "amprobe")
+	provider.Claims = claims
+	repo := authRepository.NewAuthRepo(db, &authRepository.Options{Provider: authRepository.ProviderLocal})
+	auther := jwtauth.New(nil, db, jwtauth.SetSigningMethod(jwt.SigningMethodHS256), jwtauth.SetSigningKey([]byte("0123456789abcdef0123456789abcdef")))
+	svc := authService.NewAuthService(auther, repo, &authService.Options{
+		OIDC: &authService.OIDCOptions{
+			Provider:      oidc.NewProvider(&oidc.Config{Issuer: provider.URL, ClientID: "amprobe", RedirectURL: "http://localhost/callback"}),
+			UsernameClaim: "preferred_username",
+		},
+	})
+	return svc, provider
+}
+
+func TestOIDCCallbackRejectsLocalUser(t *testing.T) {
+	db := openTestDB(t)
+	admin := &model.User{ID: model.NewUUID(), Username: "admin", Password: hash.SHA1String("password"), IsAdmin: "1", Status: 1}
+	if err := db.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	svc, provider := newOIDCService(t, db, map[string]interface{}{"preferred_username": "admin"})
+
+	ctx := context.Background()
+	authURL, stateCookie, err := svc.OIDCLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.Authorize(t, authURL)
+	u, _ := url.Parse(authURL)
+	args := &schema.OIDCCallbackArgs{Code: oidctest.Code, State: u.Query().Get("state")}
+	// 同名的本地管理员不能通过 OIDC 登录
+	if _, err := svc.OIDCCallback(ctx, args, stateCookie); err == nil || !strings.Contains(err.Error(), "conflicts with a local user") {
+		t.Fatalf("expected oidc login for local user to fail, got %v", err)
+	}
+	var got model.User
+	if err := db.Where("username = ?", "admin").Take(&got).Error; err != nil || got.Source != authRepository.ProviderLocal {
+		t.Fatalf("local user changed: %+v %v", got, err)
+	}
+}
+
+func TestOIDCCallbackStateCookie(t *testing.T) {
+	db := openTestDB(t)
+	svc, provider := newOIDCService(t, db, map[string]interface{}{"preferred_username": "alice"})
+
+	ctx := context.Background()
+	authURL, stateCookie, err := svc.OIDCLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.Authorize(t, authURL)
+	u, _ := url.Parse(authURL)
+	args := &schema.OIDCCallbackArgs{Code: oidctest.Code, State: u.Query().Get("state")}
+	// 攻击者把自己的回调地址发给受害者，受害者浏览器中没有对应的 cookie
+	for _, cookie := range []string{"", hash.SHA256String("other")} {
+		if _, err := svc.OIDCCallback(ctx, args, cookie); err == nil || err.Error() != "invalid oidc state" {
+			t.Fatalf("expected state cookie mismatch, got %v", err)
+		}
+	}
+	res, err := svc.OIDCCallback(ctx, args, stateCookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AccessToken == "" {
+		t.Fatalf("expected tokens, got %+v", res)
+	}
+}
+
+func TestSessionListAndLogoutAll(t *testing.T) {
+	db := openTestDB(t)
+	if db.Migrator().HasColumn(new(model.AuthToken), "token") {
+		t.Fatal("raw token column should be dropped")
+	}
+	user := &model.User{ID: model.NewUUID(), Username: "admin", Password: hash.SHA1String("password"), IsAdmin: "1", Status: 1}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := authRepository.NewAuthRepo(db, &authRepository.Options{Provider: authRepository.ProviderLocal})
+	auther := jwtauth.New(&jwtauth.DBStore{DB: db}, db, jwtauth.SetSigningMethod(jwt.SigningMethodHS256), jwtauth.SetSigningKey([]byte("0123456789abcdef0123456789abcdef")), jwtauth.SetExpired(60), jwtauth.SetRefreshExpired(120))
+	svc := authService.NewAuthService(auther, repo, &authService.Options{})
+
+	ctx := context.Background()
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		res, err := svc.Login(ctx, &schema.LoginArgs{Username: "admin", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, res.AccessToken)
+	}
+	reply, err := svc.SessionList(ctx, user.ID.String(), tokens[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := 0
+	for _, s := range reply.Data {
+		if s.Current {
+			current++
+		}
+	}
+	// 两次登录各有访问令牌和刷新令牌
+	if len(reply.Data) != 4 || current != 1 {
+		t.Fatalf("unexpected sessions: %+v", reply.Data)
+	}
+
+	apiToken, err := svc.APITokenCreate(ctx, user.ID.String(), &schema.APITokenCreateArgs{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.LogoutAll(ctx, user.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if _, _, _, err := auther.ParseToken(token, "access_token"); err == nil {
+			t.Fatal("expected token to be revoked")
+		}
+	}
+	if _, err := auther.ParseAPIToken(apiToken.Token); err == nil {
+		t.Fatal("expected api token to be revoked")
+	}
+	if reply, _ := svc.SessionList(ctx, user.ID.String(), tokens[0]); len(reply.Data) != 0 {
+		t.Fatalf("expected no sessions, got %+v", reply.Data)
+	}
+}
+
+func TestOIDCAdminRequiresTOTP(t *testing.T) {
+	db := openTestDB(t)
+	svc, provider := newOIDCService(t, db, map[string]interface{}{"preferred_username": "alice", "groups": []string{"ops"}})
+	svc.Options.AdminRequireTOTP = true
+	svc.Options.OIDC.AdminClaim = "groups"
+	svc.Options.OIDC.AdminValues = []string{"ops"}
+
+	ctx := context.Background()
+	authURL, stateCookie, err := svc.OIDCLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.Authorize(t, authURL)
+	u, _ := url.Parse(authURL)
+	res, err := svc.OIDCCallback(ctx, &schema.OIDCCallbackArgs{Code: oidctest.Code, State: u.Query().Get("state")}, stateCookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 通过 OIDC 创建的管理员同样需要先绑定 TOTP
+	if res.AccessToken != "" || !res.TOTPSetupRequired || res.TOTPToken == "" {
+		t.Fatalf("expected totp setup challenge, got %+v", res)
+	}
+}
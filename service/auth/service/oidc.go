@@ -0,0 +1,129 @@
+// Package service
+// Date: 2024/4/17 16:02
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/contextx"
+	"github.com/amuluze/amprobe/pkg/oidc"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/service/auth/repository"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amutool/errors"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	oidcStatePrefix = "oidc_"
+	// OIDCStateCookie 保存 state 的哈希，回调时校验请求来自发起登录的浏览器，防止登录 CSRF
+	OIDCStateCookie = "amprobe_oidc_state"
+)
+
+var errOIDCDisabled = errors.New400Error("oidc login is not enabled")
+
+type OIDCOptions struct {
+	Provider      *oidc.Provider
+	UsernameClaim string
+	AdminClaim    string
+	AdminValues   []string
+	FrontendURL   string
+}
+
+type oidcState struct {
+	verifier string
+	nonce    string
+}
+
+// OIDCLogin 生成跳转到身份提供方的授权地址，同时返回需要写入 OIDCStateCookie 的值
+func (a *AuthService) OIDCLogin(ctx context.Context) (string, string, error) {
+	if a.Options.OIDC == nil {
+		return "", "", errOIDCDisabled
+	}
+	state := oidc.NewState()
+	st := oidcState{verifier: oidc.NewVerifier(), nonce: oidc.NewState()}
+	authURL, err := a.Options.OIDC.Provider.AuthCodeURL(ctx, state, st.nonce, st.verifier)
+	if err != nil {
+		slog.Error("oidc discovery failed", "error", err)
+		return "", "", errors.New500Error(err.Error())
+	}
+	a.challenges.Set(oidcStatePrefix+state, st, cache.DefaultExpiration)
+	return authURL, hash.SHA256String(state), nil
+}
+
+// OIDCCallback 校验授权回调，自动创建本地用户并签发令牌；stateCookie 为浏览器带回的 OIDCStateCookie
+func (a *AuthService) OIDCCallback(ctx context.Context, args *schema.OIDCCallbackArgs, stateCookie string) (*schema.LoginResult, error) {
+	opts := a.Options.OIDC
+	if opts == nil {
+		return nil, errOIDCDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(stateCookie), []byte(hash.SHA256String(args.State))) != 1 {
+		return nil, errors.New400Error("invalid oidc state")
+	}
+	v, ok := a.challenges.Get(oidcStatePrefix + args.State)
+	if !ok {
+		return nil, errors.New400Error("invalid oidc state")
+	}
+	a.challenges.Delete(oidcStatePrefix + args.State)
+	if args.Error != "" {
+		slog.Error("oidc provider returned error", "error", args.Error, "description", args.ErrorDescription)
+		return nil, errors.New400Error(args.Error)
+	}
+	st := v.(oidcState)
+
+	token, err := opts.Provider.Exchange(ctx, args.Code, st.verifier)
+	if err != nil {
+		slog.Error("oidc code exchange failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	claims, err := opts.Provider.VerifyIDToken(ctx, token.IDToken, st.nonce)
+	if err != nil {
+		slog.Error("oidc id token verify failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	username, _ := claims[opts.UsernameClaim].(string)
+	if username == "" {
+		return nil, errors.New400Error("oidc username claim missing: " + opts.UsernameClaim)
+	}
+
+	isAdmin := "0"
+	if opts.AdminClaim != "" {
+		admins := make(map[string]struct{}, len(opts.AdminValues))
+		for _, val := range opts.AdminValues {
+			admins[val] = struct{}{}
+		}
+		for _, val := range oidc.ClaimValues(claims, opts.AdminClaim) {
+			if _, ok := admins[val]; ok {
+				isAdmin = "1"
+				break
+			}
+		}
+	}
+
+	u, err := a.AuthRepo.ProvisionUser(ctx, username, repository.ProviderOIDC, isAdmin)
+	if err != nil {
+		slog.Error("oidc provision user failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	// 与密码登录相同，启用 TOTP 的用户和被要求绑定 TOTP 的管理员需要完成二次验证
+	res, err := a.issueToken(u)
+	if err != nil {
+		return nil, err
+	}
+	a.Auth.RecordAudit(&auth.AuditEntry{
+		Username:   u.Username,
+		Operate:    "登录",
+		ResourceID: u.Username,
+		ClientIP:   contextx.FromClientIP(ctx),
+		UserAgent:  contextx.FromUserAgent(ctx),
+		Method:     "GET",
+		Path:       "/api/v1/auth/oidc/callback",
+		Status:     200,
+	})
+	return res, nil
+}
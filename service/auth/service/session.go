@@ -0,0 +1,51 @@
+// Package service
+// Date: 2024/4/20 15:12
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amutool/errors"
+)
+
+// sessionID 会话标识，取令牌哈希的前缀，不暴露完整哈希
+func sessionID(tokenHash string) string {
+	return tokenHash[:16]
+}
+
+func (a *AuthService) SessionList(ctx context.Context, userID, token string) (*schema.SessionListReply, error) {
+	sessions, err := a.Auth.ListSessions(userID)
+	if err != nil {
+		slog.Error("list sessions failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	current := hash.SHA256String(token)
+	list := make([]schema.Session, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, schema.Session{
+			ID:        sessionID(s.TokenHash),
+			TokenType: s.TokenType,
+			ExpiresAt: s.ExpiresAt.Unix(),
+			Current:   s.TokenHash == current,
+		})
+	}
+	return &schema.SessionListReply{Data: list}, nil
+}
+
+// LogoutAll 注销用户的全部会话（包括当前会话），并吊销全部访问令牌
+func (a *AuthService) LogoutAll(ctx context.Context, userID string) error {
+	if err := a.Auth.DestroyUserTokens(userID); err != nil {
+		slog.Error("destroy user tokens failed", "error", err)
+		return errors.New400Error(err.Error())
+	}
+	if err := a.AuthRepo.APITokenRevokeAll(ctx, userID); err != nil {
+		slog.Error("revoke api tokens failed", "error", err)
+		return errors.New500Error(err.Error())
+	}
+	return nil
+}
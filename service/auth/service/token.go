@@ -0,0 +1,81 @@
+// Package service
+// Date: 2024/4/19 10:40
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amutool/errors"
+)
+
+func toAPIToken(t *model.APIToken) schema.APIToken {
+	item := schema.APIToken{
+		ID:       t.ID,
+		Name:     t.Name,
+		Prefix:   t.Prefix,
+		ReadOnly: t.ReadOnly,
+		Created:  t.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if t.ExpiresAt != nil {
+		item.ExpiresAt = t.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	if t.LastUsedAt != nil {
+		item.LastUsedAt = t.LastUsedAt.Format("2006-01-02 15:04:05")
+	}
+	return item
+}
+
+func (a *AuthService) APITokenCreate(ctx context.Context, userID string, args *schema.APITokenCreateArgs) (*schema.APITokenCreateResult, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.New500Error(err.Error())
+	}
+	plain := auth.APITokenPrefix + hex.EncodeToString(buf)
+	token := &model.APIToken{
+		UserID:    userID,
+		Name:      args.Name,
+		TokenHash: hash.SHA256String(plain),
+		Prefix:    plain[:len(auth.APITokenPrefix)+6],
+		ReadOnly:  args.ReadOnly,
+	}
+	if args.ExpiresIn > 0 {
+		expiresAt := time.Now().AddDate(0, 0, args.ExpiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := a.AuthRepo.APITokenCreate(ctx, token); err != nil {
+		slog.Error("create api token failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	return &schema.APITokenCreateResult{APIToken: toAPIToken(token), Token: plain}, nil
+}
+
+func (a *AuthService) APITokenList(ctx context.Context, userID string) (*schema.APITokenListReply, error) {
+	tokens, err := a.AuthRepo.APITokenList(ctx, userID)
+	if err != nil {
+		slog.Error("list api token failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	list := make([]schema.APIToken, 0, len(tokens))
+	for i := range tokens {
+		list = append(list, toAPIToken(&tokens[i]))
+	}
+	return &schema.APITokenListReply{Data: list}, nil
+}
+
+func (a *AuthService) APITokenRevoke(ctx context.Context, userID string, args *schema.APITokenRevokeArgs) error {
+	if err := a.AuthRepo.APITokenRevoke(ctx, userID, args.ID); err != nil {
+		slog.Error("revoke api token failed", "error", err)
+		return errors.New400Error(err.Error())
+	}
+	return nil
+}
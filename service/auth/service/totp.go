@@ -0,0 +1,240 @@
+// Package service
+// Date: 2024/4/16 11:05
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/totp"
+	"github.com/amuluze/amprobe/pkg/utils/hash"
+	"github.com/amuluze/amprobe/pkg/utils/uuid"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amutool/errors"
+	"github.com/patrickmn/go-cache"
+)
+
+const recoveryCodeCount = 10
+
+// 同一临时令牌允许的验证码错误次数，超过后需要重新输入密码
+const (
+	maxCodeFailures    = 5
+	totpFailuresPrefix = "totp_failures_"
+)
+
+var (
+	errInvalidCode     = errors.New400Error("invalid totp code")
+	errTooManyFailures = errors.New400Error("too many invalid totp codes, please login again")
+)
+
+// newChallenge 密码校验通过后生成临时令牌，客户端需携带验证码完成第二步登录
+func (a *AuthService) newChallenge(userID string, setup bool) (*schema.LoginResult, error) {
+	token := uuid.MustString()
+	a.challenges.Set(token, userID, cache.DefaultExpiration)
+	return &schema.LoginResult{
+		TOTPRequired:      !setup,
+		TOTPSetupRequired: setup,
+		TOTPToken:         token,
+	}, nil
+}
+
+func (a *AuthService) challengeUser(ctx context.Context, token string) (*model.User, error) {
+	v, ok := a.challenges.Get(token)
+	// 缓存中同时保存了失败次数和 OIDC state，只接受临时令牌
+	userID, isToken := v.(string)
+	if !ok || !isToken {
+		return nil, errors.New400Error("totp token expired")
+	}
+	return a.AuthRepo.UserByID(ctx, userID)
+}
+
+// ChallengeUsername 返回临时令牌对应的用户名，令牌无效时返回空
+func (a *AuthService) ChallengeUsername(ctx context.Context, token string) string {
+	u, err := a.challengeUser(ctx, token)
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// codeFailed 记录临时令牌的一次验证码错误，达到 maxCodeFailures 次后删除令牌，防止在有效期内穷举验证码
+func (a *AuthService) codeFailed(token string) error {
+	key := totpFailuresPrefix + token
+	_ = a.challenges.Add(key, 0, cache.DefaultExpiration)
+	failures, err := a.challenges.IncrementInt(key, 1)
+	if err != nil || failures >= maxCodeFailures {
+		a.deleteChallenge(token)
+		return errTooManyFailures
+	}
+	return errInvalidCode
+}
+
+func (a *AuthService) deleteChallenge(token string) {
+	a.challenges.Delete(token)
+	a.challenges.Delete(totpFailuresPrefix + token)
+}
+
+// validateTOTP 校验 TOTP 验证码，已通过的验证码在有效期内不能再次使用
+func (a *AuthService) validateTOTP(ctx context.Context, u *model.User, code string) bool {
+	step, ok := totp.ValidateStep(u.TOTPSecret, code, time.Now())
+	if !ok {
+		return false
+	}
+	used, err := a.AuthRepo.TOTPUseStep(ctx, u.ID.String(), step)
+	if err != nil {
+		slog.Error("record totp step failed", "error", err)
+		return false
+	}
+	if !used {
+		slog.Warn("totp code reused", "username", u.Username)
+	}
+	return used
+}
+
+// verifyCode 校验 TOTP 验证码，失败时尝试作为恢复码使用
+func (a *AuthService) verifyCode(ctx context.Context, u *model.User, code string) bool {
+	if a.validateTOTP(ctx, u, code) {
+		return true
+	}
+	if !u.TOTPEnabled || u.RecoveryCodes == "" {
+		return false
+	}
+	hashed := hash.SHA1String(strings.ToLower(strings.TrimSpace(code)))
+	codes := strings.Split(u.RecoveryCodes, ",")
+	for i, c := range codes {
+		if c != hashed {
+			continue
+		}
+		// 恢复码只能使用一次
+		codes = append(codes[:i], codes[i+1:]...)
+		if err := a.AuthRepo.TOTPUpdate(ctx, u.ID.String(), u.TOTPSecret, u.TOTPEnabled, strings.Join(codes, ",")); err != nil {
+			slog.Error("consume recovery code failed", "error", err)
+			return false
+		}
+		slog.Warn("recovery code used", "username", u.Username, "remaining", len(codes))
+		return true
+	}
+	return false
+}
+
+func (a *AuthService) enroll(ctx context.Context, u *model.User) (*schema.TOTPEnrollResult, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errors.New500Error(err.Error())
+	}
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, errors.New500Error(err.Error())
+	}
+	hashed := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashed = append(hashed, hash.SHA1String(c))
+	}
+	// 密钥先以未启用状态保存，验证码校验通过后才启用
+	if err := a.AuthRepo.TOTPUpdate(ctx, u.ID.String(), secret, false, strings.Join(hashed, ",")); err != nil {
+		slog.Error("save totp secret failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	return &schema.TOTPEnrollResult{
+		Secret:        secret,
+		URI:           totp.URI(a.Options.TOTPIssuer, u.Username, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// LoginTOTP 两步登录的第二步，验证码通过后签发令牌
+func (a *AuthService) LoginTOTP(ctx context.Context, args *schema.LoginTOTPArgs) (*schema.LoginResult, error) {
+	u, err := a.challengeUser(ctx, args.TOTPToken)
+	if err != nil {
+		slog.Error("get totp challenge user failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	if u.TOTPSecret == "" {
+		return nil, errors.New400Error("totp not enrolled")
+	}
+	if !a.verifyCode(ctx, u, args.Code) {
+		slog.Warn("invalid totp code", "username", u.Username)
+		return nil, a.codeFailed(args.TOTPToken)
+	}
+	// 强制绑定流程中，首次验证通过即启用
+	if !u.TOTPEnabled {
+		if err := a.AuthRepo.TOTPUpdate(ctx, u.ID.String(), u.TOTPSecret, true, u.RecoveryCodes); err != nil {
+			slog.Error("enable totp failed", "error", err)
+			return nil, errors.New400Error(err.Error())
+		}
+	}
+	a.deleteChallenge(args.TOTPToken)
+
+	tokenInfo, err := a.Auth.GenerateToken(u.ID.String(), u.Username, u.IsAdmin)
+	if err != nil {
+		slog.Error("generate token failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	return &schema.LoginResult{
+		AccessToken:  tokenInfo.GetAccessToken(),
+		RefreshToken: tokenInfo.GetRefreshToken(),
+	}, nil
+}
+
+// LoginTOTPSetup 要求强制绑定但尚未绑定的用户，凭临时令牌获取密钥
+func (a *AuthService) LoginTOTPSetup(ctx context.Context, args *schema.LoginTOTPSetupArgs) (*schema.TOTPEnrollResult, error) {
+	u, err := a.challengeUser(ctx, args.TOTPToken)
+	if err != nil {
+		slog.Error("get totp challenge user failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	if u.TOTPEnabled {
+		return nil, errors.New400Error("totp already enabled")
+	}
+	return a.enroll(ctx, u)
+}
+
+func (a *AuthService) TOTPEnroll(ctx context.Context, userID string) (*schema.TOTPEnrollResult, error) {
+	u, err := a.AuthRepo.UserByID(ctx, userID)
+	if err != nil {
+		slog.Error("get user failed", "error", err)
+		return nil, errors.New400Error(err.Error())
+	}
+	if u.TOTPEnabled {
+		return nil, errors.New400Error("totp already enabled")
+	}
+	return a.enroll(ctx, u)
+}
+
+func (a *AuthService) TOTPActivate(ctx context.Context, userID string, args *schema.TOTPCodeArgs) error {
+	u, err := a.AuthRepo.UserByID(ctx, userID)
+	if err != nil {
+		slog.Error("get user failed", "error", err)
+		return errors.New400Error(err.Error())
+	}
+	if u.TOTPSecret == "" {
+		return errors.New400Error("totp not enrolled")
+	}
+	if !a.validateTOTP(ctx, u, args.Code) {
+		return errInvalidCode
+	}
+	return a.AuthRepo.TOTPUpdate(ctx, userID, u.TOTPSecret, true, u.RecoveryCodes)
+}
+
+func (a *AuthService) TOTPDisable(ctx context.Context, userID string, args *schema.TOTPCodeArgs) error {
+	u, err := a.AuthRepo.UserByID(ctx, userID)
+	if err != nil {
+		slog.Error("get user failed", "error", err)
+		return errors.New400Error(err.Error())
+	}
+	if !u.TOTPEnabled {
+		return errors.New400Error("totp not enabled")
+	}
+	if a.Options.AdminRequireTOTP && u.IsAdmin == "1" {
+		return errors.New400Error("totp is required for admin")
+	}
+	if !a.verifyCode(ctx, u, args.Code) {
+		return errInvalidCode
+	}
+	return a.AuthRepo.TOTPUpdate(ctx, userID, "", false, "")
+}
@@ -0,0 +1,45 @@
+// Package service
+// Date: 2024/5/6 10:20
+// Author: Amu
+// Description:
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/amuluze/amprobe/service/model"
+)
+
+// collect 执行采集项并记录耗时、样本数和错误，上一次采集未结束时跳过本周期
+func (a *TimedTask) collect(name string, timestamp time.Time, fn func(time.Time) (int, error)) {
+	if !a.health.Begin(name) {
+		slog.Warn("previous collection still running, skip this tick", "collector", name)
+		return
+	}
+	start := time.Now()
+	samples, err := fn(timestamp)
+	a.health.End(name, start, samples, err)
+	if err != nil {
+		slog.Error("collector failed", "collector", name, "duration", time.Since(start), "error", err)
+	}
+}
+
+// selfMetrics 各采集项最近一次的耗时以及累计错误、跳过次数
+func (a *TimedTask) selfMetrics(timestamp time.Time) []metricstore.Sample {
+	collectors := a.health.Collectors()
+	samples := make([]metricstore.Sample, 0, len(collectors)*3)
+	for _, c := range collectors {
+		if c.LastRun.IsZero() {
+			continue
+		}
+		labels := metricstore.Labels{model.LabelCollector: c.Name}
+		samples = append(samples,
+			metricstore.Sample{Metric: model.MetricCollectorDuration, Labels: labels, Timestamp: timestamp, Value: c.Duration.Seconds()},
+			metricstore.Sample{Metric: model.MetricCollectorErrors, Labels: labels, Timestamp: timestamp, Value: float64(c.Errors)},
+			metricstore.Sample{Metric: model.MetricCollectorSkipped, Labels: labels, Timestamp: timestamp, Value: float64(c.Skipped)},
+		)
+	}
+	return samples
+}
@@ -5,35 +5,176 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/amuluze/amprobe/configs"
+	"github.com/amuluze/amprobe/pkg/configx"
+	"github.com/amuluze/amprobe/pkg/psutil"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/viper"
 )
 
+// EnvPrefix 环境变量前缀，如 AMPROBE_AUTH_SIGNINGKEY 对应 Auth.SigningKey
+const EnvPrefix = "AMPROBE"
+
 type Config struct {
-	Fiber    Fiber
-	Gorm     Gorm
-	DB       DB
-	Disk     Disk
-	Task     Task
-	Ethernet Ethernet
-	Logger   Logger
-	Auth     Auth
-	InitData InitData
+	Fiber     Fiber
+	Gorm      Gorm
+	DB        DB
+	Disk      Disk
+	Task      Task
+	Ethernet  Ethernet
+	Logger    Logger
+	Auth      Auth
+	OIDC      OIDC
+	LDAP      LDAP
+	Redis     Redis
+	Audit     Audit
+	Metric    Metric
+	Container Container
+	Exporters []Exporter
+	InitData  InitData
+
+	// explicit 通过环境变量或命令行参数显式设置的配置项(viper 键)，这些项优先于数据库中保存的设置
+	explicit map[string]struct{}
+}
+
+// Explicit 配置项是否通过环境变量或命令行参数显式设置，name 为 viper 键，如 task.interval
+func (c *Config) Explicit(name string) bool {
+	_, ok := c.explicit[name]
+	return ok
 }
 
 // NewConfig Load config file (toml/json/yaml)
+// 优先级从高到低: 命令行参数 > AMPROBE_ 环境变量 > 配置文件 > 默认值；configFile 为空时只使用环境变量和命令行参数
+// 切片可以用逗号分隔，如 AMPROBE_DISK_DEVICES=/dev/sda,/dev/sdb；Exporters 等结构体切片和 map 使用 JSON
+// 例外: Disk.Devices、Ethernet.Names、Task.Interval、Task.NotMonitorDocker 首次启动后以数据库为准，
+// 配置文件中的值只用于初始化，只有环境变量和命令行参数会覆盖数据库中的值，见 loadSettings
 func NewConfig(configFile string) (*Config, error) {
+	if err := readConfig(configFile); err != nil {
+		return nil, err
+	}
 	config := &Config{}
+	if err := viper.Unmarshal(config, configx.DecodeHook()); err != nil {
+		return nil, err
+	}
+	config.explicit = explicitKeys()
 
-	viper.SetConfigFile(configFile)
+	return config, nil
+}
+
+// flagOverrides 命令行参数设置的配置项，由 applyOverrides 记录
+var flagOverrides = make(map[string]struct{})
+
+// explicitKeys 设置了环境变量或命令行参数的配置项
+func explicitKeys() map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, key := range ConfigKeys() {
+		_, env := os.LookupEnv(key.Env)
+		_, flag := flagOverrides[key.Name]
+		if env || flag {
+			keys[key.Name] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// readConfig 绑定环境变量并读取配置文件
+func readConfig(configFile string) error {
+	if err := configx.BindEnv(viper.GetViper(), EnvPrefix, Config{}); err != nil {
+		return err
+	}
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+		if err := viper.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigKeys 所有配置项及对应的环境变量，命令行参数名与 viper 键相同，如 --task.interval
+func ConfigKeys() []configx.Key {
+	return configx.Keys(EnvPrefix, Config{})
+}
+
+// SetOverrides 设置命令行参数覆盖的配置项，键为 ConfigKeys 中的 Name
+func SetOverrides(overrides map[string]string) Option {
+	return func(o *options) {
+		o.Overrides = overrides
+	}
+}
+
+// applyOverrides 命令行参数通过 viper.Set 设置，优先级高于环境变量和配置文件
+func (o *options) applyOverrides() {
+	for key, value := range o.Overrides {
+		viper.Set(key, value)
+		flagOverrides[key] = struct{}{}
+	}
+}
 
-	if err := viper.ReadInConfig(); err != nil {
+// PrintConfig 返回生效配置的 TOML 文本，敏感字段已脱敏，供命令行使用
+func PrintConfig(ctx context.Context, opts ...Option) ([]byte, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.applyOverrides()
+	config, err := NewConfig(o.ConfigFile)
+	if err != nil {
 		return nil, err
 	}
-	if err := viper.Unmarshal(config); err != nil {
+	return toml.Marshal(configx.Redact(*config))
+}
+
+// DefaultConfig 以 configs/config.toml 为模板生成带注释的默认配置，写入 path 前调用：
+// 磁盘和网卡使用本机检测到的物理设备，签名密钥随机生成，初始化数据文件为配置文件同一目录下的 init.yaml
+func DefaultConfig(path string) ([]byte, error) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
 		return nil, err
 	}
 
-	return config, nil
+	devices, err := psutil.DiskDevices()
+	if err != nil {
+		slog.Warn("failed to detect disk devices", "error", err)
+	}
+	nics, err := psutil.NetInterfaces()
+	if err != nil {
+		slog.Warn("failed to detect network interfaces", "error", err)
+	}
+	devices = psutil.WholeDisks(devices)
+	nics = psutil.PhysicalInterfaces(nics)
+
+	// 初始化用户的数据文件不存在时不导入
+	initFile := filepath.Join(dir, "init.yaml")
+	_, statErr := os.Stat(initFile)
+
+	data, err := configx.SetValues(configs.Template, map[string]interface{}{
+		"Disk.Devices":            append([]string{}, devices...),
+		"Ethernet.Names":          append([]string{}, nics...),
+		"Logger.Level":            "info",
+		"Auth.SigningKey":         hex.EncodeToString(key),
+		"InitData.Enable":         statErr == nil,
+		"InitData.InitConfigFile": initFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	header := "# 由 amprobe config init 生成，[Disk]、[Ethernet] 为在 " + hostname + " 上检测到的设备: " +
+		strings.Join(append(devices, nics...), ", ") + "\n# 可执行 amprobe config validate 校验修改后的配置\n\n"
+	return append([]byte(header), data...), nil
 }
 
 type Fiber struct {
@@ -60,7 +201,7 @@ type DB struct {
 	Host     string
 	Port     string
 	User     string
-	Password string
+	Password string `secret:"true"`
 	DBName   string
 	SSLMode  string
 }
@@ -70,8 +211,14 @@ type Disk struct {
 }
 
 type Task struct {
-	Interval int
+	Interval         int
 	NotMonitorDocker bool
+	// ProcessTopN 每次采集 CPU 和内存占用前 N 的进程，0 使用默认值 10，小于 0 不采集
+	ProcessTopN int
+	// ContainerWorkers 并发读取容器资源使用的 worker 数，0 使用默认值 4
+	ContainerWorkers int
+	// ContainerTimeout 读取单个容器资源使用的超时时间，单位 s，0 使用默认值 10
+	ContainerTimeout int
 }
 
 type Ethernet struct {
@@ -88,10 +235,118 @@ type Logger struct {
 type Auth struct {
 	Enable         bool
 	SigningMethod  string
-	SigningKey     string
+	SigningKey     string `secret:"true"`
 	Expired        int
 	RefreshExpired int
 	Prefix         string
+	// TOTPIssuer 认证器 App 中显示的签发方名称
+	TOTPIssuer string
+	// AdminRequireTOTP 管理员账号必须启用 TOTP 两步验证
+	AdminRequireTOTP bool
+	// Provider 账号密码登录的认证方式: local / ldap / local+ldap
+	Provider string
+	// Store 令牌存储方式: memory / db / redis
+	Store string
+}
+
+type Audit struct {
+	// ChainKey 审计日志哈希链 HMAC 密钥，为空时使用 Auth.SigningKey
+	ChainKey string `secret:"true"`
+	// Sink 审计事件转发: 空(不转发) / file / syslog
+	Sink          string
+	SinkFile      string
+	SyslogNetwork string
+	SyslogAddr    string
+	SyslogTag     string
+	// RetentionDays 审计记录保留天数，过期记录归档后删除，0 表示永久保留
+	RetentionDays int
+	// ArchiveDir 归档文件目录
+	ArchiveDir string
+}
+
+type Container struct {
+	// Runtime auto / docker / podman / containerd，auto 依次探测 docker、podman、containerd 的 socket
+	Runtime string
+	// Endpoint 运行时 socket 地址，docker / podman 如 unix:///run/podman/podman.sock，containerd 如 /run/containerd/containerd.sock
+	Endpoint string
+	// Namespace containerd 命名空间
+	Namespace string
+	// CgroupRoot cgroup 挂载点，容器内运行时为宿主机 /sys 的挂载目录下的 fs/cgroup，为空时使用 HOST_SYS 或 /sys/fs/cgroup
+	CgroupRoot string
+}
+
+type Metric struct {
+	// Store 主机指标存储方式: db / tsdb
+	Store string
+	// Dir tsdb 数据目录
+	Dir string
+	// RetentionDays tsdb 数据保留天数
+	RetentionDays int
+}
+
+// Exporter 远端推送目标
+type Exporter struct {
+	// Type 推送协议: prometheus (remote-write) / influxdb (line protocol) / otlp-http / otlp-grpc
+	Type string
+	// URL 写入地址，otlp-grpc 为 host:port
+	URL      string
+	Username string
+	Password string `secret:"true"`
+	Token    string `secret:"true"`
+	// Headers 附加的请求头 (otlp-grpc 为 metadata)，可能包含认证信息，输出时脱敏
+	Headers map[string]string `secret:"true"`
+	// Insecure otlp-grpc 不使用 TLS
+	Insecure bool
+	// Timeout 单次发送超时，单位 s
+	Timeout int
+	// BatchSize 单次发送的最大采样数
+	BatchSize int
+	// FlushInterval 未攒满批次时的最长等待时间，单位 s
+	FlushInterval int
+	// QueueSize 内存中等待重试的最大批次数
+	QueueSize int
+	// BufferDir 远端不可用时的磁盘缓冲目录，为空不落盘
+	BufferDir string
+	// BufferMaxMB 磁盘缓冲上限
+	BufferMaxMB int
+	// Labels 附加标签，默认附加 instance=主机名；otlp 作为 Resource 属性，默认附加 host.name=主机名
+	Labels map[string]string
+}
+
+type Redis struct {
+	Addr     string
+	Password string `secret:"true"`
+	DB       int
+}
+
+type OIDC struct {
+	Enable       bool
+	Issuer       string
+	ClientID     string
+	ClientSecret string `secret:"true"`
+	RedirectURL  string
+	Scopes       []string
+	// UsernameClaim 作为本地用户名的声明
+	UsernameClaim string
+	// AdminClaim 与 AdminValues 任一值匹配时将用户设为管理员
+	AdminClaim  string
+	AdminValues []string
+	// FrontendURL 登录成功后携带令牌跳转的前端地址，为空时直接返回 JSON
+	FrontendURL string
+}
+
+type LDAP struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string `secret:"true"`
+	BaseDN             string
+	UserFilter         string
+	UsernameAttribute  string
+	GroupAttribute     string
+	AdminGroups        []string
+	Timeout            int
 }
 
 type InitData struct {
@@ -6,8 +6,8 @@ package repository
 
 import (
 	"context"
+	"github.com/amuluze/amprobe/pkg/containerx"
 	"github.com/amuluze/amprobe/service/schema"
-	"github.com/amuluze/amutool/docker"
 	"github.com/amuluze/amutool/errors"
 
 	"github.com/amuluze/amprobe/service/model"
@@ -33,15 +33,11 @@ type IContainerRepo interface {
 
 type ContainerRepo struct {
 	DB      *database.DB
-	Manager *docker.Manager
+	Runtime containerx.Runtime
 }
 
-func NewContainerRepo(db *database.DB) *ContainerRepo {
-	manager, err := docker.NewManager()
-	if err != nil {
-		panic(err)
-	}
-	return &ContainerRepo{DB: db, Manager: manager}
+func NewContainerRepo(db *database.DB, rt containerx.Runtime) *ContainerRepo {
+	return &ContainerRepo{DB: db, Runtime: rt}
 }
 
 func (a *ContainerRepo) ContainerList(ctx context.Context, args *schema.ContainerQueryArgs) (model.Containers, error) {
@@ -85,7 +81,7 @@ func (a *ContainerRepo) Version(ctx context.Context) (model.Docker, error) {
 }
 
 func (a *ContainerRepo) ContainerStart(ctx context.Context, args *schema.ContainerStartArgs) error {
-	err := a.Manager.StartContainer(ctx, args.ContainerID)
+	err := a.Runtime.StartContainer(ctx, args.ContainerID)
 	if err != nil {
 		return errors.New400Error("failed start container")
 	}
@@ -94,7 +90,7 @@ func (a *ContainerRepo) ContainerStart(ctx context.Context, args *schema.Contain
 }
 
 func (a *ContainerRepo) ContainerStop(ctx context.Context, args *schema.ContainerStopArgs) error {
-	err := a.Manager.StopContainer(ctx, args.ContainerID)
+	err := a.Runtime.StopContainer(ctx, args.ContainerID)
 	if err != nil {
 		return errors.New400Error("failed to stop container")
 	}
@@ -103,7 +99,7 @@ func (a *ContainerRepo) ContainerStop(ctx context.Context, args *schema.Containe
 }
 
 func (a *ContainerRepo) ContainerRemove(ctx context.Context, args *schema.ContainerRemoveArgs) error {
-	err := a.Manager.DeleteContainer(ctx, args.ContainerID)
+	err := a.Runtime.RemoveContainer(ctx, args.ContainerID)
 	if err != nil {
 		return errors.New400Error("failed to remove container")
 	}
@@ -112,7 +108,7 @@ func (a *ContainerRepo) ContainerRemove(ctx context.Context, args *schema.Contai
 }
 
 func (a *ContainerRepo) ContainerRestart(ctx context.Context, args *schema.ContainerRestartArgs) error {
-	err := a.Manager.RestartContainer(ctx, args.ContainerID)
+	err := a.Runtime.RestartContainer(ctx, args.ContainerID)
 	if err != nil {
 		return errors.New400Error("failed to restart container")
 	}
@@ -121,7 +117,7 @@ func (a *ContainerRepo) ContainerRestart(ctx context.Context, args *schema.Conta
 }
 
 func (a *ContainerRepo) ImageRemove(ctx context.Context, args *schema.ImageRemoveArgs) error {
-	err := a.Manager.RemoveImage(ctx, args.ImageID)
+	err := a.Runtime.RemoveImage(ctx, args.ImageID)
 	if err != nil {
 		return errors.New400Error(err.Error())
 	}
@@ -130,5 +126,5 @@ func (a *ContainerRepo) ImageRemove(ctx context.Context, args *schema.ImageRemov
 }
 
 func (a *ContainerRepo) ImagesPrune(ctx context.Context) error {
-	return a.Manager.PruneImages(ctx)
+	return a.Runtime.PruneImages(ctx)
 }
@@ -7,6 +7,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"github.com/amuluze/amprobe/pkg/containerx"
 	"github.com/amuluze/amprobe/pkg/utils"
 	"github.com/amuluze/amprobe/service/container/repository"
 	"github.com/amuluze/amprobe/service/schema"
@@ -46,7 +47,7 @@ func (a *ContainerService) ContainerList(ctx context.Context, args *schema.Conta
 	var list []schema.Container
 	for _, item := range mContainers {
 		list = append(list, schema.Container{
-			ID:            item.ContainerID[:6],
+			ID:            containerx.ShortID(item.ContainerID),
 			Name:          item.Name,
 			Image:         item.Image,
 			State:         item.State,
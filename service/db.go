@@ -5,11 +5,13 @@
 package service
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/amuluze/amprobe/pkg/migrate"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amutool/database"
 	"gorm.io/gorm/logger"
@@ -37,21 +39,67 @@ func NewDB(config *Config, models *model.Models) (*database.DB, error) {
 		return nil, err
 	}
 	if gormConfig.EnableAutoMigrate {
-		if dbType := gormConfig.DBType; strings.ToLower(dbType) == "mysql" {
-			db.Set("gorm:table_options", "ENGINE=InnoDB")
-		}
-		err := db.AutoMigrate(models.GetAllModels()...)
-		if err != nil {
+		if _, err := Migrate(db, models); err != nil {
 			return nil, err
 		}
 	}
 	db.Logger = logger.New(
-        log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-        logger.Config{
-            SlowThreshold: time.Second,   // 慢 SQL 阈值
-            LogLevel:      logger.Info,   // 日志级别
-            Colorful:      false,          // 禁用彩色打印
-        },
-    )
+		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
+		logger.Config{
+			SlowThreshold: time.Second, // 慢 SQL 阈值
+			LogLevel:      logger.Info, // 日志级别
+			Colorful:      false,       // 禁用彩色打印
+		},
+	)
 	return db, nil
 }
+
+// Migrate 执行未完成的数据库迁移
+func Migrate(db *database.DB, models *model.Models) ([]int, error) {
+	tx := db.DB
+	if db.Dialector.Name() == "mysql" {
+		tx = tx.Set("gorm:table_options", "ENGINE=InnoDB")
+	}
+	versions, err := migrate.Up(tx, models.Migrations())
+	if err != nil {
+		return versions, err
+	}
+	if len(versions) > 0 {
+		slog.Info("database migrated", "versions", versions)
+	}
+	return versions, nil
+}
+
+func openDB(opts ...Option) (*database.DB, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	config, err := NewConfig(o.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	// 命令行执行迁移时由调用方控制，这里不自动迁移
+	config.Gorm.EnableAutoMigrate = false
+	return NewDB(config, model.NewModels())
+}
+
+// MigrateUp 执行数据库迁移，供命令行使用
+func MigrateUp(ctx context.Context, opts ...Option) ([]int, error) {
+	db, err := openDB(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return Migrate(db, model.NewModels())
+}
+
+// MigrateStatus 查询数据库迁移状态，供命令行使用
+func MigrateStatus(ctx context.Context, opts ...Option) ([]migrate.Status, error) {
+	db, err := openDB(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return migrate.List(db.DB, model.NewModels().Migrations())
+}
@@ -0,0 +1,82 @@
+// Package service
+// Date: 2024/4/26 16:10
+// Author: Amu
+// Description:
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/exporter"
+	"github.com/amuluze/amprobe/pkg/metricstore"
+)
+
+func InitExporters(config *Config) (exporter.Exporters, func(), error) {
+	var exporters exporter.Exporters
+	for _, c := range config.Exporters {
+		httpConfig := exporter.HTTPConfig{
+			URL:      c.URL,
+			Username: c.Username,
+			Password: c.Password,
+			Token:    c.Token,
+			Headers:  c.Headers,
+			Timeout:  time.Duration(c.Timeout) * time.Second,
+		}
+		hostname, _ := os.Hostname()
+		labels := metricstore.Labels{}
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		var client exporter.Client
+		switch c.Type {
+		case "prometheus", "influxdb":
+			if _, ok := labels["instance"]; !ok && hostname != "" {
+				labels["instance"] = hostname
+			}
+			if c.Type == "prometheus" {
+				client = exporter.NewRemoteWriteClient(httpConfig)
+			} else {
+				client = exporter.NewInfluxClient(httpConfig)
+			}
+		case "otlp-http", "otlp-grpc":
+			// OTLP 的附加标签作为 Resource 属性
+			resource := labels
+			labels = nil
+			if _, ok := resource["host.name"]; !ok && hostname != "" {
+				resource["host.name"] = hostname
+			}
+			if c.Type == "otlp-http" {
+				client = exporter.NewOTLPHTTPClient(httpConfig, resource)
+				break
+			}
+			grpcClient, err := exporter.NewOTLPGRPCClient(c.URL, c.Insecure, httpConfig, resource)
+			if err != nil {
+				_ = exporters.Close()
+				return nil, nil, err
+			}
+			client = grpcClient
+		default:
+			_ = exporters.Close()
+			return nil, nil, fmt.Errorf("unknown exporter type: %s", c.Type)
+		}
+		e, err := exporter.New(client, exporter.Options{
+			BatchSize:      c.BatchSize,
+			FlushInterval:  time.Duration(c.FlushInterval) * time.Second,
+			QueueSize:      c.QueueSize,
+			BufferDir:      c.BufferDir,
+			BufferMaxBytes: int64(c.BufferMaxMB) << 20,
+			Timeout:        time.Duration(c.Timeout) * time.Second,
+			Labels:         labels,
+		})
+		if err != nil {
+			_ = exporters.Close()
+			return nil, nil, err
+		}
+		exporters = append(exporters, e)
+	}
+	var err error
+	cleanFunc := func() { err = exporters.Close() }
+	return exporters, cleanFunc, err
+}
@@ -115,3 +115,101 @@ func (a *HostAPI) NetUsage(ctx *fiber.Ctx) error {
 	}
 	return fiberx.Success(ctx, usage)
 }
+
+func (a *HostAPI) Processes(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	var args schema.ProcessQueryArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	processes, err := a.HostService.Processes(c, args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, processes)
+}
+
+func (a *HostAPI) SwapUsage(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	var args schema.SwapUsageArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	usage, err := a.HostService.SwapUsage(c, args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, usage)
+}
+
+func (a *HostAPI) PressureUsage(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	var args schema.PressureUsageArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	usage, err := a.HostService.PressureUsage(c, args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, usage)
+}
+
+func (a *HostAPI) NetErrors(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	var args schema.NetErrorsArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	reply, err := a.HostService.NetErrors(c, args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, reply)
+}
+
+func (a *HostAPI) Connections(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	reply, err := a.HostService.Connections(c)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, reply)
+}
+
+func (a *HostAPI) Sensors(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	reply, err := a.HostService.Sensors(c)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, reply)
+}
+
+func (a *HostAPI) SensorUsage(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	var args schema.SensorUsageArgs
+	if err := fiberx.ParseQuery(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	if err := validatex.ValidateStruct(args); err != nil {
+		return fiberx.Failure(ctx, errors.ErrBadRequest)
+	}
+	reply, err := a.HostService.SensorUsage(c, args)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, reply)
+}
@@ -7,7 +7,8 @@ package repository
 import (
 	"context"
 	"time"
-	
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/amuluze/amutool/database"
@@ -16,23 +17,33 @@ import (
 
 var HostRepoSet = wire.NewSet(NewHostRepo, wire.Bind(new(IHostRepo), new(*HostRepo)))
 
+// latestWindow 查询最新指标时回溯的时间范围
+const latestWindow = 10 * time.Minute
+
 type IHostRepo interface {
 	HostInfo(ctx context.Context) (model.Host, error)
 	CPUInfo(ctx context.Context) (model.CPU, error)
-	CPUUsage(ctx context.Context, args schema.CPUUsageArgs) ([]model.CPU, error)
+	CPUUsage(ctx context.Context, args schema.CPUUsageArgs) ([]metricstore.Series, error)
 	MemInfo(ctx context.Context) (model.Memory, error)
-	MemUsage(ctx context.Context, args schema.MemoryUsageArgs) ([]model.Memory, error)
+	MemUsage(ctx context.Context, args schema.MemoryUsageArgs) ([]metricstore.Series, error)
 	DiskInfo(ctx context.Context) ([]model.Disk, error)
-	DiskUsage(ctx context.Context, args schema.DiskUsageArgs) ([]model.Disk, error)
-	NetUsage(ctx context.Context, args schema.NetworkUsageArgs) ([]model.Net, error)
+	DiskUsage(ctx context.Context, args schema.DiskUsageArgs) (map[string][]metricstore.Series, error)
+	NetUsage(ctx context.Context, args schema.NetworkUsageArgs) (recv, send []metricstore.Series, err error)
+	NetErrors(ctx context.Context, args schema.NetErrorsArgs) (map[string][]metricstore.Series, error)
+	SwapUsage(ctx context.Context, args schema.SwapUsageArgs) (in, out []metricstore.Series, err error)
+	PressureUsage(ctx context.Context, args schema.PressureUsageArgs) (some, full []metricstore.Series, err error)
+	Processes(ctx context.Context, args schema.ProcessQueryArgs) ([]model.Process, error)
+	Sensors(ctx context.Context) ([]model.Sensor, error)
+	SensorUsage(ctx context.Context, args schema.SensorUsageArgs) ([]model.Sensor, error)
 }
 
 type HostRepo struct {
-	DB *database.DB
+	DB    *database.DB
+	Store metricstore.MetricStore
 }
 
-func NewHostRepo(db *database.DB) *HostRepo {
-	return &HostRepo{DB: db}
+func NewHostRepo(db *database.DB, store metricstore.MetricStore) *HostRepo {
+	return &HostRepo{DB: db, Store: store}
 }
 
 func (h HostRepo) HostInfo(ctx context.Context) (model.Host, error) {
@@ -43,60 +54,279 @@ func (h HostRepo) HostInfo(ctx context.Context) (model.Host, error) {
 	return hostInfo, nil
 }
 
+// rangeQuery 构造范围查询，EndTime 为空时查询到当前时间
+func rangeQuery(metric string, startTime, endTime, step int64, agg string) metricstore.Query {
+	end := time.Now()
+	if endTime > 0 {
+		end = time.Unix(endTime, 0)
+	}
+	return metricstore.Query{
+		Metric:      metric,
+		Start:       time.Unix(startTime, 0),
+		End:         end,
+		Step:        time.Duration(step) * time.Second,
+		Aggregation: metricstore.Aggregation(agg),
+	}
+}
+
+// latest 查询指标各序列的最新采样点
+func (h HostRepo) latest(ctx context.Context, metric string) ([]metricstore.Series, error) {
+	now := time.Now()
+	series, err := h.Store.Query(ctx, metricstore.Query{Metric: metric, Start: now.Add(-latestWindow), End: now})
+	if err != nil {
+		return nil, err
+	}
+	for i := range series {
+		if n := len(series[i].Points); n > 0 {
+			series[i].Points = series[i].Points[n-1:]
+		}
+	}
+	return series, nil
+}
+
+// latestValue 查询无标签指标的最新值
+func (h HostRepo) latestValue(ctx context.Context, metric string) (metricstore.Point, error) {
+	series, err := h.latest(ctx, metric)
+	if err != nil {
+		return metricstore.Point{}, err
+	}
+	for _, s := range series {
+		if len(s.Points) > 0 {
+			return s.Points[0], nil
+		}
+	}
+	return metricstore.Point{}, nil
+}
+
 func (h HostRepo) CPUInfo(ctx context.Context) (model.CPU, error) {
 	var cpuInfo model.CPU
-	if err := h.DB.Order("timestamp desc").Take(&cpuInfo).Error; err != nil {
+	p, err := h.latestValue(ctx, model.MetricCPUPercent)
+	if err != nil {
 		return cpuInfo, err
 	}
+	cpuInfo.Timestamp = p.Timestamp
+	cpuInfo.CPUPercent = p.Value
 	return cpuInfo, nil
 }
 
-func (h HostRepo) CPUUsage(ctx context.Context, args schema.CPUUsageArgs) ([]model.CPU, error) {
-	var cpuInfos []model.CPU
-	if err := h.DB.Model(&model.CPU{}).Where("timestamp > ? and timestamp < ?", time.Unix(args.StartTime, 0), time.Unix(args.EndTime, 0)).Order("timestamp asc").Find(&cpuInfos).Error; err != nil {
-		return cpuInfos, err
-	}
-	return cpuInfos, nil
+func (h HostRepo) CPUUsage(ctx context.Context, args schema.CPUUsageArgs) ([]metricstore.Series, error) {
+	return h.Store.Query(ctx, rangeQuery(model.MetricCPUPercent, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+}
+
+// memoryTrending 内存趋势可选的指标
+var memoryTrending = map[string]string{
+	"":          model.MetricMemoryPercent,
+	"percent":   model.MetricMemoryPercent,
+	"used":      model.MetricMemoryUsed,
+	"available": model.MetricMemoryAvailable,
+	"buffers":   model.MetricMemoryBuffers,
+	"cached":    model.MetricMemoryCached,
+	"swap_used": model.MetricSwapUsed,
 }
 
 func (h HostRepo) MemInfo(ctx context.Context) (model.Memory, error) {
 	var memInfo model.Memory
-	if err := h.DB.Order("timestamp desc").Take(&memInfo).Error; err != nil {
-		return memInfo, err
+	fields := []struct {
+		metric string
+		value  *float64
+	}{
+		{model.MetricMemoryPercent, &memInfo.MemPercent},
+		{model.MetricMemoryTotal, &memInfo.MemTotal},
+		{model.MetricMemoryUsed, &memInfo.MemUsed},
+		{model.MetricMemoryAvailable, &memInfo.MemAvailable},
+		{model.MetricMemoryBuffers, &memInfo.MemBuffers},
+		{model.MetricMemoryCached, &memInfo.MemCached},
+		{model.MetricSwapTotal, &memInfo.SwapTotal},
+		{model.MetricSwapUsed, &memInfo.SwapUsed},
+	}
+	for _, f := range fields {
+		p, err := h.latestValue(ctx, f.metric)
+		if err != nil {
+			return memInfo, err
+		}
+		if f.metric == model.MetricMemoryPercent {
+			memInfo.Timestamp = p.Timestamp
+		}
+		*f.value = p.Value
 	}
 	return memInfo, nil
 }
 
-func (h HostRepo) MemUsage(ctx context.Context, args schema.MemoryUsageArgs) ([]model.Memory, error) {
-	var memInfos []model.Memory
-	if err := h.DB.Model(&model.Memory{}).Where("timestamp > ?", time.Unix(args.StartTime, 0)).Order("timestamp asc").Find(&memInfos).Error; err != nil {
-		return memInfos, err
+func (h HostRepo) MemUsage(ctx context.Context, args schema.MemoryUsageArgs) ([]metricstore.Series, error) {
+	return h.Store.Query(ctx, rangeQuery(memoryTrending[args.Metric], args.StartTime, args.EndTime, args.Step, args.Aggregation))
+}
+
+func (h HostRepo) SwapUsage(ctx context.Context, args schema.SwapUsageArgs) (in, out []metricstore.Series, err error) {
+	in, err = h.Store.Query(ctx, rangeQuery(model.MetricSwapIn, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err = h.Store.Query(ctx, rangeQuery(model.MetricSwapOut, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+	if err != nil {
+		return nil, nil, err
 	}
-	return memInfos, nil
+	return in, out, nil
+}
+
+func (h HostRepo) PressureUsage(ctx context.Context, args schema.PressureUsageArgs) (some, full []metricstore.Series, err error) {
+	someQuery := rangeQuery(model.MetricPressureSome, args.StartTime, args.EndTime, args.Step, args.Aggregation)
+	fullQuery := rangeQuery(model.MetricPressureFull, args.StartTime, args.EndTime, args.Step, args.Aggregation)
+	if args.Resource != "" {
+		someQuery.Labels = metricstore.Labels{model.LabelResource: args.Resource}
+		fullQuery.Labels = metricstore.Labels{model.LabelResource: args.Resource}
+	}
+	some, err = h.Store.Query(ctx, someQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	full, err = h.Store.Query(ctx, fullQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	return some, full, nil
 }
 
 func (h HostRepo) DiskInfo(ctx context.Context) ([]model.Disk, error) {
+	reads, err := h.latest(ctx, model.MetricDiskRead)
+	if err != nil {
+		return nil, err
+	}
+	writes, err := h.latest(ctx, model.MetricDiskWrite)
+	if err != nil {
+		return nil, err
+	}
 	var diskInfos []model.Disk
-	subQuery := h.DB.Model(&model.Disk{}).Select("max(id)").Group("device")
-
-	if err := h.DB.Model(&model.Disk{}).Where("id IN (?)", subQuery).Find(&diskInfos).Error; err != nil {
-		return diskInfos, err
+	index := make(map[string]int)
+	for _, s := range reads {
+		if len(s.Points) == 0 {
+			continue
+		}
+		disk := model.Disk{Device: s.Labels[model.LabelDevice], DiskRead: s.Points[0].Value}
+		disk.CreatedAt = s.Points[0].Timestamp
+		index[disk.Device] = len(diskInfos)
+		diskInfos = append(diskInfos, disk)
+	}
+	for _, s := range writes {
+		if i, ok := index[s.Labels[model.LabelDevice]]; ok && len(s.Points) > 0 {
+			diskInfos[i].DiskWrite = s.Points[0].Value
+		}
 	}
 	return diskInfos, nil
 }
 
-func (h HostRepo) DiskUsage(ctx context.Context, args schema.DiskUsageArgs) ([]model.Disk, error) {
-	var diskInfos []model.Disk
-	if err := h.DB.Model(&model.Disk{}).Where("created_at > ?", time.Unix(args.StartTime, 0)).Order("created_at asc").Find(&diskInfos).Error; err != nil {
-		return diskInfos, err
+// diskMetrics 磁盘趋势包含的指标
+var diskMetrics = []string{
+	model.MetricDiskRead,
+	model.MetricDiskWrite,
+	model.MetricDiskReadIOPS,
+	model.MetricDiskWriteIOPS,
+	model.MetricDiskAwait,
+	model.MetricDiskQueueDepth,
+	model.MetricDiskUtil,
+}
+
+// DiskUsage 返回磁盘各指标的序列，key 为指标名
+func (h HostRepo) DiskUsage(ctx context.Context, args schema.DiskUsageArgs) (map[string][]metricstore.Series, error) {
+	res := make(map[string][]metricstore.Series, len(diskMetrics))
+	for _, metric := range diskMetrics {
+		series, err := h.Store.Query(ctx, rangeQuery(metric, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+		if err != nil {
+			return nil, err
+		}
+		res[metric] = series
 	}
-	return diskInfos, nil
+	return res, nil
+}
+
+func (h HostRepo) NetUsage(ctx context.Context, args schema.NetworkUsageArgs) (recv, send []metricstore.Series, err error) {
+	recv, err = h.Store.Query(ctx, rangeQuery(model.MetricNetRecv, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+	if err != nil {
+		return nil, nil, err
+	}
+	send, err = h.Store.Query(ctx, rangeQuery(model.MetricNetSend, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+	if err != nil {
+		return nil, nil, err
+	}
+	return recv, send, nil
+}
+
+// netErrorMetrics 网卡错误趋势包含的指标
+var netErrorMetrics = []string{
+	model.MetricNetPacketsRecv,
+	model.MetricNetPacketsSent,
+	model.MetricNetErrIn,
+	model.MetricNetErrOut,
+	model.MetricNetDropIn,
+	model.MetricNetDropOut,
 }
 
-func (h HostRepo) NetUsage(ctx context.Context, args schema.NetworkUsageArgs) ([]model.Net, error) {
-	var netInfos []model.Net
-	if err := h.DB.Model(&model.Net{}).Where("created_at > ?", time.Unix(args.StartTime, 0)).Order("created_at asc").Find(&netInfos).Error; err != nil {
-		return netInfos, err
+// NetErrors 返回网卡包、错误、丢包指标的序列，key 为指标名
+func (h HostRepo) NetErrors(ctx context.Context, args schema.NetErrorsArgs) (map[string][]metricstore.Series, error) {
+	res := make(map[string][]metricstore.Series, len(netErrorMetrics))
+	for _, metric := range netErrorMetrics {
+		series, err := h.Store.Query(ctx, rangeQuery(metric, args.StartTime, args.EndTime, args.Step, args.Aggregation))
+		if err != nil {
+			return nil, err
+		}
+		res[metric] = series
+	}
+	return res, nil
+}
+
+func (h HostRepo) Processes(ctx context.Context, args schema.ProcessQueryArgs) ([]model.Process, error) {
+	var processes []model.Process
+	tx := h.DB.WithContext(ctx).Model(&model.Process{})
+	if args.StartTime == 0 {
+		// 最近一次采集
+		var latest model.Process
+		if err := h.DB.WithContext(ctx).Order("timestamp desc").Limit(1).Find(&latest).Error; err != nil {
+			return processes, err
+		}
+		if latest.ID == 0 {
+			return processes, nil
+		}
+		tx = tx.Where("timestamp = ?", latest.Timestamp)
+	} else {
+		end := time.Now()
+		if args.EndTime > 0 {
+			end = time.Unix(args.EndTime, 0)
+		}
+		tx = tx.Where("timestamp >= ? and timestamp <= ?", time.Unix(args.StartTime, 0), end)
+	}
+	if args.PID > 0 {
+		tx = tx.Where("pid = ?", args.PID)
+	}
+	if err := tx.Order("timestamp asc").Find(&processes).Error; err != nil {
+		return processes, err
+	}
+	return processes, nil
+}
+
+// Sensors 最近一次采集的传感器读数
+func (h HostRepo) Sensors(ctx context.Context) ([]model.Sensor, error) {
+	var sensors []model.Sensor
+	var latest model.Sensor
+	if err := h.DB.WithContext(ctx).Order("timestamp desc").Limit(1).Find(&latest).Error; err != nil {
+		return sensors, err
+	}
+	if latest.ID == 0 {
+		return sensors, nil
+	}
+	err := h.DB.WithContext(ctx).Where("timestamp = ?", latest.Timestamp).Order("type, name").Find(&sensors).Error
+	return sensors, err
+}
+
+func (h HostRepo) SensorUsage(ctx context.Context, args schema.SensorUsageArgs) ([]model.Sensor, error) {
+	var sensors []model.Sensor
+	end := time.Now()
+	if args.EndTime > 0 {
+		end = time.Unix(args.EndTime, 0)
+	}
+	tx := h.DB.WithContext(ctx).
+		Where("type = ? AND timestamp >= ? AND timestamp <= ?", args.Type, time.Unix(args.StartTime, 0), end)
+	if args.Name != "" {
+		tx = tx.Where("name = ?", args.Name)
 	}
-	return netInfos, nil
+	err := tx.Order("timestamp asc").Find(&sensors).Error
+	return sensors, err
 }
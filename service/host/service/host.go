@@ -7,9 +7,12 @@ package service
 import (
 	"context"
 	"sort"
+	"time"
 
+	"github.com/amuluze/amprobe/pkg/metricstore"
 	"github.com/amuluze/amprobe/pkg/psutil"
 	"github.com/amuluze/amprobe/service/host/repository"
+	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amprobe/service/schema"
 	"github.com/google/wire"
 )
@@ -25,6 +28,13 @@ type IHostService interface {
 	DiskUsage(ctx context.Context, args schema.DiskUsageArgs) (schema.DiskUsageReply, error)
 	DiskUsages(ctx context.Context, args schema.DiskUsageArgs) ([]schema.DiskUsageReply, error)
 	NetUsage(ctx context.Context, args schema.NetworkUsageArgs) ([]schema.NetworkUsageReply, error)
+	NetErrors(ctx context.Context, args schema.NetErrorsArgs) ([]schema.NetErrorsReply, error)
+	Connections(ctx context.Context) (schema.ConnectionsReply, error)
+	SwapUsage(ctx context.Context, args schema.SwapUsageArgs) (schema.SwapUsageReply, error)
+	PressureUsage(ctx context.Context, args schema.PressureUsageArgs) ([]schema.PressureUsageReply, error)
+	Processes(ctx context.Context, args schema.ProcessQueryArgs) (schema.ProcessReply, error)
+	Sensors(ctx context.Context) (schema.SensorsReply, error)
+	SensorUsage(ctx context.Context, args schema.SensorUsageArgs) ([]schema.SensorUsageReply, error)
 }
 
 type HostService struct {
@@ -61,18 +71,11 @@ func (h HostService) CPUInfo(ctx context.Context) (schema.CPUInfoReply, error) {
 }
 
 func (h HostService) CPUUsage(ctx context.Context, args schema.CPUUsageArgs) (schema.CPUUsageReply, error) {
-	mHosts, err := h.HostRepo.CPUUsage(ctx, args)
+	series, err := h.HostRepo.CPUUsage(ctx, args)
 	if err != nil {
 		return schema.CPUUsageReply{}, err
 	}
-	var list []schema.Usage
-	for _, item := range mHosts {
-		list = append(list, schema.Usage{
-			Timestamp: item.Timestamp.Unix(),
-			Value:     item.CPUPercent,
-		})
-	}
-	return schema.CPUUsageReply{Data: list}, nil
+	return schema.CPUUsageReply{Data: toUsages(series)}, nil
 }
 
 func (h HostService) MemInfo(ctx context.Context) (schema.MemoryInfoReply, error) {
@@ -80,62 +83,194 @@ func (h HostService) MemInfo(ctx context.Context) (schema.MemoryInfoReply, error
 	if err != nil {
 		return schema.MemoryInfoReply{}, err
 	}
-	return schema.MemoryInfoReply{Percent: memInfo.MemPercent, Total: memInfo.MemTotal, Used: memInfo.MemUsed}, nil
+	return schema.MemoryInfoReply{
+		Percent:   memInfo.MemPercent,
+		Total:     memInfo.MemTotal,
+		Used:      memInfo.MemUsed,
+		Available: memInfo.MemAvailable,
+		Buffers:   memInfo.MemBuffers,
+		Cached:    memInfo.MemCached,
+		SwapTotal: memInfo.SwapTotal,
+		SwapUsed:  memInfo.SwapUsed,
+	}, nil
+}
+
+func (h HostService) SwapUsage(ctx context.Context, args schema.SwapUsageArgs) (schema.SwapUsageReply, error) {
+	in, out, err := h.HostRepo.SwapUsage(ctx, args)
+	if err != nil {
+		return schema.SwapUsageReply{}, err
+	}
+	list := make([]schema.SwapIO, 0)
+	for _, points := range pairSeries(in, out, "") {
+		for _, p := range points {
+			list = append(list, schema.SwapIO{Timestamp: p.timestamp, SwapIn: p.first, SwapOut: p.second})
+		}
+	}
+	return schema.SwapUsageReply{Data: list}, nil
+}
+
+func (h HostService) PressureUsage(ctx context.Context, args schema.PressureUsageArgs) ([]schema.PressureUsageReply, error) {
+	some, full, err := h.HostRepo.PressureUsage(ctx, args)
+	if err != nil {
+		return []schema.PressureUsageReply{}, err
+	}
+	list := make([]schema.PressureUsageReply, 0)
+	for resource, points := range pairSeries(some, full, model.LabelResource) {
+		usage := schema.PressureUsageReply{Resource: resource, Data: make([]schema.PressureIO, 0, len(points))}
+		for _, p := range points {
+			usage.Data = append(usage.Data, schema.PressureIO{Timestamp: p.timestamp, Some: p.first, Full: p.second})
+		}
+		list = append(list, usage)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Resource < list[j].Resource
+	})
+	return list, nil
 }
 
 func (h HostService) MemUsage(ctx context.Context, args schema.MemoryUsageArgs) (schema.MemoryUsageReply, error) {
-	memInfos, err := h.HostRepo.MemUsage(ctx, args)
+	series, err := h.HostRepo.MemUsage(ctx, args)
 	if err != nil {
 		return schema.MemoryUsageReply{}, err
 	}
+	return schema.MemoryUsageReply{Data: toUsages(series)}, nil
+}
+
+// toUsages 将无标签指标序列转换为趋势数据
+func toUsages(series []metricstore.Series) []schema.Usage {
 	var list []schema.Usage
-	for _, item := range memInfos {
-		list = append(list, schema.Usage{
-			Timestamp: item.Timestamp.Unix(),
-			Value:     item.MemPercent,
+	for _, s := range series {
+		for _, p := range s.Points {
+			list = append(list, schema.Usage{
+				Timestamp: p.Timestamp.Unix(),
+				Value:     p.Value,
+			})
+		}
+	}
+	return list
+}
+
+// pairPoint 同一时刻的两个指标值
+type pairPoint struct {
+	timestamp int64
+	first     float64
+	second    float64
+}
+
+// pairSeries 按标签值和时间戳合并两个指标的序列，如磁盘读/写、网卡收/发
+func pairSeries(first, second []metricstore.Series, label string) map[string][]pairPoint {
+	res := make(map[string][]pairPoint)
+	index := make(map[string]map[int64]int)
+	add := func(series []metricstore.Series, isFirst bool) {
+		for _, s := range series {
+			name := s.Labels[label]
+			if _, ok := index[name]; !ok {
+				index[name] = make(map[int64]int)
+				res[name] = []pairPoint{}
+			}
+			for _, p := range s.Points {
+				ts := p.Timestamp.Unix()
+				i, ok := index[name][ts]
+				if !ok {
+					res[name] = append(res[name], pairPoint{timestamp: ts})
+					i = len(res[name]) - 1
+					index[name][ts] = i
+				}
+				if isFirst {
+					res[name][i].first = p.Value
+				} else {
+					res[name][i].second = p.Value
+				}
+			}
+		}
+	}
+	add(first, true)
+	add(second, false)
+	for name := range res {
+		points := res[name]
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].timestamp < points[j].timestamp
 		})
 	}
-	return schema.MemoryUsageReply{Data: list}, nil
+	return res
 }
 
-func (h HostService) DiskUsages(ctx context.Context, args schema.DiskUsageArgs) ([]schema.DiskUsageReply, error) {
+// groupPoint 同一时刻多个指标的值，key 为指标名
+type groupPoint struct {
+	timestamp int64
+	values    map[string]float64
+}
+
+// groupSeries 按标签值和时间戳合并多个指标的序列，series 的 key 为指标名
+func groupSeries(series map[string][]metricstore.Series, label string) map[string][]groupPoint {
+	res := make(map[string][]groupPoint)
+	index := make(map[string]map[int64]int)
+	for metric, list := range series {
+		for _, s := range list {
+			name := s.Labels[label]
+			if _, ok := index[name]; !ok {
+				index[name] = make(map[int64]int)
+				res[name] = []groupPoint{}
+			}
+			for _, p := range s.Points {
+				ts := p.Timestamp.Unix()
+				i, ok := index[name][ts]
+				if !ok {
+					res[name] = append(res[name], groupPoint{timestamp: ts, values: make(map[string]float64)})
+					i = len(res[name]) - 1
+					index[name][ts] = i
+				}
+				res[name][i].values[metric] = p.Value
+			}
+		}
+	}
+	for name := range res {
+		points := res[name]
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].timestamp < points[j].timestamp
+		})
+	}
+	return res
+}
 
-	diskUsages, err := h.HostRepo.DiskUsage(ctx, args)
+func toDiskIOs(points []groupPoint) []schema.DiskIO {
+	diskIOs := make([]schema.DiskIO, 0, len(points))
+	for _, p := range points {
+		diskIOs = append(diskIOs, schema.DiskIO{
+			Timestamp:  p.timestamp,
+			IORead:     p.values[model.MetricDiskRead],
+			IOWrite:    p.values[model.MetricDiskWrite],
+			ReadIOPS:   p.values[model.MetricDiskReadIOPS],
+			WriteIOPS:  p.values[model.MetricDiskWriteIOPS],
+			Await:      p.values[model.MetricDiskAwait],
+			QueueDepth: p.values[model.MetricDiskQueueDepth],
+			Util:       p.values[model.MetricDiskUtil],
+		})
+	}
+	return diskIOs
+}
+
+func (h HostService) DiskUsages(ctx context.Context, args schema.DiskUsageArgs) ([]schema.DiskUsageReply, error) {
+	series, err := h.HostRepo.DiskUsage(ctx, args)
 	if err != nil {
 		return []schema.DiskUsageReply{}, err
 	}
 
-	diskMap := make(map[string][]schema.DiskIO)
+	diskMap := groupSeries(series, model.LabelDevice)
 	devices := make(map[string]struct{})
-	// add serias data
-	for _, item := range diskUsages {
-		device := item.Device
-		// 获取当前设备的切片
-		diskIOs, ok := diskMap[device]
-		if !ok {
-			// 如果设备还没有在 map 中，创建一个新的切片
-			diskIOs = []schema.DiskIO{}
-			devices[device] = struct{}{}
-		}
-		// 将新的 DiskIO 添加到切片中
-		diskIOs = append(diskIOs, schema.DiskIO{
-			Timestamp: item.CreatedAt.Unix(),
-			IORead:    item.DiskRead,
-			IOWrite:   item.DiskWrite,
-		})
-		// 将更新后的切片放回 map 中
-		diskMap[device] = diskIOs
+	for device := range diskMap {
+		devices[device] = struct{}{}
 	}
-	diskInfos,_ := psutil.GetDiskInfo(devices)
+	diskInfos, _ := psutil.GetDiskInfo(devices)
 	var list []schema.DiskUsageReply
-	for device, diskIOs := range diskMap {
+	for device, points := range diskMap {
 		list = append(list, schema.DiskUsageReply{
-			Device: device,
-			Data:   diskIOs,
+			Device:     device,
+			Data:       toDiskIOs(points),
 			Mountpoint: diskInfos[device].Mountpoint,
-			Total: diskInfos[device].Total,
-			Percent: diskInfos[device].Percent,
-			Used: diskInfos[device].Used,
+			Total:      diskInfos[device].Total,
+			Percent:    diskInfos[device].Percent,
+			Used:       diskInfos[device].Used,
 		})
 	}
 	sort.Slice(list, func(i, j int) bool {
@@ -145,49 +280,184 @@ func (h HostService) DiskUsages(ctx context.Context, args schema.DiskUsageArgs)
 }
 
 func (h HostService) DiskUsage(ctx context.Context, args schema.DiskUsageArgs) (schema.DiskUsageReply, error) {
-	diskInfos, err := h.HostRepo.DiskUsage(ctx, args)
+	series, err := h.HostRepo.DiskUsage(ctx, args)
 	if err != nil {
 		return schema.DiskUsageReply{}, err
 	}
 
 	mDisk := make([]schema.DiskIO, 0)
 	device := ""
-	for _, item := range diskInfos {
-		device = item.Device
-		mDisk = append(mDisk, schema.DiskIO{
-			Timestamp: item.CreatedAt.Unix(),
-			IORead:    item.DiskRead,
-			IOWrite:   item.DiskWrite,
-		})
+	for name, points := range groupSeries(series, model.LabelDevice) {
+		device = name
+		mDisk = append(mDisk, toDiskIOs(points)...)
 	}
 	return schema.DiskUsageReply{Device: device, Data: mDisk}, nil
 }
 
 func (h HostService) NetUsage(ctx context.Context, args schema.NetworkUsageArgs) ([]schema.NetworkUsageReply, error) {
-	netInfos, err := h.HostRepo.NetUsage(ctx, args)
+	recvs, sends, err := h.HostRepo.NetUsage(ctx, args)
 	if err != nil {
 		return []schema.NetworkUsageReply{}, err
 	}
-	netMap := make(map[string]schema.NetworkUsageReply)
-	for _, item := range netInfos {
-		usage, ok := netMap[item.Ethernet]
-		if !ok {
-			usage = schema.NetworkUsageReply{Ethernet: item.Ethernet}
-			usage.Data = make([]schema.NetIO, 0)
+	list := make([]schema.NetworkUsageReply, 0)
+	for eth, points := range pairSeries(recvs, sends, model.LabelEthernet) {
+		usage := schema.NetworkUsageReply{Ethernet: eth}
+		usage.Data = make([]schema.NetIO, 0, len(points))
+		for _, p := range points {
+			usage.Data = append(usage.Data, schema.NetIO{
+				Timestamp: p.timestamp,
+				BytesSent: p.second,
+				BytesRecv: p.first,
+			})
 		}
-		usage.Data = append(usage.Data, schema.NetIO{
-			Timestamp: item.CreatedAt.Unix(),
-			BytesSent: item.NetSend,
-			BytesRecv: item.NetRecv,
-		})
-		netMap[item.Ethernet] = usage
+		list = append(list, usage)
 	}
-	list := make([]schema.NetworkUsageReply, 0)
-	for _, item := range netMap {
-		list = append(list, item)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Ethernet < list[j].Ethernet
+	})
+	return list, nil
+}
+
+func (h HostService) NetErrors(ctx context.Context, args schema.NetErrorsArgs) ([]schema.NetErrorsReply, error) {
+	series, err := h.HostRepo.NetErrors(ctx, args)
+	if err != nil {
+		return []schema.NetErrorsReply{}, err
+	}
+	list := make([]schema.NetErrorsReply, 0)
+	for eth, points := range groupSeries(series, model.LabelEthernet) {
+		reply := schema.NetErrorsReply{Ethernet: eth, Data: make([]schema.NetErrorIO, 0, len(points))}
+		for _, p := range points {
+			reply.Data = append(reply.Data, schema.NetErrorIO{
+				Timestamp:   p.timestamp,
+				PacketsRecv: p.values[model.MetricNetPacketsRecv],
+				PacketsSent: p.values[model.MetricNetPacketsSent],
+				ErrIn:       p.values[model.MetricNetErrIn],
+				ErrOut:      p.values[model.MetricNetErrOut],
+				DropIn:      p.values[model.MetricNetDropIn],
+				DropOut:     p.values[model.MetricNetDropOut],
+			})
+		}
+		list = append(list, reply)
 	}
 	sort.Slice(list, func(i, j int) bool {
 		return list[i].Ethernet < list[j].Ethernet
 	})
 	return list, nil
 }
+
+// Connections 实时读取连接状态及监听端口
+func (h HostService) Connections(ctx context.Context) (schema.ConnectionsReply, error) {
+	stat, err := psutil.GetConnections()
+	if err != nil {
+		return schema.ConnectionsReply{}, err
+	}
+	reply := schema.ConnectionsReply{
+		Timestamp: time.Now().Unix(),
+		States:    make([]schema.ConnectionState, 0, len(stat.States)),
+		Listens:   make([]schema.ListenPort, 0, len(stat.Listens)),
+	}
+	for state, count := range stat.States {
+		reply.States = append(reply.States, schema.ConnectionState{State: state, Count: count})
+	}
+	sort.Slice(reply.States, func(i, j int) bool {
+		return reply.States[i].State < reply.States[j].State
+	})
+	for _, l := range stat.Listens {
+		reply.Listens = append(reply.Listens, schema.ListenPort{
+			Protocol: l.Protocol,
+			Address:  l.Address,
+			Port:     l.Port,
+			PID:      l.PID,
+			Process:  l.Process,
+		})
+	}
+	return reply, nil
+}
+
+func (h HostService) Processes(ctx context.Context, args schema.ProcessQueryArgs) (schema.ProcessReply, error) {
+	processes, err := h.HostRepo.Processes(ctx, args)
+	if err != nil {
+		return schema.ProcessReply{}, err
+	}
+	// 记录按时间升序，按采集时间分组
+	list := make([]schema.ProcessSnapshot, 0)
+	for _, item := range processes {
+		ts := item.Timestamp.Unix()
+		if len(list) == 0 || list[len(list)-1].Timestamp != ts {
+			list = append(list, schema.ProcessSnapshot{Timestamp: ts, Processes: []schema.Process{}})
+		}
+		snapshot := &list[len(list)-1]
+		snapshot.Processes = append(snapshot.Processes, schema.Process{
+			PID:         item.PID,
+			Name:        item.Name,
+			Cmdline:     item.Cmdline,
+			User:        item.User,
+			CPUPercent:  item.CPUPercent,
+			MemPercent:  item.MemPercent,
+			RSS:         item.RSS,
+			Threads:     item.Threads,
+			FDs:         item.FDs,
+			ContainerID: item.ContainerID,
+		})
+	}
+	for i := range list {
+		ps := list[i].Processes
+		sort.SliceStable(ps, func(a, b int) bool {
+			if args.SortBy == "memory" {
+				return ps[a].RSS > ps[b].RSS
+			}
+			return ps[a].CPUPercent > ps[b].CPUPercent
+		})
+		if args.Limit > 0 && len(ps) > args.Limit {
+			list[i].Processes = ps[:args.Limit]
+		}
+	}
+	return schema.ProcessReply{Data: list}, nil
+}
+
+func (h HostService) Sensors(ctx context.Context) (schema.SensorsReply, error) {
+	sensors, err := h.HostRepo.Sensors(ctx)
+	reply := schema.SensorsReply{
+		Temperatures: []schema.Temperature{},
+		Fans:         []schema.Fan{},
+		Batteries:    []schema.Battery{},
+	}
+	if err != nil {
+		return reply, err
+	}
+	for _, item := range sensors {
+		reply.Timestamp = item.Timestamp.Unix()
+		switch item.Type {
+		case model.SensorTemperature:
+			reply.Temperatures = append(reply.Temperatures, schema.Temperature{Name: item.Name, Value: item.Value, High: item.High, Critical: item.Critical})
+		case model.SensorFan:
+			reply.Fans = append(reply.Fans, schema.Fan{Name: item.Name, RPM: item.Value})
+		case model.SensorBattery:
+			reply.Batteries = append(reply.Batteries, schema.Battery{Name: item.Name, Capacity: item.Value, Status: item.Status})
+		}
+	}
+	return reply, nil
+}
+
+func (h HostService) SensorUsage(ctx context.Context, args schema.SensorUsageArgs) ([]schema.SensorUsageReply, error) {
+	sensors, err := h.HostRepo.SensorUsage(ctx, args)
+	if err != nil {
+		return []schema.SensorUsageReply{}, err
+	}
+	// 记录按时间升序，按传感器名称分组
+	index := make(map[string]int)
+	list := make([]schema.SensorUsageReply, 0)
+	for _, item := range sensors {
+		i, ok := index[item.Name]
+		if !ok {
+			list = append(list, schema.SensorUsageReply{Name: item.Name, Data: []schema.Usage{}})
+			i = len(list) - 1
+			index[item.Name] = i
+		}
+		list[i].Data = append(list[i].Data, schema.Usage{Timestamp: item.Timestamp.Unix(), Value: item.Value})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
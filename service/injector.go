@@ -5,6 +5,7 @@
 package service
 
 import (
+	"github.com/amuluze/amprobe/pkg/auditlog"
 	"github.com/amuluze/amutool/logger"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/wire"
@@ -13,21 +14,23 @@ import (
 var InjectorSet = wire.NewSet(NewInjector)
 
 type Injector struct {
-	App     *fiber.App
-	Router  *Router
-	Config  *Config
-	Prepare *Prepare
-	Logger  *logger.Logger
-	Task    *TimedTask
+	App      *fiber.App
+	Router   *Router
+	Config   *Config
+	Prepare  *Prepare
+	Logger   *logger.Logger
+	Task     *TimedTask
+	Archiver *auditlog.Archiver
 }
 
-func NewInjector(app *fiber.App, router *Router, prepare *Prepare, config *Config, task *TimedTask, logx *logger.Logger) (*Injector, error) {
+func NewInjector(app *fiber.App, router *Router, prepare *Prepare, config *Config, task *TimedTask, archiver *auditlog.Archiver, logx *logger.Logger) (*Injector, error) {
 	return &Injector{
-		App:     app,
-		Router:  router,
-		Config:  config,
-		Prepare: prepare,
-		Task:    task,
-		Logger:  logx,
+		App:      app,
+		Router:   router,
+		Config:   config,
+		Prepare:  prepare,
+		Task:     task,
+		Archiver: archiver,
+		Logger:   logx,
 	}, nil
 }
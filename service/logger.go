@@ -7,6 +7,7 @@ package service
 import (
 	"fmt"
 
+	"github.com/amuluze/amprobe/pkg/configx"
 	"github.com/amuluze/amutool/logger"
 
 	"github.com/fsnotify/fsnotify"
@@ -34,10 +35,14 @@ func NewLogger(config *Config) *logger.Logger {
 		logx.Info("use default logger level")
 	}
 
+	// 只使用环境变量和命令行参数时没有配置文件可监听
+	if viper.ConfigFileUsed() == "" {
+		return logx
+	}
 	viper.WatchConfig()
 	viper.OnConfigChange(func(in fsnotify.Event) {
 		fmt.Println("Config file changed:", in.Name)
-		if err := viper.Unmarshal(config); err != nil {
+		if err := viper.Unmarshal(config, configx.DecodeHook()); err != nil {
 			fmt.Printf("unmarshal config error when change, %v", err)
 		}
 		switch config.Logger.Level {
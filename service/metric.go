@@ -0,0 +1,37 @@
+// Package service
+// Date: 2024/4/25 17:10
+// Author: Amu
+// Description:
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/metricstore"
+	"github.com/amuluze/amprobe/pkg/metricstore/gormstore"
+	"github.com/amuluze/amprobe/pkg/metricstore/tsdb"
+	"github.com/amuluze/amutool/database"
+)
+
+func InitMetricStore(config *Config, db *database.DB) (metricstore.MetricStore, func(), error) {
+	var store metricstore.MetricStore
+	switch config.Metric.Store {
+	case "", "db":
+		store = gormstore.New(db)
+	case "tsdb":
+		s, err := tsdb.Open(tsdb.Options{
+			Dir:       config.Metric.Dir,
+			Retention: time.Duration(config.Metric.RetentionDays) * 24 * time.Hour,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		store = s
+	default:
+		return nil, nil, fmt.Errorf("unknown metric store: %s", config.Metric.Store)
+	}
+	var err error
+	cleanFunc := func() { err = store.Close() }
+	return store, cleanFunc, err
+}
@@ -0,0 +1,101 @@
+// Package middleware
+// Date: 2024/4/21 10:05
+// Author: Amu
+// Description:
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	redactedValue  = "******"
+	maxParamsBytes = 4096
+)
+
+// resourceKeys 按顺序从请求参数中提取操作对象
+var resourceKeys = []string{"container_id", "image_id", "id", "name", "username"}
+
+// sensitiveKey 判断参数名是否为需要脱敏的字段
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if key == "code" {
+		return true
+	}
+	for _, word := range []string{"password", "secret", "token", "key"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if sensitiveKey(k) {
+				val[k] = redactedValue
+			} else {
+				val[k] = redactValue(item)
+			}
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// parseParams 解析 JSON 请求体，返回脱敏后的参数和操作对象
+// 非 JSON 请求体不记录，避免把未知格式的敏感数据写入审计日志
+func parseParams(body []byte) (string, string) {
+	if len(body) == 0 {
+		return "", ""
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return "", ""
+	}
+	var resourceID string
+	for _, key := range resourceKeys {
+		if v, ok := params[key]; ok && v != nil && !sensitiveKey(key) {
+			resourceID = fmt.Sprint(v)
+			break
+		}
+	}
+	data, err := json.Marshal(redactValue(params))
+	if err != nil {
+		return "", resourceID
+	}
+	if len(data) > maxParamsBytes {
+		data = data[:maxParamsBytes]
+	}
+	return string(data), resourceID
+}
+
+func newAuditEntry(c *fiber.Ctx, username string, operate string, start time.Time) *auth.AuditEntry {
+	params, resourceID := parseParams(c.Body())
+	return &auth.AuditEntry{
+		Username:   username,
+		Operate:    operate,
+		ResourceID: resourceID,
+		Params:     params,
+		ClientIP:   c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		Method:     c.Method(),
+		Path:       c.Path(),
+		Status:     c.Response().StatusCode(),
+		Duration:   time.Since(start),
+	}
+}
@@ -12,31 +12,60 @@ import (
 	"github.com/amuluze/amutool/errors"
 	"github.com/gofiber/fiber/v2"
 	"log/slog"
+	"strings"
+	"time"
 )
 
+func wrapClientContext(c *fiber.Ctx) {
+	ctx := contextx.NewClientIP(c.UserContext(), c.IP())
+	ctx = contextx.NewUserAgent(ctx, c.Get(fiber.HeaderUserAgent))
+	c.SetUserContext(ctx)
+}
+
 func wrapUserAuthContext(c *fiber.Ctx, userID string, username string) {
 	ctx := contextx.NewUserID(c.UserContext(), userID)
-	ctx = contextx.NewUsername(c.UserContext(), username)
+	ctx = contextx.NewUsername(ctx, username)
 	c.SetUserContext(ctx)
 }
 
 func UserAuthMiddleware(a auth.Auther, skippers ...SkipperFunc) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		wrapClientContext(c)
 		if SkipHandler(c, skippers...) {
+			if _, ok := LoginPath[c.Path()]; !ok {
+				return c.Next()
+			}
 			var args schema.LoginArgs
 			_ = c.BodyParser(&args)
-			if c.Path() == "/api/v1/auth/login" {
-				a.RecordAudit(args.Username, "登录")
+			err := c.Next()
+			if err != nil {
+				err = fiberx.Failure(c, err)
+			}
+			username := args.Username
+			if u := fiberx.LoginUser(c); u != "" {
+				username = u
 			}
-			return c.Next()
+			a.RecordAudit(newAuditEntry(c, username, OperateEvent[c.Path()], start))
+			return err
 		}
 
-		slog.Info("auth middleware", "token", fiberx.GetToken(c))
+		token := fiberx.GetToken(c)
 		var userID string
 		var username string
 		var isAdmin string
+		var readOnly bool
 		var err error
-		userID, username, isAdmin, err = a.ParseToken(fiberx.GetToken(c), "access_token")
+		apiToken := strings.HasPrefix(token, auth.APITokenPrefix)
+		if apiToken {
+			var info *auth.APITokenInfo
+			if info, err = a.ParseAPIToken(token); err == nil {
+				userID, username, isAdmin, readOnly = info.UserID, info.Username, info.IsAdmin, info.ReadOnly
+			}
+		} else {
+			slog.Info("auth middleware", "token", token)
+			userID, username, isAdmin, err = a.ParseToken(token, "access_token")
+		}
 
 		if errors.Is(err, auth.ErrInvalidToken) {
 			slog.Error("invalid token", "err", err)
@@ -48,16 +77,41 @@ func UserAuthMiddleware(a auth.Auther, skippers ...SkipperFunc) fiber.Handler {
 
 		slog.Info("user id", "user_id", userID)
 		wrapUserAuthContext(c, userID, username)
-		if c.Method() == "POST" && isAdmin != "1" && c.Path() != "/api/v1/auth/logout" {
+		// 只读访问令牌仅允许查询
+		if readOnly && c.Method() != "GET" {
 			return fiberx.Forbidden(c)
 		}
-		if err := c.Next(); err == nil {
-			if (c.Method() == "POST" && isAdmin == "1") || c.Path() == "/api/v1/auth/logout" {
-				a.RecordAudit(username, OperateEvent[c.Path()])
-			}
-			return nil
-		} else {
-			return fiberx.Failure(c, err)
+		if _, ok := SessionOnlyPath[c.Path()]; ok && apiToken {
+			return fiberx.Forbidden(c)
+		}
+		_, userOperate := UserOperatePath[c.Path()]
+		write := isWriteMethod(c.Method())
+		if (write || isAdminPath(c.Path())) && isAdmin != "1" && !userOperate {
+			return fiberx.Forbidden(c)
+		}
+		err = c.Next()
+		if err != nil {
+			err = fiberx.Failure(c, err)
+		}
+		// 失败的操作同样记录，便于追溯
+		if (write && isAdmin == "1") || userOperate {
+			a.RecordAudit(newAuditEntry(c, username, OperateEvent[c.Path()], start))
+		}
+		return err
+	}
+}
+
+// isWriteMethod POST / PUT / DELETE 等修改类请求需要管理员权限并记录审计日志
+func isWriteMethod(method string) bool {
+	return method != fiber.MethodGet && method != fiber.MethodHead
+}
+
+// isAdminPath 路径属于仅管理员可访问的接口
+func isAdminPath(path string) bool {
+	for _, prefix := range AdminPathPrefix {
+		if strings.HasPrefix(path, prefix) {
+			return true
 		}
 	}
+	return false
 }
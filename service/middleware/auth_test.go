@@ -0,0 +1,58 @@
+// Package middleware
+// Date: 2024/4/19 16:40
+// Author: Amu
+// Description:
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amuluze/amprobe/pkg/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+type fakeAuther struct {
+	auth.Auther
+}
+
+func (fakeAuther) ParseToken(token string, tokenType string) (string, string, string, error) {
+	return "u1", "admin", "1", nil
+}
+
+func (fakeAuther) ParseAPIToken(token string) (*auth.APITokenInfo, error) {
+	return &auth.APITokenInfo{UserID: "u1", Username: "admin", IsAdmin: "1"}, nil
+}
+
+func (fakeAuther) RecordAudit(entry *auth.AuditEntry) {}
+
+func TestAPITokenSessionOnlyPath(t *testing.T) {
+	app := fiber.New()
+	app.Use(UserAuthMiddleware(fakeAuther{}))
+	app.Post("/api/v1/*", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	cases := []struct {
+		token  string
+		path   string
+		status int
+	}{
+		{"amp_0123456789", "/api/v1/auth/api_token_create", fiber.StatusForbidden},
+		{"amp_0123456789", "/api/v1/auth/api_token_revoke", fiber.StatusForbidden},
+		{"amp_0123456789", "/api/v1/auth/totp_enroll", fiber.StatusForbidden},
+		{"amp_0123456789", "/api/v1/auth/totp_activate", fiber.StatusForbidden},
+		{"amp_0123456789", "/api/v1/auth/totp_disable", fiber.StatusForbidden},
+		{"amp_0123456789", "/api/v1/container/container_start", fiber.StatusOK},
+		{"jwt", "/api/v1/auth/api_token_create", fiber.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(fiber.MethodPost, tc.path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tc.token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s %s: expected %d, got %d", tc.token, tc.path, tc.status, resp.StatusCode)
+		}
+	}
+}
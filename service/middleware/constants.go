@@ -6,13 +6,53 @@ package middleware
 
 var OperateEvent = map[string]string{
 	"/api/v1/auth/login":                  "登录",
+	"/api/v1/auth/login_totp":             "TOTP 二次验证登录",
 	"/api/v1/auth/logout":                 "登出",
+	"/api/v1/auth/logout_all":             "注销全部会话",
 	"/api/v1/auth/pass_update":            "更新密码",
 	"/api/v1/auth/token_update":           "刷新token",
+	"/api/v1/auth/totp_enroll":            "绑定TOTP",
+	"/api/v1/auth/totp_activate":          "启用TOTP",
+	"/api/v1/auth/totp_disable":           "停用TOTP",
+	"/api/v1/auth/api_token_create":       "创建访问令牌",
+	"/api/v1/auth/api_token_revoke":       "吊销访问令牌",
 	"/api/v1/container/container_start":   "启动容器",
 	"/api/v1/container/container_stop":    "停止容器",
 	"/api/v1/container/container_remove":  "删除容器",
 	"/api/v1/container/container_restart": "重启容器",
 	"/api/v1/container/image_remove":      "删除镜像",
 	"/api/v1/container/images_prune":      "删除虚悬镜像",
+	"/api/v1/settings":                    "更新设置",
+}
+
+// LoginPath 无需令牌的登录接口，成功和失败都记录审计日志
+var LoginPath = map[string]struct{}{
+	"/api/v1/auth/login":      {},
+	"/api/v1/auth/login_totp": {},
+}
+
+// UserOperatePath 非管理员用户也允许调用的 POST 接口
+var UserOperatePath = map[string]struct{}{
+	"/api/v1/auth/logout":        {},
+	"/api/v1/auth/logout_all":    {},
+	"/api/v1/auth/totp_enroll":   {},
+	"/api/v1/auth/totp_activate": {},
+	"/api/v1/auth/totp_disable":  {},
+
+	"/api/v1/auth/api_token_create": {},
+	"/api/v1/auth/api_token_revoke": {},
+}
+
+// SessionOnlyPath 只允许登录会话调用的接口，访问令牌不能签发新令牌或修改二次验证
+var SessionOnlyPath = map[string]struct{}{
+	"/api/v1/auth/totp_enroll":      {},
+	"/api/v1/auth/totp_activate":    {},
+	"/api/v1/auth/totp_disable":     {},
+	"/api/v1/auth/api_token_create": {},
+	"/api/v1/auth/api_token_revoke": {},
+}
+
+// AdminPathPrefix 查询类接口中仅管理员可访问的路径前缀，审计日志包含其他用户的 IP 和操作记录
+var AdminPathPrefix = []string{
+	"/api/v1/audit/",
 }
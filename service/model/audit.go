@@ -5,17 +5,50 @@
 package model
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Audits []Audit
 
 type Audit struct {
-	gorm.Model
-	Username string `gorm:"type:varchar(255);not null"`
-	Operate  string `gorm:"type:varchar(255);not null"`
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	Username   string         `gorm:"type:varchar(255);not null;index"`
+	Operate    string         `gorm:"type:varchar(255);not null;index"`
+	ResourceID string         `gorm:"type:varchar(255);index;comment:操作对象"`
+	Params     string         `gorm:"type:text;comment:请求参数(已脱敏)"`
+	ClientIP   string         `gorm:"type:varchar(64);comment:客户端 IP"`
+	UserAgent  string         `gorm:"type:varchar(512);comment:客户端 UA"`
+	Method     string         `gorm:"type:varchar(16)"`
+	Path       string         `gorm:"type:varchar(255)"`
+	Status     int            `gorm:"comment:响应状态码"`
+	Duration   int64          `gorm:"comment:耗时(毫秒)"`
+	PrevHash   string         `gorm:"type:varchar(64);comment:上一条记录的哈希"`
+	Hash       string         `gorm:"type:varchar(64);comment:本条记录的 HMAC"`
 }
 
 func (d *Audit) TableName() string {
 	return "s_audit"
 }
+
+// AuditHead 审计哈希链的链尾，只有一行，用于发现删除最新记录；
+// Seq 每次写入加一，多个实例同时写入时作为乐观锁；
+// AnchorID/AnchorHash 记录最后一条已归档的记录，剩余的第一条记录必须链接到它，用于发现删除最早的记录
+type AuditHead struct {
+	ID         uint   `gorm:"primarykey"`
+	Seq        uint64 `gorm:"comment:写入次数"`
+	LastID     uint   `gorm:"comment:最后一条记录 ID"`
+	LastHash   string `gorm:"type:varchar(64);comment:最后一条记录的哈希"`
+	AnchorID   uint   `gorm:"comment:最后一条已归档记录 ID"`
+	AnchorHash string `gorm:"type:varchar(64);comment:最后一条已归档记录的哈希"`
+	Hash       string `gorm:"type:varchar(64);comment:链尾的 HMAC"`
+	UpdatedAt  time.Time
+}
+
+func (h *AuditHead) TableName() string {
+	return "s_audit_head"
+}
@@ -1,9 +1,8 @@
-
 package model
 
 import "time"
 
 type SeriesModel struct {
-	ID        uint `gorm:"primarykey"`
-	CreatedAt time.Time
-}
\ No newline at end of file
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+}
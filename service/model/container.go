@@ -14,7 +14,7 @@ type Containers []Container
 
 type Container struct {
 	gorm.Model
-	Timestamp   time.Time
+	Timestamp   time.Time `gorm:"index"`
 	ContainerID string
 	Name        string
 	Image       string
@@ -39,7 +39,7 @@ func (d *Container) TableName() string {
 
 type Docker struct {
 	gorm.Model
-	Timestamp     time.Time
+	Timestamp     time.Time `gorm:"index"`
 	DockerVersion string
 	APIVersion    string
 	MinAPIVersion string
@@ -62,7 +62,7 @@ type Images []Image
 
 type Image struct {
 	gorm.Model
-	Timestamp time.Time
+	Timestamp time.Time `gorm:"index"`
 	ImageID   string
 	Name      string
 	Tag       string
@@ -0,0 +1,24 @@
+//go:build mysql
+
+// Package model_test
+// Date: 2024/4/24 14:10
+// Author: Amu
+// Description: go test -tags mysql ./service/model/
+// 需要设置 AMPROBE_TEST_MYSQL_DSN，例如 "amprobe:amprobe@tcp(127.0.0.1:3306)/amprobe_test?charset=utf8mb4&parseTime=True&loc=Local"
+package model_test
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func openDialector(t *testing.T) gorm.Dialector {
+	dsn := os.Getenv("AMPROBE_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("AMPROBE_TEST_MYSQL_DSN not set")
+	}
+	return mysql.New(mysql.Config{DSN: dsn, DisableDatetimePrecision: true})
+}
@@ -0,0 +1,24 @@
+//go:build postgres
+
+// Package model_test
+// Date: 2024/4/24 14:10
+// Author: Amu
+// Description: go test -tags postgres ./service/model/
+// 需要设置 AMPROBE_TEST_POSTGRES_DSN，例如 "host=127.0.0.1 user=amprobe password=amprobe dbname=amprobe_test sslmode=disable"
+package model_test
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func openDialector(t *testing.T) gorm.Dialector {
+	dsn := os.Getenv("AMPROBE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("AMPROBE_TEST_POSTGRES_DSN not set")
+	}
+	return postgres.New(postgres.Config{DSN: dsn, PreferSimpleProtocol: true})
+}
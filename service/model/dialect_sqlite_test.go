@@ -0,0 +1,19 @@
+//go:build !postgres && !mysql
+
+// Package model_test
+// Date: 2024/4/24 14:10
+// Author: Amu
+// Description: 默认使用 SQLite 运行集成测试
+package model_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openDialector(t *testing.T) gorm.Dialector {
+	return sqlite.Open(filepath.Join(t.TempDir(), "probe.db"))
+}
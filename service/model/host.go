@@ -12,7 +12,7 @@ import (
 
 type Host struct {
 	gorm.Model
-	Timestamp       time.Time
+	Timestamp       time.Time `gorm:"index"`
 	Uptime          string
 	Hostname        string
 	Os              string
@@ -34,7 +34,7 @@ func (d *Host) TableName() string {
 
 type CPU struct {
 	gorm.Model
-	Timestamp  time.Time
+	Timestamp  time.Time `gorm:"index"`
 	CPUPercent float64
 }
 
@@ -49,10 +49,17 @@ func (d *CPU) TableName() string {
 
 type Memory struct {
 	gorm.Model
-	Timestamp  time.Time
-	MemPercent float64
-	MemTotal   float64
-	MemUsed    float64
+	Timestamp    time.Time `gorm:"index"`
+	MemPercent   float64
+	MemTotal     float64
+	MemUsed      float64
+	MemAvailable float64
+	MemBuffers   float64
+	MemCached    float64
+	SwapTotal    float64
+	SwapUsed     float64
+	SwapIn       float64 // swap in bytes per second
+	SwapOut      float64 // swap out bytes per second
 }
 
 func (d *Memory) TableName() string {
@@ -66,9 +73,14 @@ func (d *Memory) TableName() string {
 
 type Disk struct {
 	SeriesModel
-	Device      string
-	DiskRead    float64 // disk read bytes
-	DiskWrite   float64 // disk write bytes
+	Device         string
+	DiskRead       float64 // disk read bytes
+	DiskWrite      float64 // disk write bytes
+	DiskReadIOPS   float64 // 每秒读次数
+	DiskWriteIOPS  float64 // 每秒写次数
+	DiskAwait      float64 // 平均 IO 耗时(ms)
+	DiskQueueDepth float64 // 平均队列深度
+	DiskUtil       float64 // 设备忙碌时间占比(%)
 }
 
 func (d *Disk) TableName() string {
@@ -82,9 +94,15 @@ func (d *Disk) TableName() string {
 
 type Net struct {
 	SeriesModel
-	Ethernet  string
-	NetRecv   float64
-	NetSend   float64
+	Ethernet       string
+	NetRecv        float64
+	NetSend        float64
+	NetPacketsRecv float64 // 每秒接收包数
+	NetPacketsSent float64 // 每秒发送包数
+	NetErrIn       float64 // 每秒接收错误数
+	NetErrOut      float64 // 每秒发送错误数
+	NetDropIn      float64 // 每秒接收丢包数
+	NetDropOut     float64 // 每秒发送丢包数
 }
 
 func (d *Net) TableName() string {
@@ -0,0 +1,210 @@
+// Package model_test
+// Date: 2024/4/24 14:20
+// Author: Amu
+// Description: 数据库集成测试，默认 SQLite，PostgreSQL/MySQL 通过 build tag 启用
+package model_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/auditlog"
+	"github.com/amuluze/amprobe/pkg/migrate"
+	auditRepository "github.com/amuluze/amprobe/service/audit/repository"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amutool/database"
+	"gorm.io/gorm"
+	gormSchema "gorm.io/gorm/schema"
+)
+
+// openTestDB 打开测试库并清空已有表，执行全部迁移
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := gorm.Open(openDialector(t), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	models := model.NewModels()
+	drop := func() {
+		tables := append(models.GetAllModels(), new(migrate.Record))
+		if err := db.Migrator().DropTable(tables...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drop()
+	t.Cleanup(func() {
+		drop()
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if _, err := migrate.Up(db, models.Migrations()); err != nil {
+		t.Fatal(err)
+	}
+	return &database.DB{DB: db}
+}
+
+func TestMigrations(t *testing.T) {
+	db := openTestDB(t)
+	models := model.NewModels()
+	for _, m := range models.GetAllModels() {
+		if !db.Migrator().HasTable(m) {
+			t.Fatalf("table for %T not created", m)
+		}
+		// 迁移使用冻结的结构，模型新增字段时需要追加迁移版本
+		sch, err := gormSchema.Parse(m, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range sch.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(m, field.DBName) {
+				t.Fatalf("column %s.%s not created by migrations", sch.Table, field.DBName)
+			}
+		}
+		columns, err := db.Migrator().ColumnTypes(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(columns) != len(sch.DBNames) {
+			t.Fatalf("table %s has %d columns, model has %d", sch.Table, len(columns), len(sch.DBNames))
+		}
+	}
+	versions, err := migrate.Up(db.DB, models.Migrations())
+	if err != nil || len(versions) != 0 {
+		t.Fatalf("expected migrations to be idempotent, got %v %v", versions, err)
+	}
+}
+
+func TestSeriesIndexes(t *testing.T) {
+	db := openTestDB(t)
+	cases := []struct {
+		model interface{}
+		field string
+	}{
+		{&model.Host{}, "Timestamp"},
+		{&model.CPU{}, "Timestamp"},
+		{&model.Memory{}, "Timestamp"},
+		{&model.Container{}, "Timestamp"},
+		{&model.Docker{}, "Timestamp"},
+		{&model.Image{}, "Timestamp"},
+		{&model.Disk{}, "CreatedAt"},
+		{&model.Net{}, "CreatedAt"},
+		{&model.Audit{}, "CreatedAt"},
+	}
+	for _, c := range cases {
+		if !db.Migrator().HasIndex(c.model, c.field) {
+			t.Fatalf("missing index on %T.%s", c.model, c.field)
+		}
+	}
+}
+
+func TestUserUUID(t *testing.T) {
+	db := openTestDB(t)
+	user := &model.User{ID: model.NewUUID(), Username: "admin", Password: "x", IsAdmin: "1", Status: 1}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got model.User
+	if err := db.Where("id = ?", user.ID).Take(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID || got.Source != "local" {
+		t.Fatalf("unexpected user: %+v", got)
+	}
+}
+
+func TestSettingSave(t *testing.T) {
+	db := openTestDB(t)
+	setting := &model.Setting{ID: model.SettingID, Interval: 60, Devices: []string{"/dev/sda"}, Ethernets: []string{"eth0", "eth1"}}
+	if err := db.Save(setting).Error; err != nil {
+		t.Fatal(err)
+	}
+	setting.Interval = 30
+	setting.Devices = nil
+	if err := db.Save(setting).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got model.Setting
+	if err := db.Take(&got, model.SettingID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Interval != 30 || len(got.Devices) != 0 || len(got.Ethernets) != 2 || got.Ethernets[1] != "eth1" {
+		t.Fatalf("unexpected setting: %+v", got)
+	}
+	var count int64
+	db.Model(&model.Setting{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 setting row, got %d", count)
+	}
+}
+
+func TestSnapshotReplace(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	db.Create(&[]model.Image{{Timestamp: now, Name: "a"}, {Timestamp: now, Name: "b"}})
+	err := db.RunInTransaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&model.Image{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.Image{Timestamp: now, Name: "c"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Unscoped().Model(&model.Image{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 image, got %d", count)
+	}
+}
+
+func TestSeriesRangeQuery(t *testing.T) {
+	db := openTestDB(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 10; i++ {
+		db.Create(&model.CPU{Timestamp: start.Add(time.Duration(i) * time.Minute), CPUPercent: float64(i)})
+	}
+	var cpus []model.CPU
+	err := db.Where("timestamp > ? and timestamp < ?", start.Add(2*time.Minute), start.Add(6*time.Minute)).
+		Order("timestamp asc").Find(&cpus).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cpus) != 3 || cpus[0].CPUPercent != 3 {
+		t.Fatalf("unexpected range result: %d rows", len(cpus))
+	}
+}
+
+func TestAuditChainAndQuery(t *testing.T) {
+	db := openTestDB(t)
+	chain := auditlog.NewChain(db, []byte("test-key"))
+	for _, name := range []string{"admin", "admin", "guest"} {
+		if err := chain.Record(&model.Audit{Username: name, Operate: "启动容器", ResourceID: "abc123", Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := chain.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK || res.Checked != 3 {
+		t.Fatalf("unexpected verify result: %+v", res)
+	}
+
+	repo := auditRepository.NewAuditRepo(db)
+	args := &schema.AuditQueryArgs{AuditFilter: schema.AuditFilter{Username: "admin"}, Page: 1, Size: 10}
+	audits, err := repo.AuditQuery(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, err := repo.AuditCount(context.Background(), &args.AuditFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) != 2 || total != 2 {
+		t.Fatalf("expected 2 audits, got %d/%d", len(audits), total)
+	}
+}
@@ -0,0 +1,97 @@
+// Package model
+// Date: 2024/4/25 15:10
+// Author: Amu
+// Description:
+package model
+
+import "time"
+
+// 主机指标名称，采集任务写入、查询接口读取时共用
+const (
+	MetricCPUPercent      = "cpu_percent"
+	MetricMemoryPercent   = "memory_percent"
+	MetricMemoryTotal     = "memory_total"
+	MetricMemoryUsed      = "memory_used"
+	MetricMemoryAvailable = "memory_available"
+	MetricMemoryBuffers   = "memory_buffers"
+	MetricMemoryCached    = "memory_cached"
+	MetricSwapTotal       = "swap_total"
+	MetricSwapUsed        = "swap_used"
+	MetricSwapIn          = "swap_in"
+	MetricSwapOut         = "swap_out"
+	MetricDiskRead        = "disk_read"
+	MetricDiskWrite       = "disk_write"
+	MetricDiskReadIOPS    = "disk_read_iops"
+	MetricDiskWriteIOPS   = "disk_write_iops"
+	MetricDiskAwait       = "disk_await"
+	MetricDiskQueueDepth  = "disk_queue_depth"
+	MetricDiskUtil        = "disk_util"
+	MetricNetRecv         = "net_recv"
+	MetricNetSend         = "net_send"
+	MetricNetPacketsRecv  = "net_packets_recv"
+	MetricNetPacketsSent  = "net_packets_sent"
+	MetricNetErrIn        = "net_err_in"
+	MetricNetErrOut       = "net_err_out"
+	MetricNetDropIn       = "net_drop_in"
+	MetricNetDropOut      = "net_drop_out"
+)
+
+// TCP 连接数，LabelState 区分 ESTABLISHED / TIME_WAIT 等状态
+const MetricTCPConnections = "tcp_connections"
+
+// PSI 指标，LabelResource 区分 cpu / memory / io
+const (
+	MetricPressureSome = "pressure_some_avg10"
+	MetricPressureFull = "pressure_full_avg10"
+)
+
+// 传感器指标，LabelSensor 为传感器名称
+const (
+	MetricTemperature     = "sensor_temperature_celsius"
+	MetricFanSpeed        = "sensor_fan_rpm"
+	MetricBatteryCapacity = "sensor_battery_capacity"
+)
+
+// 容器指标名称
+const (
+	MetricContainerCPUPercent    = "container_cpu_percent"
+	MetricContainerMemoryPercent = "container_memory_percent"
+	MetricContainerMemoryUsage   = "container_memory_usage"
+	MetricContainerMemoryLimit   = "container_memory_limit"
+	MetricContainerIORead        = "container_io_read"
+	MetricContainerIOWrite       = "container_io_write"
+	MetricContainerPIDs          = "container_pids"
+)
+
+// 采集自身指标，LabelCollector 为采集项名称；错误和跳过次数为进程启动以来的累计值
+const (
+	MetricCollectorDuration = "amprobe_collector_duration_seconds"
+	MetricCollectorErrors   = "amprobe_collector_errors_total"
+	MetricCollectorSkipped  = "amprobe_collector_skipped_total"
+)
+
+// 指标标签
+const (
+	LabelDevice    = "device"
+	LabelEthernet  = "ethernet"
+	LabelContainer = "container_id"
+	LabelName      = "name"
+	LabelImage     = "image"
+	LabelResource  = "resource"
+	LabelState     = "state"
+	LabelSensor    = "sensor"
+	LabelCollector = "collector"
+)
+
+// MetricSample 通用指标样本，没有专用表的指标写入此表
+type MetricSample struct {
+	ID        uint      `gorm:"primarykey"`
+	Metric    string    `gorm:"size:128;not null;index:idx_metric_sample_metric_ts,priority:1"`
+	Labels    string    `gorm:"size:512;comment:标签(规范格式)"`
+	Timestamp time.Time `gorm:"index:idx_metric_sample_metric_ts,priority:2;index"`
+	Value     float64
+}
+
+func (m *MetricSample) TableName() string {
+	return "s_metric_sample"
+}
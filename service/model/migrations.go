@@ -0,0 +1,121 @@
+// Package model
+// Date: 2024/4/24 11:05
+// Author: Amu
+// Description: 数据库迁移，新增或修改表结构时追加新版本，已发布的版本不要修改；
+// 迁移使用 migrations_schema.go 中按版本冻结的结构，不要直接引用当前的模型
+package model
+
+import (
+	"github.com/amuluze/amprobe/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+func (a *Models) Migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{
+			// 基线版本，兼容此前由 AutoMigrate 创建的库
+			Version: 1,
+			Name:    "baseline",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					new(containerV1),
+					new(dockerV1),
+					new(imageV1),
+					new(hostV1),
+					new(cpuV1),
+					new(memoryV1),
+					new(diskV1),
+					new(netV1),
+					new(userV1),
+					new(auditV1),
+					new(apiTokenV1),
+					new(authTokenV1),
+				)
+			},
+		},
+		{
+			Version: 2,
+			Name:    "metric sample",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(metricSampleV2))
+			},
+		},
+		{
+			Version: 3,
+			Name:    "process",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(processV3))
+			},
+		},
+		{
+			Version: 4,
+			Name:    "memory swap",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(memoryV4))
+			},
+		},
+		{
+			Version: 5,
+			Name:    "disk io stats",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(diskV5))
+			},
+		},
+		{
+			Version: 6,
+			Name:    "net errors",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(netV6))
+			},
+		},
+		{
+			Version: 7,
+			Name:    "sensor",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(sensorV7))
+			},
+		},
+		{
+			Version: 8,
+			Name:    "setting",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(settingV8))
+			},
+		},
+		{
+			// 登录令牌只保存哈希，删除令牌原文列
+			Version: 9,
+			Name:    "auth token hash only",
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasColumn(new(authTokenV1), "token") {
+					return tx.Migrator().DropColumn(new(authTokenV1), "token")
+				}
+				return nil
+			},
+		},
+		{
+			Version: 10,
+			Name:    "audit head",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(auditHeadV10))
+			},
+		},
+		{
+			Version: 11,
+			Name:    "totp last step",
+			Up: func(tx *gorm.DB) error {
+				if tx.Migrator().HasColumn(new(userV11), "TOTPLastStep") {
+					return nil
+				}
+				return tx.Migrator().AddColumn(new(userV11), "TOTPLastStep")
+			},
+		},
+		{
+			Version: 12,
+			Name:    "audit archive anchor",
+			Up: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(new(auditHeadV12))
+			},
+		},
+	}
+}
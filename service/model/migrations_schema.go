@@ -0,0 +1,322 @@
+// Package model
+// Date: 2024/5/10 18:00
+// Author: Amu
+// Description: 各迁移版本冻结的表结构，迁移只使用这里的结构，模型后续的修改不会影响已发布的版本
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// v1 baseline
+
+type containerV1 struct {
+	gorm.Model
+	Timestamp   time.Time `gorm:"index"`
+	ContainerID string
+	Name        string
+	Image       string
+	IP          string
+	State       string
+	Uptime      string
+	CPUPercent  float64
+	MemPercent  float64
+	MemUsage    float64
+	MemLimit    float64
+}
+
+func (containerV1) TableName() string { return "s_container" }
+
+type dockerV1 struct {
+	gorm.Model
+	Timestamp     time.Time `gorm:"index"`
+	DockerVersion string
+	APIVersion    string
+	MinAPIVersion string
+	GitCommit     string
+	GoVersion     string
+	Os            string
+	Arch          string
+}
+
+func (dockerV1) TableName() string { return "s_docker" }
+
+type imageV1 struct {
+	gorm.Model
+	Timestamp time.Time `gorm:"index"`
+	ImageID   string
+	Name      string
+	Tag       string
+	Created   string
+	Size      string
+	Number    int
+}
+
+func (imageV1) TableName() string { return "s_image" }
+
+type hostV1 struct {
+	gorm.Model
+	Timestamp       time.Time `gorm:"index"`
+	Uptime          string
+	Hostname        string
+	Os              string
+	Platform        string
+	PlatformVersion string
+	KernelVersion   string
+	KernelArch      string
+}
+
+func (hostV1) TableName() string { return "s_host" }
+
+type cpuV1 struct {
+	gorm.Model
+	Timestamp  time.Time `gorm:"index"`
+	CPUPercent float64
+}
+
+func (cpuV1) TableName() string { return "s_cpu" }
+
+type memoryV1 struct {
+	gorm.Model
+	Timestamp  time.Time `gorm:"index"`
+	MemPercent float64
+	MemTotal   float64
+	MemUsed    float64
+}
+
+func (memoryV1) TableName() string { return "s_memory" }
+
+type diskV1 struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	Device    string
+	DiskRead  float64
+	DiskWrite float64
+}
+
+func (diskV1) TableName() string { return "s_disk" }
+
+type netV1 struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	Ethernet  string
+	NetRecv   float64
+	NetSend   float64
+}
+
+func (netV1) TableName() string { return "s_net" }
+
+type userV1 struct {
+	ID        UUID      `gorm:"primaryKey;comment:唯一标识"`
+	CreatedAt time.Time `gorm:"comment:创建时间"`
+	UpdatedAt time.Time `gorm:"comment:更新时间"`
+	Username  string    `gorm:"size:255;uniqueIndex;not null;comment:用户名"`
+	Password  string    `gorm:"size:128;not null;comment:密码"`
+	Remark    *string   `gorm:"size:200;comment:备注"`
+	IsAdmin   string    `gorm:"default:'0';comment:是否是管理员('1':是 '0':否)"`
+	Status    int       `gorm:"index;default:0;comment:状态(1:启用 0:停用)"`
+	Source    string    `gorm:"size:32;default:'local';comment:用户来源(local/oidc/ldap)"`
+
+	TOTPSecret    string `gorm:"size:64;comment:TOTP 密钥"`
+	TOTPEnabled   bool   `gorm:"default:false;comment:是否已启用 TOTP"`
+	RecoveryCodes string `gorm:"size:1024;comment:TOTP 恢复码(SHA1, 逗号分隔)"`
+}
+
+func (userV1) TableName() string { return "sys_user" }
+
+type auditV1 struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	Username   string         `gorm:"type:varchar(255);not null;index"`
+	Operate    string         `gorm:"type:varchar(255);not null;index"`
+	ResourceID string         `gorm:"type:varchar(255);index;comment:操作对象"`
+	Params     string         `gorm:"type:text;comment:请求参数(已脱敏)"`
+	ClientIP   string         `gorm:"type:varchar(64);comment:客户端 IP"`
+	UserAgent  string         `gorm:"type:varchar(512);comment:客户端 UA"`
+	Method     string         `gorm:"type:varchar(16)"`
+	Path       string         `gorm:"type:varchar(255)"`
+	Status     int            `gorm:"comment:响应状态码"`
+	Duration   int64          `gorm:"comment:耗时(毫秒)"`
+	PrevHash   string         `gorm:"type:varchar(64);comment:上一条记录的哈希"`
+	Hash       string         `gorm:"type:varchar(64);comment:本条记录的 HMAC"`
+}
+
+func (auditV1) TableName() string { return "s_audit" }
+
+type apiTokenV1 struct {
+	gorm.Model
+	UserID     string     `gorm:"size:64;index;not null;comment:所属用户"`
+	Name       string     `gorm:"size:255;not null;comment:令牌名称"`
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null;comment:令牌 SHA256"`
+	Prefix     string     `gorm:"size:16;comment:令牌前缀，便于识别"`
+	ReadOnly   bool       `gorm:"default:false;comment:是否只读"`
+	ExpiresAt  *time.Time `gorm:"comment:过期时间，为空表示永不过期"`
+	LastUsedAt *time.Time `gorm:"comment:最近使用时间"`
+}
+
+func (apiTokenV1) TableName() string { return "s_api_token" }
+
+type authTokenV1 struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"comment:创建时间"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null;comment:令牌 SHA256"`
+	Token     string    `gorm:"type:text;not null;comment:令牌"`
+	UserID    string    `gorm:"size:64;index;not null;comment:所属用户"`
+	TokenType string    `gorm:"size:32;comment:令牌类型(access_token/refresh_token)"`
+	ExpiresAt time.Time `gorm:"index;comment:过期时间"`
+}
+
+func (authTokenV1) TableName() string { return "s_auth_token" }
+
+// v2 metric sample
+
+type metricSampleV2 struct {
+	ID        uint      `gorm:"primarykey"`
+	Metric    string    `gorm:"size:128;not null;index:idx_metric_sample_metric_ts,priority:1"`
+	Labels    string    `gorm:"size:512;comment:标签(规范格式)"`
+	Timestamp time.Time `gorm:"index:idx_metric_sample_metric_ts,priority:2;index"`
+	Value     float64
+}
+
+func (metricSampleV2) TableName() string { return "s_metric_sample" }
+
+// v3 process
+
+type processV3 struct {
+	ID          uint      `gorm:"primarykey"`
+	Timestamp   time.Time `gorm:"index"`
+	PID         int32     `gorm:"index"`
+	Name        string    `gorm:"size:255"`
+	Cmdline     string    `gorm:"size:1024"`
+	User        string    `gorm:"size:64"`
+	CPUPercent  float64
+	MemPercent  float64
+	RSS         uint64
+	Threads     int32
+	FDs         int32
+	ContainerID string `gorm:"size:64;comment:所在容器 ID，宿主机进程为空"`
+}
+
+func (processV3) TableName() string { return "s_process" }
+
+// v4 memory swap
+
+type memoryV4 struct {
+	gorm.Model
+	Timestamp    time.Time `gorm:"index"`
+	MemPercent   float64
+	MemTotal     float64
+	MemUsed      float64
+	MemAvailable float64
+	MemBuffers   float64
+	MemCached    float64
+	SwapTotal    float64
+	SwapUsed     float64
+	SwapIn       float64
+	SwapOut      float64
+}
+
+func (memoryV4) TableName() string { return "s_memory" }
+
+// v5 disk io stats
+
+type diskV5 struct {
+	ID             uint      `gorm:"primarykey"`
+	CreatedAt      time.Time `gorm:"index"`
+	Device         string
+	DiskRead       float64
+	DiskWrite      float64
+	DiskReadIOPS   float64
+	DiskWriteIOPS  float64
+	DiskAwait      float64
+	DiskQueueDepth float64
+	DiskUtil       float64
+}
+
+func (diskV5) TableName() string { return "s_disk" }
+
+// v6 net errors
+
+type netV6 struct {
+	ID             uint      `gorm:"primarykey"`
+	CreatedAt      time.Time `gorm:"index"`
+	Ethernet       string
+	NetRecv        float64
+	NetSend        float64
+	NetPacketsRecv float64
+	NetPacketsSent float64
+	NetErrIn       float64
+	NetErrOut      float64
+	NetDropIn      float64
+	NetDropOut     float64
+}
+
+func (netV6) TableName() string { return "s_net" }
+
+// v7 sensor
+
+type sensorV7 struct {
+	ID        uint      `gorm:"primarykey"`
+	Timestamp time.Time `gorm:"index"`
+	Type      string    `gorm:"size:16;index;comment:temperature / fan / battery"`
+	Name      string    `gorm:"size:128"`
+	Value     float64
+	High      float64 `gorm:"comment:温度告警阈值，0 表示未提供"`
+	Critical  float64 `gorm:"comment:温度临界阈值，0 表示未提供"`
+	Status    string  `gorm:"size:32;comment:电池状态"`
+}
+
+func (sensorV7) TableName() string { return "s_sensor" }
+
+// v8 setting
+
+type settingV8 struct {
+	ID               uint     `gorm:"primarykey"`
+	Interval         int      `gorm:"comment:采集间隔，单位 s"`
+	Devices          []string `gorm:"serializer:json;type:text;comment:采集的磁盘设备"`
+	Ethernets        []string `gorm:"serializer:json;type:text;comment:采集的网卡"`
+	NotMonitorDocker bool     `gorm:"comment:与配置 Task.NotMonitorDocker 一致，为 true 时采集容器指标"`
+	UpdatedAt        time.Time
+}
+
+func (settingV8) TableName() string { return "s_setting" }
+
+// v10 audit head
+
+type auditHeadV10 struct {
+	ID        uint   `gorm:"primarykey"`
+	Seq       uint64 `gorm:"comment:写入次数"`
+	LastID    uint   `gorm:"comment:最后一条记录 ID"`
+	LastHash  string `gorm:"type:varchar(64);comment:最后一条记录的哈希"`
+	Hash      string `gorm:"type:varchar(64);comment:链尾的 HMAC"`
+	UpdatedAt time.Time
+}
+
+func (auditHeadV10) TableName() string { return "s_audit_head" }
+
+// v11 totp last step，只新增一列
+
+type userV11 struct {
+	TOTPLastStep int64 `gorm:"default:0;comment:最近一次通过的 TOTP 时间窗口，用于拒绝重放"`
+}
+
+func (userV11) TableName() string { return "sys_user" }
+
+// v12 audit archive anchor
+
+type auditHeadV12 struct {
+	ID         uint   `gorm:"primarykey"`
+	Seq        uint64 `gorm:"comment:写入次数"`
+	LastID     uint   `gorm:"comment:最后一条记录 ID"`
+	LastHash   string `gorm:"type:varchar(64);comment:最后一条记录的哈希"`
+	AnchorID   uint   `gorm:"comment:最后一条已归档记录 ID"`
+	AnchorHash string `gorm:"type:varchar(64);comment:最后一条已归档记录的哈希"`
+	Hash       string `gorm:"type:varchar(64);comment:链尾的 HMAC"`
+	UpdatedAt  time.Time
+}
+
+func (auditHeadV12) TableName() string { return "s_audit_head" }
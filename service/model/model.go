@@ -26,5 +26,12 @@ func (a *Models) GetAllModels() []interface{} {
 		new(Net),
 		new(User),
 		new(Audit),
+		new(AuditHead),
+		new(APIToken),
+		new(AuthToken),
+		new(MetricSample),
+		new(Process),
+		new(Sensor),
+		new(Setting),
 	}
 }
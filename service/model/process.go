@@ -0,0 +1,34 @@
+// Package model
+// Date: 2024/4/27 15:20
+// Author: Amu
+// Description:
+package model
+
+import "time"
+
+// 进程字段的列长度，写入前按此截断，超长时 postgres / mysql 会拒绝整批写入
+const (
+	ProcessNameSize    = 255
+	ProcessCmdlineSize = 1024
+	ProcessUserSize    = 64
+)
+
+// Process 每个采集周期 CPU 或内存占用靠前的进程
+type Process struct {
+	ID          uint      `gorm:"primarykey"`
+	Timestamp   time.Time `gorm:"index"`
+	PID         int32     `gorm:"index"`
+	Name        string    `gorm:"size:255"`
+	Cmdline     string    `gorm:"size:1024"`
+	User        string    `gorm:"size:64"`
+	CPUPercent  float64
+	MemPercent  float64
+	RSS         uint64
+	Threads     int32
+	FDs         int32
+	ContainerID string `gorm:"size:64;comment:所在容器 ID，宿主机进程为空"`
+}
+
+func (p *Process) TableName() string {
+	return "s_process"
+}
@@ -0,0 +1,30 @@
+// Package model
+// Date: 2024/4/29 15:10
+// Author: Amu
+// Description:
+package model
+
+import "time"
+
+// 传感器类型
+const (
+	SensorTemperature = "temperature"
+	SensorFan         = "fan"
+	SensorBattery     = "battery"
+)
+
+// Sensor 传感器读数，温度单位 ℃，风扇单位 RPM，电池为电量百分比
+type Sensor struct {
+	ID        uint      `gorm:"primarykey"`
+	Timestamp time.Time `gorm:"index"`
+	Type      string    `gorm:"size:16;index;comment:temperature / fan / battery"`
+	Name      string    `gorm:"size:128"`
+	Value     float64
+	High      float64 `gorm:"comment:温度告警阈值，0 表示未提供"`
+	Critical  float64 `gorm:"comment:温度临界阈值，0 表示未提供"`
+	Status    string  `gorm:"size:32;comment:电池状态"`
+}
+
+func (s *Sensor) TableName() string {
+	return "s_sensor"
+}
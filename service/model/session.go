@@ -0,0 +1,21 @@
+// Package model
+// Date: 2024/4/20 14:02
+// Author: Amu
+// Description:
+package model
+
+import "time"
+
+// AuthToken 持久化的登录令牌，用于数据库令牌存储，只保存令牌的哈希
+type AuthToken struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"comment:创建时间"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null;comment:令牌 SHA256"`
+	UserID    string    `gorm:"size:64;index;not null;comment:所属用户"`
+	TokenType string    `gorm:"size:32;comment:令牌类型(access_token/refresh_token)"`
+	ExpiresAt time.Time `gorm:"index;comment:过期时间"`
+}
+
+func (t *AuthToken) TableName() string {
+	return "s_auth_token"
+}
@@ -0,0 +1,24 @@
+// Package model
+// Date: 2024/5/8 10:00
+// Author: Amu
+// Description:
+package model
+
+import "time"
+
+// SettingID 设置表只保存一行
+const SettingID = 1
+
+// Setting 运行时可修改的采集设置，首次启动时由配置文件初始化，之后以数据库为准
+type Setting struct {
+	ID               uint     `gorm:"primarykey"`
+	Interval         int      `gorm:"comment:采集间隔，单位 s"`
+	Devices          []string `gorm:"serializer:json;type:text;comment:采集的磁盘设备"`
+	Ethernets        []string `gorm:"serializer:json;type:text;comment:采集的网卡"`
+	NotMonitorDocker bool     `gorm:"comment:与配置 Task.NotMonitorDocker 一致，为 true 时采集容器指标"`
+	UpdatedAt        time.Time
+}
+
+func (s *Setting) TableName() string {
+	return "s_setting"
+}
@@ -0,0 +1,29 @@
+// Package model
+// Date: 2024/4/19 09:48
+// Author: Amu
+// Description:
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type APITokens []APIToken
+
+// APIToken 用于脚本和 CI 调用的长期访问令牌，只保存哈希值
+type APIToken struct {
+	gorm.Model
+	UserID     string     `gorm:"size:64;index;not null;comment:所属用户"`
+	Name       string     `gorm:"size:255;not null;comment:令牌名称"`
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null;comment:令牌 SHA256"`
+	Prefix     string     `gorm:"size:16;comment:令牌前缀，便于识别"`
+	ReadOnly   bool       `gorm:"default:false;comment:是否只读"`
+	ExpiresAt  *time.Time `gorm:"comment:过期时间，为空表示永不过期"`
+	LastUsedAt *time.Time `gorm:"comment:最近使用时间"`
+}
+
+func (t *APIToken) TableName() string {
+	return "s_api_token"
+}
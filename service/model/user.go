@@ -5,14 +5,13 @@
 package model
 
 import (
-	"github.com/amuluze/amprobe/pkg/utils/uuid"
 	"time"
 )
 
 type Users []*User
 
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;comment:唯一标识"`
+	ID        UUID      `gorm:"primaryKey;comment:唯一标识"`
 	CreatedAt time.Time `gorm:"comment:创建时间"`
 	UpdatedAt time.Time `gorm:"comment:更新时间"`
 	Username  string    `gorm:"size:255;uniqueIndex;not null;comment:用户名"`
@@ -20,6 +19,12 @@ type User struct {
 	Remark    *string   `gorm:"size:200;comment:备注"`
 	IsAdmin   string    `gorm:"default:'0';comment:是否是管理员('1':是 '0':否)"`
 	Status    int       `gorm:"index;default:0;comment:状态(1:启用 0:停用)"`
+	Source    string    `gorm:"size:32;default:'local';comment:用户来源(local/oidc/ldap)"`
+
+	TOTPSecret    string `gorm:"size:64;comment:TOTP 密钥"`
+	TOTPEnabled   bool   `gorm:"default:false;comment:是否已启用 TOTP"`
+	RecoveryCodes string `gorm:"size:1024;comment:TOTP 恢复码(SHA1, 逗号分隔)"`
+	TOTPLastStep  int64  `gorm:"default:0;comment:最近一次通过的 TOTP 时间窗口，用于拒绝重放"`
 }
 
 func (a User) TableName() string {
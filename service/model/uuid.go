@@ -0,0 +1,32 @@
+// Package model
+// Date: 2024/4/24 10:30
+// Author: Amu
+// Description:
+package model
+
+import (
+	"github.com/amuluze/amprobe/pkg/utils/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// UUID 主键类型，按数据库方言映射列类型
+type UUID struct {
+	uuid.UUID
+}
+
+func NewUUID() UUID {
+	return UUID{UUID: uuid.MustUUID()}
+}
+
+// GormDBDataType PostgreSQL 使用原生 uuid，MySQL 使用 char(36)，SQLite 保持原有的 uuid 声明
+func (UUID) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "uuid"
+	case "mysql":
+		return "char(36)"
+	default:
+		return "uuid"
+	}
+}
@@ -6,7 +6,6 @@ package service
 
 import (
 	"github.com/amuluze/amprobe/pkg/utils/hash"
-	"github.com/amuluze/amprobe/pkg/utils/uuid"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amutool/database"
 	"github.com/google/wire"
@@ -45,7 +44,7 @@ func (a *Prepare) Init(config *Config) {
 			// 不存在则创建
 			if err := a.db.Model(&model.User{}).Where("username = ?", u.Username).Take(&ou).Error; err != nil {
 				a.db.Create(&model.User{
-					ID:       uuid.MustUUID(),
+					ID:       model.NewUUID(),
 					Username: u.Username,
 					Password: hash.SHA1String(u.Password),
 					Status:   u.Status,
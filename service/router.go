@@ -16,6 +16,7 @@ import (
 	authAPI "github.com/amuluze/amprobe/service/auth/api"
 	containerAPI "github.com/amuluze/amprobe/service/container/api"
 	hostAPI "github.com/amuluze/amprobe/service/host/api"
+	systemAPI "github.com/amuluze/amprobe/service/system/api"
 )
 
 var RouterSet = wire.NewSet(wire.Struct(new(Router), "*"), wire.Bind(new(IRouter), new(*Router)))
@@ -35,6 +36,7 @@ type Router struct {
 	hostAPI      *hostAPI.HostAPI
 	authAPI      *authAPI.AuthAPI
 	auditAPI     *auditAPI.AuditAPI
+	systemAPI    *systemAPI.SystemAPI
 
 	loggerHandler *LoggerHandler
 }
@@ -42,6 +44,10 @@ type Router struct {
 func (a *Router) RegisterAPI(app *fiber.App) {
 	// 以下是 websocket service
 
+	// 探针接口，不需要认证
+	app.Get("/healthz", a.systemAPI.Healthz).Name("存活检查")
+	app.Get("/readyz", a.systemAPI.Readyz).Name("就绪检查")
+
 	// 以下是 http 服务
 	g := app.Group("/api")
 
@@ -50,6 +56,7 @@ func (a *Router) RegisterAPI(app *fiber.App) {
 			a.auth,
 			middleware.AllowPathPrefixSkipper("/api/v1/auth/login"),
 			middleware.AllowPathPrefixSkipper("/api/v1/auth/token_update"),
+			middleware.AllowPathPrefixSkipper("/api/v1/auth/oidc/"),
 		))
 	}
 
@@ -68,6 +75,18 @@ func (a *Router) RegisterAPI(app *fiber.App) {
 			gAuth.Post("/logout", a.authAPI.Logout).Name("登出")
 			gAuth.Post("/pass_update", a.authAPI.PassUpdate).Name("更新密码")
 			gAuth.Post("/token_update", a.authAPI.TokenUpdate).Name("更新 token")
+			gAuth.Post("/login_totp", a.authAPI.LoginTOTP).Name("TOTP 二次验证登录")
+			gAuth.Post("/login_totp_setup", a.authAPI.LoginTOTPSetup).Name("登录时绑定 TOTP")
+			gAuth.Post("/totp_enroll", a.authAPI.TOTPEnroll).Name("绑定 TOTP")
+			gAuth.Post("/totp_activate", a.authAPI.TOTPActivate).Name("启用 TOTP")
+			gAuth.Post("/totp_disable", a.authAPI.TOTPDisable).Name("停用 TOTP")
+			gAuth.Get("/oidc/login", a.authAPI.OIDCLogin).Name("OIDC 登录")
+			gAuth.Get("/oidc/callback", a.authAPI.OIDCCallback).Name("OIDC 登录回调")
+			gAuth.Get("/api_tokens", a.authAPI.APITokenList).Name("获取访问令牌列表")
+			gAuth.Post("/api_token_create", a.authAPI.APITokenCreate).Name("创建访问令牌")
+			gAuth.Post("/api_token_revoke", a.authAPI.APITokenRevoke).Name("吊销访问令牌")
+			gAuth.Get("/sessions", a.authAPI.SessionList).Name("获取登录会话列表")
+			gAuth.Post("/logout_all", a.authAPI.LogoutAll).Name("注销全部会话")
 		}
 
 		gContainer := v1.Group("container")
@@ -93,12 +112,31 @@ func (a *Router) RegisterAPI(app *fiber.App) {
 			gHost.Get("mem_trending", a.hostAPI.MemUsage).Name("获取内存使用率")
 			gHost.Get("disk_trending", a.hostAPI.DiskUsage).Name("获取磁盘使用率")
 			gHost.Get("net_trending", a.hostAPI.NetUsage).Name("获取网络使用率")
+			gHost.Get("/net_errors_trending", a.hostAPI.NetErrors).Name("获取网卡错误及丢包情况")
+			gHost.Get("/connections", a.hostAPI.Connections).Name("获取连接状态及监听端口")
+			gHost.Get("/swap_trending", a.hostAPI.SwapUsage).Name("获取 swap 换入换出速率")
+			gHost.Get("/psi_trending", a.hostAPI.PressureUsage).Name("获取 PSI 压力趋势")
+			gHost.Get("/processes", a.hostAPI.Processes).Name("获取进程列表")
+			gHost.Get("/sensors", a.hostAPI.Sensors).Name("获取传感器读数")
+			gHost.Get("/sensors_trending", a.hostAPI.SensorUsage).Name("获取传感器趋势")
 		}
 
 		gAudit := v1.Group("audit")
 		{
 			gAudit.Get("/query", a.auditAPI.AuditQuery).Name("获取审计日志")
+			gAudit.Get("/export", a.auditAPI.AuditExport).Name("导出审计日志")
+			gAudit.Get("/verify", a.auditAPI.AuditVerify).Name("校验审计日志")
+			gAudit.Get("/archives", a.auditAPI.AuditArchiveList).Name("获取审计归档列表")
+			gAudit.Get("/archive_download", a.auditAPI.AuditArchiveDownload).Name("下载审计归档")
 		}
+
+		gSystem := v1.Group("system")
+		{
+			gSystem.Get("/status", a.systemAPI.Status).Name("获取采集状态")
+		}
+
+		v1.Get("/settings", a.systemAPI.SettingsGet).Name("获取设置")
+		v1.Put("/settings", a.systemAPI.SettingsUpdate).Name("更新设置")
 	}
 	app.Use("ws", func(c *fiber.Ctx) error {
 		// IsWebSocketUpgrade returns true if the client
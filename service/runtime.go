@@ -0,0 +1,29 @@
+// Package service
+// Date: 2024/4/30 17:20
+// Author: Amu
+// Description:
+package service
+
+import (
+	"log/slog"
+
+	"github.com/amuluze/amprobe/pkg/containerx"
+)
+
+func InitRuntime(config *Config) (containerx.Runtime, func(), error) {
+	rt, err := containerx.New(containerx.Options{
+		Runtime:   config.Container.Runtime,
+		Endpoint:  config.Container.Endpoint,
+		Namespace: config.Container.Namespace,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("container runtime initialized", "runtime", rt.Name())
+	cleanFunc := func() {
+		if err := rt.Close(); err != nil {
+			slog.Error("failed to close container runtime", "error", err)
+		}
+	}
+	return rt, cleanFunc, nil
+}
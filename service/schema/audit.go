@@ -5,15 +5,35 @@
 package schema
 
 type Audit struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Operate  string `json:"operate"`
-	Created  string `json:"created"`
+	ID         uint   `json:"id"`
+	Username   string `json:"username"`
+	Operate    string `json:"operate"`
+	ResourceID string `json:"resource_id"`
+	Params     string `json:"params"`
+	ClientIP   string `json:"client_ip"`
+	UserAgent  string `json:"user_agent"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Status     int    `json:"status"`
+	Duration   int64  `json:"duration"`
+	Created    string `json:"created"`
+}
+
+// AuditFilter 审计日志过滤条件，为空的条件不生效
+type AuditFilter struct {
+	Username   string `query:"username" description:"操作用户"`
+	Operate    string `query:"operate" description:"操作类型"`
+	ResourceID string `query:"resource_id" description:"操作对象"`
+	StartTime  int64  `query:"start_time" description:"开始时间(unix 秒)"`
+	EndTime    int64  `query:"end_time" description:"结束时间(unix 秒)"`
 }
 
 type AuditQueryArgs struct {
-	Page int `json:"page" validate:"required"`
-	Size int `json:"size" validate:"required,gt=0"`
+	AuditFilter
+	// BeforeID 游标分页，大于 0 时返回 ID 小于该值的记录并忽略 Page
+	BeforeID uint `query:"before_id" description:"游标分页"`
+	Page     int  `json:"page" validate:"required"`
+	Size     int  `json:"size" validate:"required,gt=0"`
 }
 
 type AuditQueryReply struct {
@@ -22,3 +42,29 @@ type AuditQueryReply struct {
 	Page  int     `json:"page"`
 	Size  int     `json:"size"`
 }
+
+type AuditExportArgs struct {
+	AuditFilter
+	Format string `query:"format" validate:"omitempty,oneof=csv json" description:"导出格式: csv / json"`
+}
+
+type AuditVerifyReply struct {
+	OK       bool   `json:"ok" description:"哈希链是否完整"`
+	Checked  int    `json:"checked" description:"已校验的记录数"`
+	BrokenID uint   `json:"broken_id,omitempty" description:"第一条校验失败的记录"`
+	Reason   string `json:"reason,omitempty" description:"失败原因"`
+}
+
+type AuditArchive struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mod_time"`
+}
+
+type AuditArchiveListReply struct {
+	Data []AuditArchive `json:"data"`
+}
+
+type AuditArchiveDownloadArgs struct {
+	Name string `query:"name" validate:"required" description:"归档文件名"`
+}
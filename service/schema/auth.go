@@ -12,6 +12,10 @@ type LoginArgs struct {
 type LoginResult struct {
 	AccessToken  string `json:"access_token" description:"访问令牌"`
 	RefreshToken string `json:"refresh_token" description:"刷新令牌"`
+
+	TOTPRequired      bool   `json:"totp_required,omitempty" description:"需要输入 TOTP 验证码"`
+	TOTPSetupRequired bool   `json:"totp_setup_required,omitempty" description:"需要先绑定 TOTP"`
+	TOTPToken         string `json:"totp_token,omitempty" description:"TOTP 校验使用的临时令牌"`
 }
 
 type PasswordUpdateArgs struct {
@@ -26,3 +30,69 @@ type UserInfo struct {
 	Email    string `json:"email" description:"邮箱"`
 	Status   int    `json:"status" description:"状态"`
 }
+
+type LoginTOTPArgs struct {
+	TOTPToken string `json:"totp_token" validate:"required" description:"登录第一步返回的临时令牌"`
+	Code      string `json:"code" validate:"required" description:"TOTP 验证码或恢复码"`
+}
+
+type LoginTOTPSetupArgs struct {
+	TOTPToken string `json:"totp_token" validate:"required" description:"登录第一步返回的临时令牌"`
+}
+
+type TOTPEnrollResult struct {
+	Secret        string   `json:"secret" description:"TOTP 密钥"`
+	URI           string   `json:"uri" description:"otpauth URI"`
+	RecoveryCodes []string `json:"recovery_codes" description:"恢复码，仅展示一次"`
+}
+
+type TOTPCodeArgs struct {
+	Code string `json:"code" validate:"required" description:"TOTP 验证码"`
+}
+
+type OIDCCallbackArgs struct {
+	Code             string `query:"code" description:"授权码"`
+	State            string `query:"state" validate:"required" description:"state"`
+	Error            string `query:"error" description:"身份提供方返回的错误"`
+	ErrorDescription string `query:"error_description" description:"错误描述"`
+}
+
+type APIToken struct {
+	ID         uint   `json:"id" description:"令牌 id"`
+	Name       string `json:"name" description:"令牌名称"`
+	Prefix     string `json:"prefix" description:"令牌前缀"`
+	ReadOnly   bool   `json:"read_only" description:"是否只读"`
+	ExpiresAt  string `json:"expires_at" description:"过期时间，为空表示永不过期"`
+	LastUsedAt string `json:"last_used_at" description:"最近使用时间"`
+	Created    string `json:"created" description:"创建时间"`
+}
+
+type APITokenCreateArgs struct {
+	Name      string `json:"name" validate:"required,gte=1,lte=255" description:"令牌名称"`
+	ExpiresIn int    `json:"expires_in" validate:"gte=0" description:"有效天数，0 表示永不过期"`
+	ReadOnly  bool   `json:"read_only" description:"是否只读"`
+}
+
+type APITokenCreateResult struct {
+	APIToken
+	Token string `json:"token" description:"令牌明文，仅展示一次"`
+}
+
+type APITokenListReply struct {
+	Data []APIToken `json:"data"`
+}
+
+type APITokenRevokeArgs struct {
+	ID uint `json:"id" validate:"required" description:"令牌 id"`
+}
+
+type Session struct {
+	ID        string `json:"id" description:"会话标识（令牌摘要前缀）"`
+	TokenType string `json:"token_type" description:"令牌类型"`
+	ExpiresAt int64  `json:"expires_at" description:"过期时间"`
+	Current   bool   `json:"current" description:"是否为当前会话"`
+}
+
+type SessionListReply struct {
+	Data []Session `json:"data"`
+}
@@ -19,6 +19,13 @@ type DiskIO struct {
 	Timestamp int64   `json:"timestamp"`
 	IORead    float64 `json:"io_read"`
 	IOWrite   float64 `json:"io_write"`
+	ReadIOPS  float64 `json:"read_iops"`
+	WriteIOPS float64 `json:"write_iops"`
+	// Await 平均每次 IO 耗时，单位 ms
+	Await      float64 `json:"await"`
+	QueueDepth float64 `json:"queue_depth"`
+	// Util 设备忙碌时间占比(%)
+	Util float64 `json:"util"`
 }
 
 type HostInfoReply struct {
@@ -39,6 +46,10 @@ type CPUInfoReply struct {
 type CPUUsageArgs struct {
 	StartTime int64 `query:"start_time"`
 	EndTime   int64 `query:"end_time"`
+	// Step 降采样步长，单位 s，0 表示返回原始数据
+	Step int64 `query:"step" validate:"gte=0"`
+	// Aggregation 降采样聚合方式: avg / min / max / sum / last，默认 avg
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
 }
 
 type CPUUsageReply struct {
@@ -46,20 +57,151 @@ type CPUUsageReply struct {
 }
 
 type MemoryInfoReply struct {
-	Percent float64 `json:"percent"`
-	Total   float64 `json:"total"`
-	Used    float64 `json:"used"`
+	Percent   float64 `json:"percent"`
+	Total     float64 `json:"total"`
+	Used      float64 `json:"used"`
+	Available float64 `json:"available"`
+	Buffers   float64 `json:"buffers"`
+	Cached    float64 `json:"cached"`
+	SwapTotal float64 `json:"swap_total"`
+	SwapUsed  float64 `json:"swap_used"`
 }
 
 type MemoryUsageArgs struct {
 	StartTime int64 `query:"start_time"`
 	EndTime   int64 `query:"end_time"`
+	// Step 降采样步长，单位 s，0 表示返回原始数据
+	Step int64 `query:"step" validate:"gte=0"`
+	// Aggregation 降采样聚合方式: avg / min / max / sum / last，默认 avg
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
+	// Metric 趋势指标: percent(默认) / used / available / buffers / cached / swap_used
+	Metric string `query:"metric" validate:"omitempty,oneof=percent used available buffers cached swap_used"`
 }
 
 type MemoryUsageReply struct {
 	Data []Usage `json:"data"`
 }
 
+type NetErrorsArgs struct {
+	StartTime   int64  `query:"start_time"`
+	EndTime     int64  `query:"end_time"`
+	Step        int64  `query:"step" validate:"gte=0"`
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
+}
+
+// NetErrorIO 网卡包速率及错误、丢包速率，单位 个/s
+type NetErrorIO struct {
+	Timestamp   int64   `json:"timestamp"`
+	PacketsRecv float64 `json:"packets_recv"`
+	PacketsSent float64 `json:"packets_sent"`
+	ErrIn       float64 `json:"errin"`
+	ErrOut      float64 `json:"errout"`
+	DropIn      float64 `json:"dropin"`
+	DropOut     float64 `json:"dropout"`
+}
+
+type NetErrorsReply struct {
+	Ethernet string       `json:"ethernet"`
+	Data     []NetErrorIO `json:"data"`
+}
+
+type ConnectionState struct {
+	State string `json:"state"`
+	Count int    `json:"count"`
+}
+
+type ListenPort struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	PID      int32  `json:"pid"`
+	Process  string `json:"process"`
+}
+
+type ConnectionsReply struct {
+	Timestamp int64             `json:"timestamp"`
+	States    []ConnectionState `json:"states"`
+	Listens   []ListenPort      `json:"listens"`
+}
+
+type Temperature struct {
+	Name     string  `json:"name"`
+	Value    float64 `json:"value"`
+	High     float64 `json:"high"`
+	Critical float64 `json:"critical"`
+}
+
+type Fan struct {
+	Name string  `json:"name"`
+	RPM  float64 `json:"rpm"`
+}
+
+type Battery struct {
+	Name     string  `json:"name"`
+	Capacity float64 `json:"capacity"`
+	Status   string  `json:"status"`
+}
+
+// SensorsReply 最近一次采集的传感器读数，没有对应硬件时列表为空
+type SensorsReply struct {
+	Timestamp    int64         `json:"timestamp"`
+	Temperatures []Temperature `json:"temperatures"`
+	Fans         []Fan         `json:"fans"`
+	Batteries    []Battery     `json:"batteries"`
+}
+
+type SensorUsageArgs struct {
+	StartTime int64  `query:"start_time"`
+	EndTime   int64  `query:"end_time"`
+	Type      string `query:"type" validate:"required,oneof=temperature fan battery"`
+	// Name 为空时返回该类型的全部传感器
+	Name string `query:"name"`
+}
+
+type SensorUsageReply struct {
+	Name string  `json:"name"`
+	Data []Usage `json:"data"`
+}
+
+type SwapUsageArgs struct {
+	StartTime   int64  `query:"start_time"`
+	EndTime     int64  `query:"end_time"`
+	Step        int64  `query:"step" validate:"gte=0"`
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
+}
+
+// SwapIO swap 换入/换出速率，单位 B/s
+type SwapIO struct {
+	Timestamp int64   `json:"timestamp"`
+	SwapIn    float64 `json:"swap_in"`
+	SwapOut   float64 `json:"swap_out"`
+}
+
+type SwapUsageReply struct {
+	Data []SwapIO `json:"data"`
+}
+
+type PressureUsageArgs struct {
+	StartTime   int64  `query:"start_time"`
+	EndTime     int64  `query:"end_time"`
+	Step        int64  `query:"step" validate:"gte=0"`
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
+	// Resource 为空时返回全部资源
+	Resource string `query:"resource" validate:"omitempty,oneof=cpu memory io"`
+}
+
+// PressureIO PSI 最近 10s 的阻塞时间占比(%)，some 为至少一个任务阻塞，full 为全部任务阻塞
+type PressureIO struct {
+	Timestamp int64   `json:"timestamp"`
+	Some      float64 `json:"some"`
+	Full      float64 `json:"full"`
+}
+
+type PressureUsageReply struct {
+	Resource string       `json:"resource"`
+	Data     []PressureIO `json:"data"`
+}
+
 type DiskInfo struct {
 	Device  string  `json:"device"`
 	Percent float64 `json:"percent"`
@@ -74,23 +216,64 @@ type DiskInfoReply struct {
 type DiskUsageArgs struct {
 	StartTime int64 `query:"start_time"`
 	EndTime   int64 `query:"end_time"`
+	// Step 降采样步长，单位 s，0 表示返回原始数据
+	Step int64 `query:"step" validate:"gte=0"`
+	// Aggregation 降采样聚合方式: avg / min / max / sum / last，默认 avg
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
 }
 
 type DiskUsageReply struct {
-	Device string   `json:"device"`
-	Mountpoint string `json:"mountpoint"`
-	Data   []DiskIO `json:"data"`
-	Total   uint64	`json:"total"`
-	Percent float64	`json:"percent"`
-	Used    uint64	`json:"used"`
+	Device     string   `json:"device"`
+	Mountpoint string   `json:"mountpoint"`
+	Data       []DiskIO `json:"data"`
+	Total      uint64   `json:"total"`
+	Percent    float64  `json:"percent"`
+	Used       uint64   `json:"used"`
 }
 
 type NetworkUsageArgs struct {
 	StartTime int64 `query:"start_time"`
 	EndTime   int64 `query:"end_time"`
+	// Step 降采样步长，单位 s，0 表示返回原始数据
+	Step int64 `query:"step" validate:"gte=0"`
+	// Aggregation 降采样聚合方式: avg / min / max / sum / last，默认 avg
+	Aggregation string `query:"agg" validate:"omitempty,oneof=avg min max sum last"`
 }
 
 type NetworkUsageReply struct {
 	Ethernet string  `json:"ethernet"`
 	Data     []NetIO `json:"data"`
 }
+
+type ProcessQueryArgs struct {
+	// StartTime 为空时只返回最近一次采集的进程
+	StartTime int64 `query:"start_time"`
+	EndTime   int64 `query:"end_time"`
+	PID       int32 `query:"pid"`
+	// SortBy 排序方式: cpu / memory，默认 cpu
+	SortBy string `query:"sort_by" validate:"omitempty,oneof=cpu memory"`
+	// Limit 每次采集返回的进程数，0 表示全部
+	Limit int `query:"limit" validate:"gte=0"`
+}
+
+type Process struct {
+	PID         int32   `json:"pid"`
+	Name        string  `json:"name"`
+	Cmdline     string  `json:"cmdline"`
+	User        string  `json:"user"`
+	CPUPercent  float64 `json:"cpu_percent"`
+	MemPercent  float64 `json:"mem_percent"`
+	RSS         uint64  `json:"rss"`
+	Threads     int32   `json:"threads"`
+	FDs         int32   `json:"fds"`
+	ContainerID string  `json:"container_id"`
+}
+
+type ProcessSnapshot struct {
+	Timestamp int64     `json:"timestamp"`
+	Processes []Process `json:"processes"`
+}
+
+type ProcessReply struct {
+	Data []ProcessSnapshot `json:"data"`
+}
@@ -0,0 +1,46 @@
+// Package schema
+// Date: 2024/5/7 11:00
+// Author: Amu
+// Description:
+package schema
+
+// CollectorStatus 采集项状态，时间为 unix 秒，未运行或未成功过为 0；errors / skipped 为启动以来的累计次数
+type CollectorStatus struct {
+	Name        string  `json:"name"`
+	Running     bool    `json:"running"`
+	LastRun     int64   `json:"last_run"`
+	LastSuccess int64   `json:"last_success"`
+	LastError   string  `json:"last_error"`
+	Duration    float64 `json:"duration"` // 最近一次耗时，单位 ms
+	Samples     int     `json:"samples"`  // 最近一次产生的样本或记录数
+	Errors      uint64  `json:"errors"`
+	Skipped     uint64  `json:"skipped"`
+}
+
+// CheckResult 依赖检查结果，status 为 ok / error
+type CheckResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Error   string  `json:"error,omitempty"`
+	Latency float64 `json:"latency"` // 单位 ms
+}
+
+type HealthReply struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type SystemStatusReply struct {
+	Runtime    string            `json:"runtime"`
+	Uptime     int64             `json:"uptime"` // 单位 s
+	Checks     []CheckResult     `json:"checks"`
+	Collectors []CollectorStatus `json:"collectors"`
+}
+
+// Settings 运行时可修改的采集设置，修改后立即生效
+type Settings struct {
+	Interval         int      `json:"interval" validate:"required,gte=5,lte=3600"` // 采集间隔，单位 s
+	Devices          []string `json:"devices" validate:"unique,dive,required,startswith=/dev/,max=128"`
+	Ethernets        []string `json:"ethernets" validate:"unique,dive,required,max=64"`
+	NotMonitorDocker bool     `json:"not_monitor_docker"` // 与配置 Task.NotMonitorDocker 一致，为 true 时采集容器指标
+}
@@ -18,6 +18,8 @@ import (
 
 type options struct {
 	ConfigFile string
+	// Overrides 命令行参数覆盖的配置项
+	Overrides map[string]string
 }
 
 type Option func(*options)
@@ -53,6 +55,7 @@ func Init(ctx context.Context, opts ...Option) (func(), error) {
 	for _, opt := range opts {
 		opt(&o)
 	}
+	o.applyOverrides()
 	injector, cleanFunc, err := BuildInjector(o.ConfigFile)
 	if err != nil {
 		slog.Error("build injector failed", "err", err)
@@ -70,7 +73,12 @@ func Init(ctx context.Context, opts ...Option) (func(), error) {
 	timedTask := injector.Task
 	go timedTask.Run()
 
+	// 审计日志归档，每小时检查一次
+	archiver := injector.Archiver
+	go archiver.Run(time.Hour)
+
 	return func() {
+		archiver.Stop()
 		timedTask.Stop()
 		httpServerCleanFunc()
 		cleanFunc()
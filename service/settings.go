@@ -0,0 +1,137 @@
+// Package service
+// Date: 2024/5/8 10:20
+// Author: Amu
+// Description:
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"gorm.io/gorm"
+)
+
+// settingsState 运行时设置，采集项并发读取，修改时整体替换
+type settingsState struct {
+	mu       sync.RWMutex
+	current  model.Setting
+	devices  map[string]struct{}
+	ethernet map[string]struct{}
+}
+
+func settingFromConfig(conf *Config) model.Setting {
+	return model.Setting{
+		ID:               model.SettingID,
+		Interval:         conf.Task.Interval,
+		Devices:          conf.Disk.Devices,
+		Ethernets:        conf.Ethernet.Names,
+		NotMonitorDocker: conf.Task.NotMonitorDocker,
+	}
+}
+
+// loadSettings 读取数据库中保存的设置，不存在时以配置文件初始化并保存；读取失败时使用配置文件；
+// 通过环境变量或命令行参数显式设置的项覆盖数据库中的值
+func loadSettings(db *database.DB, conf *Config) model.Setting {
+	setting := settingFromConfig(conf)
+	if db == nil {
+		return setting
+	}
+	var saved model.Setting
+	err := db.Take(&saved, model.SettingID).Error
+	switch {
+	case err == nil:
+		return overrideSettings(saved, setting, conf)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := db.Create(&setting).Error; err != nil {
+			slog.Warn("failed to save settings", "error", err)
+		}
+	default:
+		slog.Warn("failed to load settings, use config file", "error", err)
+	}
+	return setting
+}
+
+// overrideSettings 显式设置的项优先于数据库中保存的设置，仅在本次运行生效，不写回数据库
+func overrideSettings(saved, setting model.Setting, conf *Config) model.Setting {
+	var keys []string
+	if conf.Explicit("task.interval") {
+		saved.Interval = setting.Interval
+		keys = append(keys, "task.interval")
+	}
+	if conf.Explicit("task.notmonitordocker") {
+		saved.NotMonitorDocker = setting.NotMonitorDocker
+		keys = append(keys, "task.notmonitordocker")
+	}
+	if conf.Explicit("disk.devices") {
+		saved.Devices = setting.Devices
+		keys = append(keys, "disk.devices")
+	}
+	if conf.Explicit("ethernet.names") {
+		saved.Ethernets = setting.Ethernets
+		keys = append(keys, "ethernet.names")
+	}
+	if len(keys) > 0 {
+		slog.Warn("saved settings overridden by environment variables or flags, changes made through the settings API to these keys are lost on restart", "keys", keys)
+	}
+	return saved
+}
+
+func toSet(list []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(list))
+	for _, item := range list {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+// Settings 返回当前生效的设置
+func (a *TimedTask) Settings() model.Setting {
+	a.settings.mu.RLock()
+	defer a.settings.mu.RUnlock()
+	setting := a.settings.current
+	setting.Devices = append([]string(nil), setting.Devices...)
+	setting.Ethernets = append([]string(nil), setting.Ethernets...)
+	return setting
+}
+
+// ApplySettings 更新设置，下一次采集使用新的设备、网卡和容器开关，采集间隔变化时由 Run 重建 ticker
+func (a *TimedTask) ApplySettings(setting model.Setting) {
+	a.settings.mu.Lock()
+	defer a.settings.mu.Unlock()
+	prev := a.settings.current.Interval
+	a.settings.current = setting
+	a.settings.devices = toSet(setting.Devices)
+	a.settings.ethernet = toSet(setting.Ethernets)
+	if setting.Interval != prev && prev != 0 {
+		// 只保留最新的间隔，持有锁时先取出未处理的旧值，发送不会阻塞
+		select {
+		case <-a.resetCh:
+		default:
+		}
+		a.resetCh <- time.Duration(setting.Interval) * time.Second
+	}
+	slog.Info("settings applied", "interval", setting.Interval, "devices", setting.Devices, "ethernets", setting.Ethernets, "not_monitor_docker", setting.NotMonitorDocker)
+}
+
+func (a *TimedTask) deviceSet() map[string]struct{} {
+	a.settings.mu.RLock()
+	defer a.settings.mu.RUnlock()
+	return a.settings.devices
+}
+
+func (a *TimedTask) ethernetSet() map[string]struct{} {
+	a.settings.mu.RLock()
+	defer a.settings.mu.RUnlock()
+	return a.settings.ethernet
+}
+
+// collectContainers 沿用 NotMonitorDocker 的取值，为 true 时采集容器指标
+func (a *TimedTask) collectContainers() bool {
+	a.settings.mu.RLock()
+	defer a.settings.mu.RUnlock()
+	return a.settings.current.NotMonitorDocker
+}
@@ -0,0 +1,76 @@
+// Package api
+// Date: 2024/5/7 11:10
+// Author: Amu
+// Description:
+package api
+
+import (
+	"net/http"
+
+	"github.com/amuluze/amprobe/pkg/fiberx"
+	"github.com/amuluze/amprobe/pkg/validatex"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amprobe/service/system/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SystemAPI struct {
+	SystemService service.ISystemService
+}
+
+func NewSystemAPI(systemService service.ISystemService) *SystemAPI {
+	return &SystemAPI{
+		SystemService: systemService,
+	}
+}
+
+func (a *SystemAPI) Status(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	status, err := a.SystemService.Status(c)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, status)
+}
+
+// Healthz 检查失败时返回 503，供探针使用
+func (a *SystemAPI) Healthz(ctx *fiber.Ctx) error {
+	reply, ok := a.SystemService.Healthz(ctx.UserContext())
+	if !ok {
+		return fiberx.ReturnJson(ctx, http.StatusServiceUnavailable, reply)
+	}
+	return fiberx.Success(ctx, reply)
+}
+
+func (a *SystemAPI) Readyz(ctx *fiber.Ctx) error {
+	reply, ok := a.SystemService.Readyz(ctx.UserContext())
+	if !ok {
+		return fiberx.ReturnJson(ctx, http.StatusServiceUnavailable, reply)
+	}
+	return fiberx.Success(ctx, reply)
+}
+
+func (a *SystemAPI) SettingsGet(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+	settings, err := a.SystemService.SettingsGet(c)
+	if err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.Success(ctx, settings)
+}
+
+func (a *SystemAPI) SettingsUpdate(ctx *fiber.Ctx) error {
+	c := ctx.UserContext()
+
+	var args schema.Settings
+	if err := fiberx.ParseBody(ctx, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	if err := validatex.ValidateStruct(&args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	if err := a.SystemService.SettingsUpdate(c, &args); err != nil {
+		return fiberx.Failure(ctx, err)
+	}
+	return fiberx.NoContent(ctx)
+}
@@ -0,0 +1,19 @@
+// Package system
+// Date: 2024/5/7 11:10
+// Author: Amu
+// Description:
+package system
+
+import (
+	"github.com/google/wire"
+
+	"github.com/amuluze/amprobe/service/system/api"
+	"github.com/amuluze/amprobe/service/system/repository"
+	"github.com/amuluze/amprobe/service/system/service"
+)
+
+var Set = wire.NewSet(
+	api.Set,
+	service.Set,
+	repository.Set,
+)
@@ -0,0 +1,61 @@
+// Package repository
+// Date: 2024/5/7 11:10
+// Author: Amu
+// Description:
+package repository
+
+import (
+	"context"
+
+	"github.com/amuluze/amprobe/pkg/containerx"
+	"github.com/amuluze/amprobe/pkg/health"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amutool/database"
+	"github.com/google/wire"
+)
+
+var SystemRepoSet = wire.NewSet(NewSystemRepo, wire.Bind(new(ISystemRepo), new(*SystemRepo)))
+
+type ISystemRepo interface {
+	PingDB(ctx context.Context) error
+	PingRuntime(ctx context.Context) error
+	RuntimeName() string
+	Collectors() []health.CollectorStatus
+	SettingUpdate(ctx context.Context, setting *model.Setting) error
+}
+
+type SystemRepo struct {
+	DB       *database.DB
+	Runtime  containerx.Runtime
+	Registry *health.Registry
+}
+
+func NewSystemRepo(db *database.DB, rt containerx.Runtime, registry *health.Registry) *SystemRepo {
+	return &SystemRepo{DB: db, Runtime: rt, Registry: registry}
+}
+
+func (a *SystemRepo) PingDB(ctx context.Context) error {
+	sqlDB, err := a.DB.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PingRuntime 通过查询版本检查容器运行时是否可用
+func (a *SystemRepo) PingRuntime(ctx context.Context) error {
+	_, err := a.Runtime.Version(ctx)
+	return err
+}
+
+func (a *SystemRepo) RuntimeName() string {
+	return a.Runtime.Name()
+}
+
+func (a *SystemRepo) Collectors() []health.CollectorStatus {
+	return a.Registry.Collectors()
+}
+
+func (a *SystemRepo) SettingUpdate(ctx context.Context, setting *model.Setting) error {
+	return a.DB.WithContext(ctx).Save(setting).Error
+}
@@ -0,0 +1,13 @@
+// Package service
+// Date: 2024/5/7 11:10
+// Author: Amu
+// Description:
+package service
+
+import (
+	"github.com/google/wire"
+)
+
+var Set = wire.NewSet(
+	SystemServiceSet,
+)
@@ -0,0 +1,171 @@
+// Package service
+// Date: 2024/5/7 11:10
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/health"
+	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amprobe/service/schema"
+	"github.com/amuluze/amprobe/service/system/repository"
+	"github.com/amuluze/amutool/errors"
+	"github.com/google/wire"
+)
+
+var SystemServiceSet = wire.NewSet(NewSystemService, wire.Bind(new(ISystemService), new(*SystemService)))
+
+// 依赖检查的超时时间
+const checkTimeout = 3 * time.Second
+
+type ISystemService interface {
+	Status(ctx context.Context) (*schema.SystemStatusReply, error)
+	Healthz(ctx context.Context) (*schema.HealthReply, bool)
+	Readyz(ctx context.Context) (*schema.HealthReply, bool)
+	SettingsGet(ctx context.Context) (*schema.Settings, error)
+	SettingsUpdate(ctx context.Context, args *schema.Settings) error
+}
+
+// SettingsApplier 采集任务，设置保存后立即生效
+type SettingsApplier interface {
+	Settings() model.Setting
+	ApplySettings(setting model.Setting)
+}
+
+type SystemService struct {
+	SystemRepo repository.ISystemRepo
+	Applier    SettingsApplier
+	startTime  time.Time
+}
+
+func NewSystemService(systemRepo repository.ISystemRepo, applier SettingsApplier) *SystemService {
+	return &SystemService{SystemRepo: systemRepo, Applier: applier, startTime: time.Now()}
+}
+
+func (a *SystemService) dbCheck() health.Check {
+	return health.Check{Name: "db", Check: a.SystemRepo.PingDB}
+}
+
+func (a *SystemService) runtimeCheck() health.Check {
+	return health.Check{Name: a.SystemRepo.RuntimeName(), Check: a.SystemRepo.PingRuntime}
+}
+
+func (a *SystemService) check(ctx context.Context, checks ...health.Check) ([]schema.CheckResult, bool) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	results, ok := health.Run(ctx, checks)
+	list := make([]schema.CheckResult, 0, len(results))
+	for _, res := range results {
+		item := schema.CheckResult{
+			Name:    res.Name,
+			Status:  "ok",
+			Error:   res.Error,
+			Latency: float64(res.Latency.Microseconds()) / 1000,
+		}
+		if res.Error != "" {
+			item.Status = "error"
+		}
+		list = append(list, item)
+	}
+	return list, ok
+}
+
+// probeReply 探针接口无需认证，只返回各项状态，错误详情记录到日志，可通过 /api/v1/system/status 查看
+func probeReply(probe string, checks []schema.CheckResult, ok bool) *schema.HealthReply {
+	status := "ok"
+	if !ok {
+		status = "error"
+	}
+	for i := range checks {
+		if checks[i].Error != "" {
+			slog.Warn("probe check failed", "probe", probe, "check", checks[i].Name, "error", checks[i].Error)
+			checks[i].Error = ""
+		}
+	}
+	return &schema.HealthReply{Status: status, Checks: checks}
+}
+
+// Healthz 存活检查，只检查数据库，容器运行时不可用不影响采集主机指标
+func (a *SystemService) Healthz(ctx context.Context) (*schema.HealthReply, bool) {
+	checks, ok := a.check(ctx, a.dbCheck())
+	return probeReply("healthz", checks, ok), ok
+}
+
+// Readyz 就绪检查，数据库可用；采集容器指标(NotMonitorDocker 为 true)时容器运行时也需要可用
+func (a *SystemService) Readyz(ctx context.Context) (*schema.HealthReply, bool) {
+	checks := []health.Check{a.dbCheck()}
+	if a.Applier.Settings().NotMonitorDocker {
+		checks = append(checks, a.runtimeCheck())
+	}
+	results, ok := a.check(ctx, checks...)
+	return probeReply("readyz", results, ok), ok
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (a *SystemService) Status(ctx context.Context) (*schema.SystemStatusReply, error) {
+	checks, _ := a.check(ctx, a.dbCheck(), a.runtimeCheck())
+	collectors := a.SystemRepo.Collectors()
+	list := make([]schema.CollectorStatus, 0, len(collectors))
+	for _, c := range collectors {
+		list = append(list, schema.CollectorStatus{
+			Name:        c.Name,
+			Running:     c.Running,
+			LastRun:     unix(c.LastRun),
+			LastSuccess: unix(c.LastSuccess),
+			LastError:   c.LastError,
+			Duration:    float64(c.Duration.Microseconds()) / 1000,
+			Samples:     c.Samples,
+			Errors:      c.Errors,
+			Skipped:     c.Skipped,
+		})
+	}
+	return &schema.SystemStatusReply{
+		Runtime:    a.SystemRepo.RuntimeName(),
+		Uptime:     int64(time.Since(a.startTime).Seconds()),
+		Checks:     checks,
+		Collectors: list,
+	}, nil
+}
+
+func (a *SystemService) SettingsGet(ctx context.Context) (*schema.Settings, error) {
+	setting := a.Applier.Settings()
+	reply := &schema.Settings{
+		Interval:         setting.Interval,
+		Devices:          setting.Devices,
+		Ethernets:        setting.Ethernets,
+		NotMonitorDocker: setting.NotMonitorDocker,
+	}
+	if reply.Devices == nil {
+		reply.Devices = []string{}
+	}
+	if reply.Ethernets == nil {
+		reply.Ethernets = []string{}
+	}
+	return reply, nil
+}
+
+// SettingsUpdate 先保存到数据库，成功后通知采集任务
+func (a *SystemService) SettingsUpdate(ctx context.Context, args *schema.Settings) error {
+	setting := model.Setting{
+		ID:               model.SettingID,
+		Interval:         args.Interval,
+		Devices:          args.Devices,
+		Ethernets:        args.Ethernets,
+		NotMonitorDocker: args.NotMonitorDocker,
+	}
+	if err := a.SystemRepo.SettingUpdate(ctx, &setting); err != nil {
+		return errors.New400Error(err.Error())
+	}
+	a.Applier.ApplySettings(setting)
+	return nil
+}
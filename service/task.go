@@ -6,78 +6,160 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/patrickmn/go-cache"
 	"log/slog"
+	"reflect"
+	"sync"
 	"time"
 
+	"github.com/amuluze/amprobe/pkg/cgroup"
+	"github.com/amuluze/amprobe/pkg/containerx"
+	"github.com/amuluze/amprobe/pkg/exporter"
+	"github.com/amuluze/amprobe/pkg/health"
+	"github.com/amuluze/amprobe/pkg/metricstore"
 	"github.com/amuluze/amprobe/pkg/psutil"
+	"github.com/amuluze/amprobe/pkg/utils"
 	"github.com/amuluze/amprobe/service/model"
 	"github.com/amuluze/amutool/database"
-	"github.com/amuluze/amutool/docker"
 	"github.com/amuluze/amutool/timex"
+	"gorm.io/gorm"
 )
 
 type TimedTask struct {
 	db               *database.DB
-	manager          *docker.Manager
-	devices          map[string]struct{}
-	ethernet         map[string]struct{}
+	store            metricstore.MetricStore
+	exporters        exporter.Exporters
+	runtime          containerx.Runtime
+	settings         settingsState
 	ticker           timex.Ticker
+	resetCh          chan time.Duration
 	stopCh           chan struct{}
 	cache            *cache.Cache
-	notMonitorDocker bool
+	processes        *psutil.ProcessSampler
+	processTopN      int
+	containerWorkers int
+	containerTimeout time.Duration
+	health           *health.Registry
+	sensors          *psutil.SensorReader
+	cgroups          *cgroup.Collector
+	counters         counters
 }
 
-func NewTimedTask(conf *Config, db *database.DB) *TimedTask {
-	interval := conf.Task.Interval
-	tk := timex.NewTicker(time.Duration(interval) * time.Second)
-	manager, err := docker.NewManager()
-	if err != nil {
-		return nil
+// counters 上一次采集的累计计数及读取时间，每次采集与之比较，计算整个采集间隔内的平均速率
+type counters struct {
+	mu       sync.Mutex
+	swap     swapCounter
+	swapTime time.Time
+	disk     map[string]psutil.DiskIO
+	diskTime time.Time
+	net      map[string]psutil.NetIO
+	netTime  time.Time
+	// containers 各容器上一次读取的 cgroup 计数
+	containers map[string]containerCounter
+}
+
+// containerRunning 运行中容器的状态，containerd 的状态名称与 Docker 一致
+const containerRunning = "running"
+
+type containerCounter struct {
+	stats     cgroup.Stats
+	timestamp time.Time
+}
+
+// swapCounter swap 累计换入/换出字节数
+type swapCounter struct {
+	in  uint64
+	out uint64
+}
+
+func NewTimedTask(conf *Config, db *database.DB, store metricstore.MetricStore, exporters exporter.Exporters, rt containerx.Runtime, registry *health.Registry) *TimedTask {
+	topN := conf.Task.ProcessTopN
+	if topN == 0 {
+		topN = 10
 	}
 
-	dev := make(map[string]struct{})
-	for _, d := range conf.Disk.Devices {
-		dev[d] = struct{}{}
+	workers := conf.Task.ContainerWorkers
+	if workers <= 0 {
+		workers = 4
+	}
+	timeout := conf.Task.ContainerTimeout
+	if timeout <= 0 {
+		timeout = 10
 	}
 
-	eth := make(map[string]struct{})
-	for _, d := range conf.Ethernet.Names {
-		eth[d] = struct{}{}
+	// cgroup 不可用时(如非 Linux 或未挂载宿主机 /sys)使用容器运行时的 stats 接口
+	cgroups, err := cgroup.New(conf.Container.CgroupRoot)
+	if err != nil {
+		slog.Warn("cgroup collector unavailable, fall back to runtime stats", "error", err)
 	}
 
-	return &TimedTask{
-		devices:          dev,
-		ethernet:         eth,
-		ticker:           tk,
+	task := &TimedTask{
+		resetCh:          make(chan time.Duration, 1),
 		stopCh:           make(chan struct{}),
 		db:               db,
-		manager:          manager,
+		store:            store,
+		exporters:        exporters,
+		runtime:          rt,
 		cache:            cache.New(5*time.Minute, 60*time.Second),
-		notMonitorDocker: conf.Task.NotMonitorDocker,
+		processes:        psutil.NewProcessSampler(),
+		processTopN:      topN,
+		containerWorkers: workers,
+		containerTimeout: time.Duration(timeout) * time.Second,
+		health:           registry,
+		sensors:          psutil.NewSensorReader(""),
+		cgroups:          cgroups,
+		counters:         counters{containers: make(map[string]containerCounter)},
 	}
+	// 采集间隔、设备、网卡和容器开关以数据库中保存的设置为准，可通过 /api/v1/settings 修改
+	setting := loadSettings(db, conf)
+	task.ApplySettings(setting)
+	task.ticker = timex.NewTicker(time.Duration(setting.Interval) * time.Second)
+	return task
 }
 
+// Execute 各采集项并发执行，全部结束后写入采集自身指标；
+// 采集项上一周期未结束时本周期跳过该项，避免慢采集堆积
 func (a *TimedTask) Execute() {
 	timestamp := time.Now()
+	var wg sync.WaitGroup
+	run := func(name string, fn func(time.Time) (int, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.collect(name, timestamp, fn)
+		}()
+	}
 	// 处理数组指标
-	go a.host(timestamp)
-	go a.cpu(timestamp)
-	go a.memory(timestamp)
-	go a.disk()
-	go a.network()
+	run("host", a.host)
+	run("cpu", a.cpu)
+	run("memory", a.memory)
+	run("pressure", a.pressure)
+	run("disk", a.disk)
+	run("network", a.network)
+	run("connections", a.connections)
+	run("sensor", a.sensor)
+	if a.processTopN > 0 {
+		run("process", a.process)
+	}
 
-	if a.notMonitorDocker {
+	if a.collectContainers() {
 		// 处理 Docker 容器指标
-		go a.container(timestamp)
-		go func() {
-			a.docker(timestamp)
-			a.image(timestamp)
-		}()
+		run("container", a.container)
+		run("docker", func(timestamp time.Time) (int, error) {
+			versions, versionErr := a.docker(timestamp)
+			images, imageErr := a.image(timestamp)
+			return versions + images, errors.Join(versionErr, imageErr)
+		})
 	}
 
-	go a.clearOldRecord()
+	run("clean", a.clearOldRecord)
+	wg.Wait()
+
+	if _, err := a.write(a.selfMetrics(timestamp)); err != nil {
+		slog.Error("failed to write collector metrics", "error", err)
+	}
 }
 
 func (a *TimedTask) Run() {
@@ -85,6 +167,10 @@ func (a *TimedTask) Run() {
 		select {
 		case <-a.ticker.Chan():
 			go a.Execute()
+		case interval := <-a.resetCh:
+			a.ticker.Stop()
+			a.ticker = timex.NewTicker(interval)
+			slog.Info("task interval changed", "interval", interval)
 		case <-a.stopCh:
 			fmt.Println("task exit")
 			return
@@ -96,12 +182,12 @@ func (a *TimedTask) Stop() {
 	close(a.stopCh)
 }
 
-func (a *TimedTask) host(timestamp time.Time) {
-	info, _ := psutil.GetSystemInfo()
-	if err := a.db.Unscoped().Where("1 = 1").Delete(&model.Host{}).Error; err != nil {
-		slog.Error("Error deleting host table", "error", err)
+func (a *TimedTask) host(timestamp time.Time) (int, error) {
+	info, err := psutil.GetSystemInfo()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get system info: %w", err)
 	}
-	a.db.Model(&model.Host{}).Create(&model.Host{
+	if err := a.replace(&model.Host{}, &model.Host{
 		Timestamp:       timestamp,
 		Uptime:          info.Uptime,
 		Hostname:        info.Hostname,
@@ -110,97 +196,415 @@ func (a *TimedTask) host(timestamp time.Time) {
 		PlatformVersion: info.PlatformVersion,
 		KernelVersion:   info.KernelVersion,
 		KernelArch:      info.KernelArch,
-	})
+	}); err != nil {
+		return 0, err
+	}
+	return 1, nil
 }
 
-func (a *TimedTask) cpu(timestamp time.Time) {
-	cpuPercent, _ := psutil.GetCPUPercent()
-	a.db.Model(&model.CPU{}).Create(&model.CPU{
-		Timestamp:  timestamp,
-		CPUPercent: cpuPercent,
+func (a *TimedTask) cpu(timestamp time.Time) (int, error) {
+	cpuPercent, err := psutil.GetCPUPercent()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cpu percent: %w", err)
+	}
+	return a.write([]metricstore.Sample{
+		{Metric: model.MetricCPUPercent, Timestamp: timestamp, Value: cpuPercent},
 	})
 }
 
-func (a *TimedTask) memory(timestamp time.Time) {
-	memPercent, memTotal, memUsed, _ := psutil.GetMemInfo()
-	a.db.Model(&model.Memory{}).Create(&model.Memory{
-		Timestamp:  timestamp,
-		MemPercent: memPercent,
-		MemTotal:   float64(memTotal),
-		MemUsed:    float64(memUsed),
-	})
+func (a *TimedTask) memory(timestamp time.Time) (int, error) {
+	stat, err := psutil.GetMemoryStat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get memory stat: %w", err)
+	}
+	samples := []metricstore.Sample{
+		{Metric: model.MetricMemoryPercent, Timestamp: timestamp, Value: stat.UsedPercent},
+		{Metric: model.MetricMemoryTotal, Timestamp: timestamp, Value: float64(stat.Total)},
+		{Metric: model.MetricMemoryUsed, Timestamp: timestamp, Value: float64(stat.Used)},
+		{Metric: model.MetricMemoryAvailable, Timestamp: timestamp, Value: float64(stat.Available)},
+		{Metric: model.MetricMemoryBuffers, Timestamp: timestamp, Value: float64(stat.Buffers)},
+		{Metric: model.MetricMemoryCached, Timestamp: timestamp, Value: float64(stat.Cached)},
+		{Metric: model.MetricSwapTotal, Timestamp: timestamp, Value: float64(stat.SwapTotal)},
+		{Metric: model.MetricSwapUsed, Timestamp: timestamp, Value: float64(stat.SwapUsed)},
+	}
+
+	// swap 换入/换出为累计值，与上一次采集的差值计算速率
+	now := time.Now()
+	a.counters.mu.Lock()
+	prev, prevTime := a.counters.swap, a.counters.swapTime
+	if now.After(prevTime) {
+		a.counters.swap, a.counters.swapTime = swapCounter{in: stat.SwapIn, out: stat.SwapOut}, now
+	}
+	a.counters.mu.Unlock()
+	if elapsed := now.Sub(prevTime).Seconds(); !prevTime.IsZero() && elapsed > 0 {
+		in, inOK := psutil.CounterDelta(prev.in, stat.SwapIn, psutil.KernelCounterBits)
+		out, outOK := psutil.CounterDelta(prev.out, stat.SwapOut, psutil.KernelCounterBits)
+		if inOK && outOK {
+			samples = append(samples,
+				metricstore.Sample{Metric: model.MetricSwapIn, Timestamp: timestamp, Value: float64(in) / elapsed},
+				metricstore.Sample{Metric: model.MetricSwapOut, Timestamp: timestamp, Value: float64(out) / elapsed},
+			)
+		}
+	}
+	return a.write(samples)
+}
+
+// pressure 采集 PSI，内核未开启时不写入
+func (a *TimedTask) pressure(timestamp time.Time) (int, error) {
+	list, err := psutil.GetPressure()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pressure: %w", err)
+	}
+	var samples []metricstore.Sample
+	for _, p := range list {
+		labels := metricstore.Labels{model.LabelResource: p.Resource}
+		samples = append(samples, metricstore.Sample{Metric: model.MetricPressureSome, Labels: labels, Timestamp: timestamp, Value: p.Some.Avg10})
+		if p.HasFull {
+			samples = append(samples, metricstore.Sample{Metric: model.MetricPressureFull, Labels: labels, Timestamp: timestamp, Value: p.Full.Avg10})
+		}
+	}
+	return a.write(samples)
+}
+
+// disk 与上一次采集的计数比较，首次采集只记录计数
+func (a *TimedTask) disk(timestamp time.Time) (int, error) {
+	diskMap, err := psutil.GetDiskIO(a.deviceSet())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get disk io: %w", err)
+	}
+	now := time.Now()
+	a.counters.mu.Lock()
+	prevMap, prevTime := a.counters.disk, a.counters.diskTime
+	if now.After(prevTime) {
+		a.counters.disk, a.counters.diskTime = diskMap, now
+	}
+	a.counters.mu.Unlock()
+	if prevTime.IsZero() {
+		return 0, nil
+	}
+	elapsed := now.Sub(prevTime)
+	var samples []metricstore.Sample
+	for device, cur := range diskMap {
+		prev, ok := prevMap[device]
+		if !ok {
+			continue
+		}
+		stat, ok := psutil.DiskRate(prev, cur, elapsed)
+		if !ok {
+			slog.Warn("disk counters reset, skip this interval", "device", device)
+			continue
+		}
+		labels := metricstore.Labels{model.LabelDevice: device}
+		samples = append(samples,
+			metricstore.Sample{Metric: model.MetricDiskRead, Labels: labels, Timestamp: timestamp, Value: stat.ReadBytes},
+			metricstore.Sample{Metric: model.MetricDiskWrite, Labels: labels, Timestamp: timestamp, Value: stat.WriteBytes},
+			metricstore.Sample{Metric: model.MetricDiskReadIOPS, Labels: labels, Timestamp: timestamp, Value: stat.ReadIOPS},
+			metricstore.Sample{Metric: model.MetricDiskWriteIOPS, Labels: labels, Timestamp: timestamp, Value: stat.WriteIOPS},
+			metricstore.Sample{Metric: model.MetricDiskAwait, Labels: labels, Timestamp: timestamp, Value: stat.Await},
+			metricstore.Sample{Metric: model.MetricDiskQueueDepth, Labels: labels, Timestamp: timestamp, Value: stat.QueueDepth},
+			metricstore.Sample{Metric: model.MetricDiskUtil, Labels: labels, Timestamp: timestamp, Value: stat.Util},
+		)
+	}
+	return a.write(samples)
+}
+
+// network 与上一次采集的计数比较，首次采集只记录计数
+func (a *TimedTask) network(timestamp time.Time) (int, error) {
+	netMap, err := psutil.GetNetworkIO(a.ethernetSet())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get network io: %w", err)
+	}
+	now := time.Now()
+	a.counters.mu.Lock()
+	prevMap, prevTime := a.counters.net, a.counters.netTime
+	if now.After(prevTime) {
+		a.counters.net, a.counters.netTime = netMap, now
+	}
+	a.counters.mu.Unlock()
+	if prevTime.IsZero() {
+		return 0, nil
+	}
+	elapsed := now.Sub(prevTime)
+	var samples []metricstore.Sample
+	for eth, cur := range netMap {
+		prev, ok := prevMap[eth]
+		if !ok {
+			continue
+		}
+		stat, ok := psutil.NetRate(prev, cur, elapsed)
+		if !ok {
+			slog.Warn("network counters reset, skip this interval", "ethernet", eth)
+			continue
+		}
+		labels := metricstore.Labels{model.LabelEthernet: eth}
+		samples = append(samples,
+			metricstore.Sample{Metric: model.MetricNetSend, Labels: labels, Timestamp: timestamp, Value: stat.SendBytes},
+			metricstore.Sample{Metric: model.MetricNetRecv, Labels: labels, Timestamp: timestamp, Value: stat.RecvBytes},
+			metricstore.Sample{Metric: model.MetricNetPacketsRecv, Labels: labels, Timestamp: timestamp, Value: stat.PacketsRecv},
+			metricstore.Sample{Metric: model.MetricNetPacketsSent, Labels: labels, Timestamp: timestamp, Value: stat.PacketsSent},
+			metricstore.Sample{Metric: model.MetricNetErrIn, Labels: labels, Timestamp: timestamp, Value: stat.ErrIn},
+			metricstore.Sample{Metric: model.MetricNetErrOut, Labels: labels, Timestamp: timestamp, Value: stat.ErrOut},
+			metricstore.Sample{Metric: model.MetricNetDropIn, Labels: labels, Timestamp: timestamp, Value: stat.DropIn},
+			metricstore.Sample{Metric: model.MetricNetDropOut, Labels: labels, Timestamp: timestamp, Value: stat.DropOut},
+		)
+	}
+	return a.write(samples)
+}
+
+// connections 采集 TCP 各状态连接数
+func (a *TimedTask) connections(timestamp time.Time) (int, error) {
+	stat, err := psutil.GetConnections()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connections: %w", err)
+	}
+	samples := make([]metricstore.Sample, 0, len(stat.States))
+	for state, count := range stat.States {
+		samples = append(samples, metricstore.Sample{
+			Metric:    model.MetricTCPConnections,
+			Labels:    metricstore.Labels{model.LabelState: state},
+			Timestamp: timestamp,
+			Value:     float64(count),
+		})
+	}
+	return a.write(samples)
+}
+
+// write 将指标写入时序存储并推送到远端
+func (a *TimedTask) write(samples []metricstore.Sample) (int, error) {
+	if len(samples) == 0 {
+		return 0, nil
+	}
+	a.exporters.Export(samples)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := a.store.Write(ctx, samples); err != nil {
+		return 0, fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return len(samples), nil
 }
 
-func (a *TimedTask) disk() {
-	diskMap, _ := psutil.GetDiskIO(a.devices)
-	time.Sleep(1 * time.Second)
-	diskMapAfterSecond, _ := psutil.GetDiskIO(a.devices)
-	var diskInfos []model.Disk
-	for device, state := range diskMap {
-		i := diskMapAfterSecond[device]
-		disk := model.Disk{Device: device}
-		disk.DiskRead = float64(i.Read - state.Read)
-		disk.DiskWrite = float64(i.Write - state.Write)
-		diskInfos = append(diskInfos, disk)
-	}
-	// check diskInfos is empty
-	if len(diskInfos) == 0 {
-		slog.Error("diskInfos is empty")
-		return
-	}
-	a.db.Model(&model.Disk{}).Create(diskInfos)
+func (a *TimedTask) process(timestamp time.Time) (int, error) {
+	infos, err := a.processes.Top(a.processTopN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list processes: %w", err)
+	}
+	processes := make([]model.Process, 0, len(infos))
+	for _, info := range infos {
+		processes = append(processes, model.Process{
+			Timestamp:   timestamp,
+			PID:         info.PID,
+			Name:        utils.Truncate(info.Name, model.ProcessNameSize),
+			Cmdline:     utils.Truncate(info.Cmdline, model.ProcessCmdlineSize),
+			User:        utils.Truncate(info.User, model.ProcessUserSize),
+			CPUPercent:  info.CPUPercent,
+			MemPercent:  info.MemPercent,
+			RSS:         info.RSS,
+			Threads:     info.Threads,
+			FDs:         info.FDs,
+			ContainerID: info.ContainerID,
+		})
+	}
+	if len(processes) == 0 {
+		return 0, nil
+	}
+	if err := a.db.Create(&processes).Error; err != nil {
+		return 0, fmt.Errorf("failed to save processes: %w", err)
+	}
+	return len(processes), nil
+}
+
+// sensor 采集温度、风扇、电池，读数写入 s_sensor 并推送到远端
+func (a *TimedTask) sensor(timestamp time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stat, err := a.sensors.Read(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read sensors: %w", err)
+	}
+	var sensors []model.Sensor
+	var samples []metricstore.Sample
+	for _, t := range stat.Temperatures {
+		sensors = append(sensors, model.Sensor{Timestamp: timestamp, Type: model.SensorTemperature, Name: t.Name, Value: t.Value, High: t.High, Critical: t.Critical})
+		samples = append(samples, metricstore.Sample{Metric: model.MetricTemperature, Labels: metricstore.Labels{model.LabelSensor: t.Name}, Timestamp: timestamp, Value: t.Value})
+	}
+	for _, f := range stat.Fans {
+		sensors = append(sensors, model.Sensor{Timestamp: timestamp, Type: model.SensorFan, Name: f.Name, Value: f.RPM})
+		samples = append(samples, metricstore.Sample{Metric: model.MetricFanSpeed, Labels: metricstore.Labels{model.LabelSensor: f.Name}, Timestamp: timestamp, Value: f.RPM})
+	}
+	for _, b := range stat.Batteries {
+		sensors = append(sensors, model.Sensor{Timestamp: timestamp, Type: model.SensorBattery, Name: b.Name, Value: b.Capacity, Status: b.Status})
+		samples = append(samples, metricstore.Sample{Metric: model.MetricBatteryCapacity, Labels: metricstore.Labels{model.LabelSensor: b.Name}, Timestamp: timestamp, Value: b.Capacity})
+	}
+	if len(sensors) == 0 {
+		return 0, nil
+	}
+	a.exporters.Export(samples)
+	if err := a.db.Create(&sensors).Error; err != nil {
+		return 0, fmt.Errorf("failed to save sensors: %w", err)
+	}
+	return len(sensors), nil
+}
+
+// containerUsage 容器资源使用，各 ok 字段表示对应指标本次是否可用
+type containerUsage struct {
+	cpuPercent float64
+	cpuOK      bool
+	memPercent float64
+	memUsage   float64
+	memLimit   float64
+	ioRead     float64
+	ioWrite    float64
+	ioOK       bool
+	pids       float64
+	pidsOK     bool
+}
+
+// containerUsage 优先读取 cgroup，CPU 和 IO 速率与上一次读取比较，容器首次出现时不可用；
+// cgroup 读取失败时回退到容器运行时的 stats 接口
+func (a *TimedTask) containerUsage(ctx context.Context, id string, memTotal float64) (containerUsage, error) {
+	var usage containerUsage
+	if a.cgroups != nil {
+		stats, err := a.cgroups.Stats(id)
+		if err == nil {
+			now := time.Now()
+			a.counters.mu.Lock()
+			prev, ok := a.counters.containers[id]
+			a.counters.containers[id] = containerCounter{stats: stats, timestamp: now}
+			a.counters.mu.Unlock()
+
+			usage.memUsage = float64(stats.MemoryUsage)
+			usage.memLimit = float64(stats.MemoryLimit)
+			if usage.memLimit == 0 {
+				// 未限制内存时与 docker stats 一致，使用宿主机内存总量
+				usage.memLimit = memTotal
+			}
+			if usage.memLimit > 0 {
+				usage.memPercent = usage.memUsage / usage.memLimit * 100
+			}
+			usage.pids, usage.pidsOK = float64(stats.PIDs), true
+			if elapsed := now.Sub(prev.timestamp); ok && elapsed > 0 {
+				usage.cpuPercent, usage.cpuOK = cgroup.CPUPercent(prev.stats, stats, elapsed), true
+				read, readOK := psutil.CounterDelta(prev.stats.IOReadBytes, stats.IOReadBytes, 64)
+				write, writeOK := psutil.CounterDelta(prev.stats.IOWriteBytes, stats.IOWriteBytes, 64)
+				if readOK && writeOK {
+					usage.ioRead = float64(read) / elapsed.Seconds()
+					usage.ioWrite = float64(write) / elapsed.Seconds()
+					usage.ioOK = true
+				}
+			}
+			return usage, nil
+		}
+		slog.Debug("failed to read container cgroup, fall back to runtime stats", "container", id, "error", err)
+	}
+
+	stats, err := a.runtime.ContainerStats(ctx, id)
+	if err != nil {
+		return usage, err
+	}
+	usage.cpuPercent, usage.cpuOK = stats.CPUPercent, true
+	usage.memPercent, usage.memUsage, usage.memLimit = stats.MemPercent, stats.MemUsage, stats.MemLimit
+	return usage, nil
 }
 
-func (a *TimedTask) network() {
-	netMap, _ := psutil.GetNetworkIO(a.ethernet)
-	time.Sleep(1 * time.Second)
-	netMapAfterSecond, _ := psutil.GetNetworkIO(a.ethernet)
-	var netInfos []model.Net
-	for eth, info := range netMap {
-		i := netMapAfterSecond[eth]
-		net := model.Net{}
-		net.Ethernet = eth
-		net.NetSend = float64(i.Send - info.Send)
-		net.NetRecv = float64(i.Recv - info.Recv)
-		netInfos = append(netInfos, net)
-	}
-	a.db.Model(&model.Net{}).Create(netInfos)
+// containerUsages 使用固定数量的 worker 并发读取各容器资源使用，单个容器超时不影响其他容器；
+// 未运行的容器没有资源使用，不读取
+func (a *TimedTask) containerUsages(ctx context.Context, cs []containerx.Container, memTotal float64) ([]containerUsage, []error) {
+	usages := make([]containerUsage, len(cs))
+	errs := make([]error, len(cs))
+	if a.cgroups != nil {
+		var running []string
+		for i := range cs {
+			if cs[i].State == containerRunning {
+				running = append(running, cs[i].ID)
+			}
+		}
+		a.cgroups.SetContainers(running)
+	}
+	sem := make(chan struct{}, a.containerWorkers)
+	var wg sync.WaitGroup
+	for i := range cs {
+		if cs[i].State != containerRunning {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			cctx, cancel := context.WithTimeout(ctx, a.containerTimeout)
+			defer cancel()
+			usages[i], errs[i] = a.containerUsage(cctx, cs[i].ID, memTotal)
+		}(i)
+	}
+	wg.Wait()
+	return usages, errs
 }
 
-func (a *TimedTask) container(timestamp time.Time) {
+func (a *TimedTask) container(timestamp time.Time) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	cs, err := a.manager.ListContainer(ctx)
+	cs, err := a.runtime.ListContainers(ctx)
 	if err != nil {
-		slog.Error("failed to list containers", "error", err)
-		return
+		return 0, fmt.Errorf("failed to list containers: %w", err)
+	}
+	var memTotal float64
+	if _, total, _, err := psutil.GetMemInfo(); err == nil {
+		memTotal = float64(total)
 	}
+	usages, errs := a.containerUsages(ctx, cs, memTotal)
+	var failed int
+	var firstErr error
 	var containers []model.Container
-	for _, info := range cs {
+	var samples []metricstore.Sample
+	seen := make(map[string]struct{}, len(cs))
+	for i, info := range cs {
 		var d model.Container
 		d.Timestamp = timestamp
-		d.ContainerID = info.ID[:6]
+		d.ContainerID = containerx.ShortID(info.ID)
 		d.Name = info.Name
 		d.State = info.State
 		d.Image = info.Image
 		d.Uptime = info.Uptime
 		d.IP = info.IP
-
-		cpuPercent, err := a.manager.GetContainerCPU(ctx, info.ID[:6])
-		if err != nil {
-			slog.Error("failed to get container cpu", "error", err)
+		if info.State != containerRunning {
+			containers = append(containers, d)
+			continue
 		}
-		d.CPUPercent = cpuPercent
+		seen[info.ID] = struct{}{}
 
-		memPercent, used, limit, err := a.manager.GetContainerMem(ctx, info.ID[:6])
-		if err != nil {
-			slog.Error("failed to get container mem", "error", err)
+		usage := usages[i]
+		if errs[i] != nil {
+			slog.Debug("failed to get container stats", "container", d.ContainerID, "runtime", a.runtime.Name(), "error", errs[i])
+			failed++
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+		}
+		d.CPUPercent = usage.cpuPercent
+		d.MemPercent = usage.memPercent
+		d.MemUsage = usage.memUsage
+		d.MemLimit = usage.memLimit
+		labels := metricstore.Labels{model.LabelContainer: d.ContainerID, model.LabelName: d.Name, model.LabelImage: d.Image}
+		samples = append(samples,
+			metricstore.Sample{Metric: model.MetricContainerMemoryPercent, Labels: labels, Timestamp: timestamp, Value: usage.memPercent},
+			metricstore.Sample{Metric: model.MetricContainerMemoryUsage, Labels: labels, Timestamp: timestamp, Value: usage.memUsage},
+			metricstore.Sample{Metric: model.MetricContainerMemoryLimit, Labels: labels, Timestamp: timestamp, Value: usage.memLimit},
+		)
+		if usage.cpuOK {
+			samples = append(samples, metricstore.Sample{Metric: model.MetricContainerCPUPercent, Labels: labels, Timestamp: timestamp, Value: usage.cpuPercent})
+		}
+		if usage.ioOK {
+			samples = append(samples,
+				metricstore.Sample{Metric: model.MetricContainerIORead, Labels: labels, Timestamp: timestamp, Value: usage.ioRead},
+				metricstore.Sample{Metric: model.MetricContainerIOWrite, Labels: labels, Timestamp: timestamp, Value: usage.ioWrite},
+			)
+		}
+		if usage.pidsOK {
+			samples = append(samples, metricstore.Sample{Metric: model.MetricContainerPIDs, Labels: labels, Timestamp: timestamp, Value: usage.pids})
 		}
-		d.MemPercent = memPercent
-
-		d.MemUsage = used
-		d.MemLimit = limit
 		if _, ok := a.cache.Get(info.Image); !ok {
 			a.cache.Set(info.Image, 1, 2*time.Minute)
 		} else {
@@ -209,25 +613,34 @@ func (a *TimedTask) container(timestamp time.Time) {
 		}
 		containers = append(containers, d)
 	}
-	if err := a.db.Unscoped().Where("1 = 1").Delete(&model.Container{}).Error; err != nil {
-		slog.Error("failed to delete container", "error", err)
+	// 清理已删除或已停止容器的计数
+	a.counters.mu.Lock()
+	for id := range a.counters.containers {
+		if _, ok := seen[id]; !ok {
+			delete(a.counters.containers, id)
+		}
+	}
+	a.counters.mu.Unlock()
+	// 容器指标只推送到远端，本地仅保留最新快照
+	a.exporters.Export(samples)
+	if err := a.replace(&model.Container{}, &containers); err != nil {
+		return 0, fmt.Errorf("failed to replace container: %w", err)
 	}
-	a.db.Model(&model.Container{}).Create(&containers)
+	if failed > 0 {
+		return len(samples), fmt.Errorf("failed to get stats of %d/%d containers: %w", failed, len(cs), firstErr)
+	}
+	return len(samples), nil
 }
 
-func (a *TimedTask) docker(timestamp time.Time) {
+func (a *TimedTask) docker(timestamp time.Time) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	dockerVersion, err := a.manager.Version(ctx)
+	dockerVersion, err := a.runtime.Version(ctx)
 	if err != nil {
-		slog.Error("failed to get docker version", "error", err)
-		return
-	}
-	if err := a.db.Unscoped().Where("1 = 1").Delete(&model.Docker{}).Error; err != nil {
-		slog.Error("failed to delete docker container", "error", err)
+		return 0, fmt.Errorf("failed to get docker version: %w", err)
 	}
-	a.db.Model(&model.Docker{}).Create(&model.Docker{
+	if err := a.replace(&model.Docker{}, &model.Docker{
 		Timestamp:     timestamp,
 		DockerVersion: dockerVersion.DockerVersion,
 		APIVersion:    dockerVersion.APIVersion,
@@ -236,17 +649,19 @@ func (a *TimedTask) docker(timestamp time.Time) {
 		GoVersion:     dockerVersion.GoVersion,
 		Os:            dockerVersion.OS,
 		Arch:          dockerVersion.Arch,
-	})
+	}); err != nil {
+		return 0, fmt.Errorf("failed to replace docker: %w", err)
+	}
+	return 1, nil
 }
 
-func (a *TimedTask) image(timestamp time.Time) {
+func (a *TimedTask) image(timestamp time.Time) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	images, err := a.manager.ListImage(ctx)
+	images, err := a.runtime.ListImages(ctx)
 	if err != nil {
-		slog.Error("failed to get version", "error", err)
-		return
+		return 0, fmt.Errorf("failed to list images: %w", err)
 	}
 	var list model.Images
 	duplicateImage := make(map[string]struct{})
@@ -274,13 +689,27 @@ func (a *TimedTask) image(timestamp time.Time) {
 		})
 		a.cache.Delete(im.Name + ":" + im.Tag)
 	}
-	if err := a.db.Unscoped().Where("1 = 1").Delete(&model.Image{}).Error; err != nil {
-		slog.Error("failed to delete image", "error", err)
+	if err := a.replace(&model.Image{}, &list); err != nil {
+		return 0, fmt.Errorf("failed to replace image: %w", err)
 	}
-	a.db.Model(&model.Image{}).Create(&list)
+	return len(list), nil
+}
+
+// replace 在同一事务中清空快照表并写入最新数据
+func (a *TimedTask) replace(table interface{}, rows interface{}) error {
+	return a.db.RunInTransaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(table).Error; err != nil {
+			return err
+		}
+		// 空列表只清空，不写入
+		if v := reflect.Indirect(reflect.ValueOf(rows)); v.Kind() == reflect.Slice && v.Len() == 0 {
+			return nil
+		}
+		return tx.Create(rows).Error
+	})
 }
 
-func (a *TimedTask) clearOldRecord() {
+func (a *TimedTask) clearOldRecord(time.Time) (int, error) {
 	a.db.Where("timestamp < ?", time.Now().Add(-time.Minute*5)).Delete(&model.Host{})
 	a.db.Where("timestamp < ?", time.Now().Add(-time.Minute*5)).Delete(&model.Container{})
 	a.db.Where("timestamp < ?", time.Now().Add(-time.Minute*5)).Delete(&model.Image{})
@@ -289,4 +718,7 @@ func (a *TimedTask) clearOldRecord() {
 	a.db.Where("timestamp < ?", time.Now().Add(-time.Hour*24*2)).Delete(&model.Memory{})
 	a.db.Where("created_at < ?", time.Now().Add(-time.Hour*24*5)).Delete(&model.Disk{})
 	a.db.Where("created_at < ?", time.Now().Add(-time.Hour*24*5)).Delete(&model.Net{})
+	a.db.Where("timestamp < ?", time.Now().Add(-time.Hour*24*5)).Delete(&model.MetricSample{})
+	a.db.Where("timestamp < ?", time.Now().Add(-time.Hour*24*2)).Delete(&model.Process{})
+	return 0, a.db.Where("timestamp < ?", time.Now().Add(-time.Hour*24*5)).Delete(&model.Sensor{}).Error
 }
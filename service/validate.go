@@ -0,0 +1,267 @@
+// Package service
+// Date: 2024/5/10 10:00
+// Author: Amu
+// Description:
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amuluze/amprobe/pkg/cgroup"
+	"github.com/amuluze/amprobe/pkg/configx"
+	"github.com/amuluze/amprobe/pkg/containerx"
+	"github.com/amuluze/amprobe/pkg/psutil"
+	authRepository "github.com/amuluze/amprobe/service/auth/repository"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// runtimeTimeout 校验时连接容器运行时的超时时间
+const runtimeTimeout = 5 * time.Second
+
+// ValidateConfig 校验生效配置的类型、取值、磁盘和网卡是否存在、容器运行时是否可达以及签名密钥强度；
+// 返回的 error 表示配置文件无法读取，配置本身的问题记录在 Report 中
+func ValidateConfig(ctx context.Context, opts ...Option) (*configx.Report, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.applyOverrides()
+	if err := readConfig(o.ConfigFile); err != nil {
+		return nil, err
+	}
+
+	report := &configx.Report{}
+	config := &Config{}
+	if err := viper.Unmarshal(config, configx.DecodeHook(), configx.Strict()); err != nil {
+		decodeIssues(report, err)
+		// 只有未知配置项时继续校验其余配置，类型错误时无法得到完整配置
+		config = &Config{}
+		if err := viper.Unmarshal(config, configx.DecodeHook()); err != nil {
+			return report, nil
+		}
+	}
+
+	validateValues(config, report)
+	validateDevices(config, report)
+	validateSecrets(config, report)
+	validateRuntime(ctx, config, report)
+	return report, nil
+}
+
+// decodeIssues mapstructure 的错误包含多条，逐条记录
+func decodeIssues(report *configx.Report, err error) {
+	var decodeErr *mapstructure.Error
+	if !errors.As(err, &decodeErr) {
+		report.Errorf("config", "%v", err)
+		return
+	}
+	for _, msg := range decodeErr.Errors {
+		report.Errorf("config", "%s", msg)
+	}
+}
+
+func oneOf(report *configx.Report, key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	report.Errorf(key, "invalid value %q, must be one of: %s", value, strings.Join(allowed, ", "))
+}
+
+func validateValues(config *Config, report *configx.Report) {
+	if config.Fiber.Port <= 0 || config.Fiber.Port > 65535 {
+		report.Errorf("Fiber.Port", "invalid port %d", config.Fiber.Port)
+	}
+	if config.Fiber.ShutdownTimeout < 0 {
+		report.Errorf("Fiber.ShutdownTimeout", "must not be negative")
+	}
+
+	oneOf(report, "Gorm.DBType", config.Gorm.DBType, "sqlite", "postgres", "mysql")
+	if config.DB.DBName == "" {
+		report.Errorf("DB.DBName", "is required")
+	}
+	if config.Gorm.DBType != "sqlite" && config.DB.Host == "" {
+		report.Errorf("DB.Host", "is required for %s", config.Gorm.DBType)
+	}
+
+	// 与 PUT /api/v1/settings 的取值范围一致
+	if config.Task.Interval < 5 || config.Task.Interval > 3600 {
+		report.Errorf("Task.Interval", "must be between 5 and 3600 seconds, got %d", config.Task.Interval)
+	}
+	if config.Task.ContainerWorkers < 0 {
+		report.Errorf("Task.ContainerWorkers", "must not be negative")
+	}
+	if config.Task.ContainerTimeout < 0 {
+		report.Errorf("Task.ContainerTimeout", "must not be negative")
+	}
+	if config.Task.ContainerTimeout > config.Task.Interval {
+		report.Warnf("Task.ContainerTimeout", "is longer than Task.Interval, slow containers will skip collections")
+	}
+
+	oneOf(report, "Logger.Level", config.Logger.Level, "debug", "info", "warn", "error")
+
+	oneOf(report, "Auth.SigningMethod", config.Auth.SigningMethod, "", "HS256", "HS384", "HS512")
+	oneOf(report, "Auth.Provider", config.Auth.Provider, "", authRepository.ProviderLocal, authRepository.ProviderLDAP, authRepository.ProviderLocalLDAP)
+	oneOf(report, "Auth.Store", config.Auth.Store, "", "memory", "db", "redis")
+	if config.Auth.Expired <= 0 {
+		report.Errorf("Auth.Expired", "must be positive")
+	}
+	if config.Auth.RefreshExpired < config.Auth.Expired {
+		report.Errorf("Auth.RefreshExpired", "must not be shorter than Auth.Expired")
+	}
+	if config.Auth.Store == "redis" && config.Redis.Addr == "" {
+		report.Errorf("Redis.Addr", "is required when Auth.Store is redis")
+	}
+	if config.Auth.Provider == authRepository.ProviderLDAP || config.Auth.Provider == authRepository.ProviderLocalLDAP {
+		if u, err := url.Parse(config.LDAP.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+			report.Errorf("LDAP.URL", "must be ldap://host:port or ldaps://host:port")
+		}
+		if config.LDAP.BaseDN == "" {
+			report.Errorf("LDAP.BaseDN", "is required")
+		}
+	}
+	if config.OIDC.Enable {
+		required := []struct{ key, value string }{
+			{"OIDC.Issuer", config.OIDC.Issuer},
+			{"OIDC.ClientID", config.OIDC.ClientID},
+			{"OIDC.RedirectURL", config.OIDC.RedirectURL},
+		}
+		for _, r := range required {
+			if r.value == "" {
+				report.Errorf(r.key, "is required when OIDC is enabled")
+			}
+		}
+	}
+
+	oneOf(report, "Audit.Sink", config.Audit.Sink, "", "file", "syslog")
+	if config.Audit.Sink == "file" && config.Audit.SinkFile == "" {
+		report.Errorf("Audit.SinkFile", "is required when Audit.Sink is file")
+	}
+	if config.Audit.RetentionDays < 0 {
+		report.Errorf("Audit.RetentionDays", "must not be negative")
+	}
+	if config.Audit.RetentionDays > 0 && config.Audit.ArchiveDir == "" {
+		report.Errorf("Audit.ArchiveDir", "is required when Audit.RetentionDays is set")
+	}
+
+	oneOf(report, "Metric.Store", config.Metric.Store, "", "db", "tsdb")
+	if config.Metric.Store == "tsdb" {
+		if config.Metric.Dir == "" {
+			report.Errorf("Metric.Dir", "is required when Metric.Store is tsdb")
+		}
+		if config.Metric.RetentionDays <= 0 {
+			report.Errorf("Metric.RetentionDays", "must be positive")
+		}
+	}
+
+	oneOf(report, "Container.Runtime", config.Container.Runtime, "", "auto", containerx.RuntimeDocker, containerx.RuntimePodman, containerx.RuntimeContainerd)
+
+	for i, e := range config.Exporters {
+		key := fmt.Sprintf("Exporters[%d]", i)
+		oneOf(report, key+".Type", e.Type, "prometheus", "influxdb", "otlp-http", "otlp-grpc")
+		if e.URL == "" {
+			report.Errorf(key+".URL", "is required")
+			continue
+		}
+		if e.Type != "otlp-grpc" {
+			if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				report.Errorf(key+".URL", "invalid URL %q", e.URL)
+			}
+		}
+	}
+
+	if config.InitData.Enable {
+		if _, err := os.Stat(config.InitData.InitConfigFile); err != nil {
+			report.Errorf("InitData.InitConfigFile", "%v", err)
+		}
+	}
+}
+
+// validateDevices 配置的磁盘和网卡需要能在本机读取到 IO 计数，否则对应图表为空
+func validateDevices(config *Config, report *configx.Report) {
+	if len(config.Disk.Devices) == 0 {
+		report.Warnf("Disk.Devices", "no disk configured, disk IO will not be collected")
+	}
+	if devices, err := psutil.DiskDevices(); err != nil {
+		report.Warnf("Disk.Devices", "failed to list disk devices: %v", err)
+	} else {
+		missing(report, "Disk.Devices", config.Disk.Devices, devices, psutil.WholeDisks(devices))
+	}
+
+	if len(config.Ethernet.Names) == 0 {
+		report.Warnf("Ethernet.Names", "no network interface configured, network IO will not be collected")
+	}
+	if names, err := psutil.NetInterfaces(); err != nil {
+		report.Warnf("Ethernet.Names", "failed to list network interfaces: %v", err)
+	} else {
+		missing(report, "Ethernet.Names", config.Ethernet.Names, names, psutil.PhysicalInterfaces(names))
+	}
+}
+
+func missing(report *configx.Report, key string, configured, available, suggested []string) {
+	set := toSet(available)
+	for _, name := range configured {
+		if _, ok := set[name]; !ok {
+			report.Errorf(key, "%s not found on this host, available: %s", name, strings.Join(suggested, ", "))
+		}
+	}
+}
+
+// validateSecrets 启用认证时签名密钥强度不足为错误；未启用时密钥仍用于审计日志哈希链，记为警告
+func validateSecrets(config *Config, report *configx.Report) {
+	minLen := 64
+	switch config.Auth.SigningMethod {
+	case "HS256":
+		minLen = 32
+	case "HS384":
+		minLen = 48
+	}
+	if err := configx.CheckSecret(config.Auth.SigningKey, minLen); err != nil {
+		if config.Auth.Enable {
+			report.Errorf("Auth.SigningKey", "signing key %v", err)
+		} else {
+			report.Warnf("Auth.SigningKey", "signing key %v", err)
+		}
+	}
+	if config.Audit.ChainKey != "" {
+		if err := configx.CheckSecret(config.Audit.ChainKey, 32); err != nil {
+			report.Warnf("Audit.ChainKey", "chain key %v", err)
+		}
+	}
+}
+
+// validateRuntime 采集容器指标时容器运行时不可达为错误，否则只影响容器管理页面，记为警告
+func validateRuntime(ctx context.Context, config *Config, report *configx.Report) {
+	fail := report.Warnf
+	if config.Task.NotMonitorDocker {
+		fail = report.Errorf
+	}
+	rt, err := containerx.New(containerx.Options{
+		Runtime:   config.Container.Runtime,
+		Endpoint:  config.Container.Endpoint,
+		Namespace: config.Container.Namespace,
+	})
+	if err != nil {
+		fail("Container.Runtime", "%v", err)
+		return
+	}
+	defer rt.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, runtimeTimeout)
+	defer cancel()
+	if _, err := rt.Version(ctx); err != nil {
+		fail("Container.Endpoint", "%s is not reachable: %v", rt.Name(), err)
+	}
+
+	if _, err := cgroup.New(config.Container.CgroupRoot); err != nil {
+		report.Warnf("Container.CgroupRoot", "cgroup unavailable, container stats fall back to %s: %v", rt.Name(), err)
+	}
+}
@@ -7,30 +7,27 @@ package service
 import (
 	"bufio"
 	"context"
-	"github.com/amuluze/amutool/docker"
+	"github.com/amuluze/amprobe/pkg/containerx"
 	"github.com/gofiber/contrib/websocket"
 	"log/slog"
 	"time"
 )
 
 type LoggerHandler struct {
-	manager *docker.Manager
+	runtime containerx.Runtime
 }
 
-func NewLoggerHandler() *LoggerHandler {
-	manager, err := docker.NewManager()
-	if err != nil {
-		return nil
-	}
-	return &LoggerHandler{manager: manager}
+func NewLoggerHandler(rt containerx.Runtime) *LoggerHandler {
+	return &LoggerHandler{runtime: rt}
 }
 
 func (l *LoggerHandler) Handler(c *websocket.Conn) {
 	containerId := c.Params("id")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	reader, err := l.manager.ContainerLogs(ctx, containerId)
+	reader, err := l.runtime.ContainerLogs(ctx, containerId)
 	if err != nil {
+		slog.Error("failed to get container logs", "runtime", l.runtime.Name(), "error", err)
 		return
 	}
 	scanner := bufio.NewScanner(reader)
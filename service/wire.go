@@ -4,11 +4,14 @@
 package service
 
 import (
+	"github.com/amuluze/amprobe/pkg/health"
 	"github.com/amuluze/amprobe/service/audit"
 	"github.com/amuluze/amprobe/service/auth"
 	"github.com/amuluze/amprobe/service/container"
 	"github.com/amuluze/amprobe/service/host"
 	"github.com/amuluze/amprobe/service/model"
+	"github.com/amuluze/amprobe/service/system"
+	systemService "github.com/amuluze/amprobe/service/system/service"
 	"github.com/google/wire"
 )
 
@@ -17,17 +20,27 @@ func BuildInjector(configFile string) (*Injector, func(), error) {
 		NewConfig,
 		NewLogger,
 		NewDB,
+		InitAuditChain,
+		InitAuditArchiver,
 		InitAuthStore,
+		InitMetricStore,
+		InitExporters,
+		InitRuntime,
+		health.NewRegistry,
 		InitAuth,
+		InitAuthOptions,
+		InitAuthRepoOptions,
 		container.Set,
 		host.Set,
 		model.Set,
 		auth.Set,
 		audit.Set,
+		system.Set,
 		NewLoggerHandler,
 		RouterSet,
 		NewFiberApp,
 		NewTimedTask,
+		wire.Bind(new(systemService.SettingsApplier), new(*TimedTask)),
 		PrepareSet,
 		InjectorSet,
 	)
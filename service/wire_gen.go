@@ -7,6 +7,7 @@
 package service
 
 import (
+	"github.com/amuluze/amprobe/pkg/health"
 	api4 "github.com/amuluze/amprobe/service/audit/api"
 	repository4 "github.com/amuluze/amprobe/service/audit/repository"
 	service4 "github.com/amuluze/amprobe/service/audit/service"
@@ -20,6 +21,9 @@ import (
 	repository2 "github.com/amuluze/amprobe/service/host/repository"
 	service2 "github.com/amuluze/amprobe/service/host/service"
 	"github.com/amuluze/amprobe/service/model"
+	api5 "github.com/amuluze/amprobe/service/system/api"
+	repository5 "github.com/amuluze/amprobe/service/system/repository"
+	service5 "github.com/amuluze/amprobe/service/system/service"
 )
 
 // Injectors from wire.go:
@@ -29,34 +33,71 @@ func BuildInjector(configFile string) (*Injector, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	store, cleanup, err := InitAuthStore(config)
+	models := model.NewModels()
+	db, err := NewDB(config, models)
 	if err != nil {
 		return nil, nil, err
 	}
-	models := model.NewModels()
-	db, err := NewDB(config, models)
+	storer, cleanup, err := InitAuthStore(config, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, cleanup2, err := InitAuditChain(config, db)
 	if err != nil {
 		cleanup()
 		return nil, nil, err
 	}
-	auther, cleanup2, err := InitAuth(config, store, db)
+	auther, cleanup3, err := InitAuth(config, storer, db, chain)
 	if err != nil {
+		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
-	containerRepo := repository.NewContainerRepo(db)
+	runtime, cleanup4, err := InitRuntime(config)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	containerRepo := repository.NewContainerRepo(db, runtime)
 	containerService := service.NewContainerService(containerRepo)
 	containerAPI := api.NewContainerAPI(containerService)
-	hostRepo := repository2.NewHostRepo(db)
+	metricStore, cleanup5, err := InitMetricStore(config, db)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	hostRepo := repository2.NewHostRepo(db, metricStore)
 	hostService := service2.NewHostService(hostRepo)
 	hostAPI := api2.NewHostAPI(hostService)
-	authRepo := repository3.NewAuthRepo(db)
-	authService := service3.NewAuthService(auther, authRepo)
+	repositoryOptions := InitAuthRepoOptions(config)
+	authRepo := repository3.NewAuthRepo(db, repositoryOptions)
+	serviceOptions := InitAuthOptions(config)
+	authService := service3.NewAuthService(auther, authRepo, serviceOptions)
 	authAPI := api3.NewLoginAPI(authService)
 	auditRepo := repository4.NewAuditRepo(db)
-	auditService := service4.NewAuditService(auditRepo)
+	archiver := InitAuditArchiver(config, chain)
+	auditService := service4.NewAuditService(auditRepo, chain, archiver)
 	auditAPI := api4.NewAuditAPI(auditService)
-	loggerHandler := NewLoggerHandler()
+	registry := health.NewRegistry()
+	systemRepo := repository5.NewSystemRepo(db, runtime, registry)
+	exporters, cleanup6, err := InitExporters(config)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	timedTask := NewTimedTask(config, db, metricStore, exporters, runtime, registry)
+	systemService := service5.NewSystemService(systemRepo, timedTask)
+	systemAPI := api5.NewSystemAPI(systemService)
+	loggerHandler := NewLoggerHandler(runtime)
 	router := &Router{
 		config:        config,
 		auth:          auther,
@@ -64,21 +105,29 @@ func BuildInjector(configFile string) (*Injector, func(), error) {
 		hostAPI:       hostAPI,
 		authAPI:       authAPI,
 		auditAPI:      auditAPI,
+		systemAPI:     systemAPI,
 		loggerHandler: loggerHandler,
 	}
 	app := NewFiberApp(config, router)
 	prepare := &Prepare{
 		db: db,
 	}
-	timedTask := NewTimedTask(config, db)
 	logger := NewLogger(config)
-	injector, err := NewInjector(app, router, prepare, config, timedTask, logger)
+	injector, err := NewInjector(app, router, prepare, config, timedTask, archiver, logger)
 	if err != nil {
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
 	return injector, func() {
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
 		cleanup2()
 		cleanup()
 	}, nil
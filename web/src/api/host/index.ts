@@ -17,7 +17,11 @@ import {
     MemTrending,
     MemTrendingArgs,
     NetTrendingArgs,
-    NetUsage
+    NetUsage,
+    PressureTrendingArgs,
+    PressureUsage,
+    SwapTrending,
+    SwapTrendingArgs
 } from '@/interface/host.ts'
 
 export function queryHostInfo() {
@@ -37,6 +41,13 @@ export function queryMemInfo() {
 export function queryMemUsage(param: MemTrendingArgs) {
     return request.get<MemTrending>('/api/v1/host/mem_trending', param)
 }
+export function querySwapUsage(param: SwapTrendingArgs) {
+    return request.get<SwapTrending>('/api/v1/host/swap_trending', param)
+}
+
+export function queryPressureUsage(param: PressureTrendingArgs) {
+    return request.get<PressureUsage[]>('/api/v1/host/psi_trending', param)
+}
 
 export function queryDiskInfo() {
     return request.get<DiskInfoResult>('/api/v1/host/disk_info', {})
@@ -241,3 +241,129 @@ export const netOptions: EChartsOption = {
         }
     ]
 }
+
+export const swapOptions: EChartsOption = {
+    // title: {
+    //     text: 'Swap 换入换出',
+    // },
+    tooltip: {
+        trigger: 'axis',
+        axisPointer: {
+            type: 'cross',
+            label: {
+                backgroundColor: '#6a7985'
+            }
+        }
+    },
+    legend: {
+        data: ['Swap In', 'Swap Out'],
+        left: 'right'
+    },
+    grid: {
+        left: '3%',
+        right: '4%',
+        bottom: '3%',
+        containLabel: true
+    },
+    xAxis: {
+        type: 'category',
+        boundaryGap: false,
+        data: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+    },
+    yAxis: [
+        {
+            type: 'value',
+            axisLabel: {
+                show: true,
+                formatter: function yAxisLabelFormatter(value: number): string {
+                    const units = ['B', 'KB', 'MB', 'GB', 'TB']
+                    let unitIndex = 0
+                    while (value >= 1024 && unitIndex < units.length - 1) {
+                        value /= 1024
+                        unitIndex++
+                    }
+                    return value.toFixed(2) + ' ' + units[unitIndex]
+                }
+            }
+        }
+    ],
+    series: [
+        {
+            name: 'Swap In',
+            type: 'line',
+            stack: 'Total',
+            areaStyle: {},
+            emphasis: {
+                focus: 'series'
+            },
+            data: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+        },
+        {
+            name: 'Swap Out',
+            type: 'line',
+            stack: 'Total',
+            areaStyle: {},
+            emphasis: {
+                focus: 'series'
+            },
+            data: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+        }
+    ]
+}
+
+export const pressureOptions: EChartsOption = {
+    // title: {
+    //     text: 'PSI 压力',
+    // },
+    tooltip: {
+        trigger: 'axis',
+        axisPointer: {
+            type: 'cross',
+            label: {
+                backgroundColor: '#6a7985'
+            }
+        }
+    },
+    legend: {
+        data: ['Some', 'Full'],
+        left: 'right'
+    },
+    grid: {
+        left: '3%',
+        right: '4%',
+        bottom: '3%',
+        containLabel: true
+    },
+    xAxis: {
+        type: 'category',
+        boundaryGap: false,
+        data: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+    },
+    yAxis: [
+        {
+            type: 'value',
+            axisLabel: {
+                show: true,
+                formatter: '{value} %'
+            }
+        }
+    ],
+    series: [
+        {
+            name: 'Some',
+            type: 'line',
+            emphasis: {
+                focus: 'series'
+            },
+            data: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+        },
+        {
+            name: 'Full',
+            type: 'line',
+            emphasis: {
+                focus: 'series'
+            },
+            data: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+        }
+    ]
+}
@@ -24,6 +24,11 @@ export interface DiskIO {
     timestamp: number
     io_read: number
     io_write: number
+    read_iops: number
+    write_iops: number
+    await: number
+    queue_depth: number
+    util: number
 }
 
 export interface DiskUsage {
@@ -34,6 +39,10 @@ export interface DiskUsage {
     used: number
     remaing: number
     data: DiskIO[]
+    // 仅用于页面展示的最近一次采样
+    iops?: string
+    await?: string
+    util?: string
 }
 
 export interface NetInfo{
@@ -61,6 +70,11 @@ export interface MemInfo {
     percent: number
     total: number
     used: number
+    available: number
+    buffers: number
+    cached: number
+    swap_total: number
+    swap_used: number
 }
 export interface DiskInfo {
     device: string
@@ -90,6 +104,38 @@ export interface MemTrending {
     data: Usage[]
 }
 
+export interface SwapTrendingArgs {
+    start_time: number
+    end_time: number
+}
+
+export interface SwapIO {
+    timestamp: number
+    swap_in: number
+    swap_out: number
+}
+
+export interface SwapTrending {
+    data: SwapIO[]
+}
+
+export interface PressureTrendingArgs {
+    start_time: number
+    end_time: number
+    resource?: string
+}
+
+export interface PressureIO {
+    timestamp: number
+    some: number
+    full: number
+}
+
+export interface PressureUsage {
+    resource: string
+    data: PressureIO[]
+}
+
 export interface DiskTrendingArgs {
     start_time: number
     end_time: number